@@ -0,0 +1,48 @@
+// Command import-candles loads historical OHLCV candles into stocks.db from CSV files, one
+// symbol and interval per run. Run it from the server's directory:
+//
+//	go run ./cmd/import-candles -symbol RELIANCE -interval 1d RELIANCE-2023.csv RELIANCE-2024.csv
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/db"
+)
+
+func main() {
+	symbol := flag.String("symbol", "", "trading symbol the candles belong to")
+	interval := flag.String("interval", "1d", "candle interval: 1m, 5m, 15m, 1h or 1d")
+	exchange := flag.String("exchange", "", "exchange whose calendar is used for the gap report (default: from the instruments master)")
+	flag.Parse()
+	if *symbol == "" || flag.NArg() == 0 {
+		log.Fatalf("usage: import-candles -symbol SYMBOL [-interval 1d] [-exchange NSE] FILE...")
+	}
+
+	db.InitDB()
+	defer db.DB.Close()
+
+	calendar := candles.CalendarFor(*symbol, *exchange)
+	for _, path := range flag.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("❌ Failed to open %s: %v", path, err)
+		}
+		result, err := candles.Import(*symbol, *interval, calendar, file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("❌ Failed to import candles from %s: %v", path, err)
+		}
+		log.Printf("🕯️ %s: %d rows, %d new, %d replaced, %d duplicates (%s to %s)", path, result.Rows,
+			result.Inserted, result.Updated, result.Duplicates, result.From.Format("2006-01-02 15:04"), result.To.Format("2006-01-02 15:04"))
+		if result.MissingCandles > 0 {
+			log.Printf("⚠️ %d %s candles missing on %s trading sessions", result.MissingCandles, *interval, calendar)
+			for _, gap := range result.Gaps {
+				log.Printf("   %s to %s (%d)", gap.From.Format("2006-01-02 15:04"), gap.To.Format("2006-01-02 15:04"), gap.Missing)
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+// Command import-instruments refreshes the instruments master in stocks.db from a Kite
+// instruments CSV dump (https://api.kite.trade/instruments). Run it from the server's directory:
+//
+//	go run ./cmd/import-instruments instruments.csv
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/instruments"
+)
+
+func main() {
+	path := instruments.File()
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+
+	db.InitDB()
+	defer db.DB.Close()
+
+	count, err := instruments.ImportFile(path)
+	if err != nil {
+		log.Fatalf("❌ Failed to import instruments from %s: %v", path, err)
+	}
+	log.Printf("📇 Imported %d instruments from %s", count, path)
+}
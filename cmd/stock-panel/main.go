@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/vinaykotian/stock-panel/internal/alertengine"
+	"github.com/vinaykotian/stock-panel/internal/alertsync"
+	"github.com/vinaykotian/stock-panel/internal/candles"
 	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/events"
 	"github.com/vinaykotian/stock-panel/internal/handlers"
+	"github.com/vinaykotian/stock-panel/internal/marketdata"
+	"github.com/vinaykotian/stock-panel/internal/outbox"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
 )
 
 func main() {
@@ -25,6 +34,31 @@ func main() {
 	// Start token cleanup goroutine
 	handlers.StartTokenCleanup()
 
+	// Start prompting users to log in to Kite again after the daily 6 AM token expiry
+	handlers.StartKiteSessionExpiryCheck()
+
+	// Deliver queued broker calls, and periodically repair drift between stored alerts and Kite
+	alertsync.Start()
+	outbox.Start()
+
+	// Start end-of-day portfolio snapshots
+	portfolio.StartSnapshotScheduler()
+
+	// Start pruning quotes past the retention window
+	quotes.StartRetention()
+
+	// Keep OHLCV candles up to date as quotes arrive
+	candles.Start()
+
+	// Push stored quotes to browser event streams
+	events.Start()
+
+	// Evaluate active alerts against every stored quote
+	alertengine.Start()
+
+	// Stream live quotes from the configured market-data provider (Kite, CSV replay or simulator)
+	marketdata.StartFromEnv(context.Background())
+
 	// Root redirect to login
 	http.HandleFunc("/", handlers.LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
@@ -156,6 +190,80 @@ func main() {
 		handlers.GetDailyPnL(w, r)
 	})))
 
+	// Portfolio endpoints (protected)
+	http.HandleFunc("/portfolio", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetPortfolio(w, r)
+	})))
+
+	http.HandleFunc("/portfolio/snapshot", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.TakePortfolioSnapshot(w, r)
+	})))
+
+	http.HandleFunc("/portfolio/benchmark", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetBenchmarkComparison(w, r)
+	})))
+
+	// Benchmark price series endpoints (protected)
+	http.HandleFunc("/benchmarks", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handlers.LoadBenchmark(w, r)
+		case http.MethodGet:
+			handlers.GetBenchmarks(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Cash ledger endpoints (protected)
+	http.HandleFunc("/ledger", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handlers.AddLedgerEntry(w, r)
+		case http.MethodGet:
+			handlers.GetLedger(w, r)
+		case http.MethodDelete:
+			handlers.DeleteLedgerEntry(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	// Capital-weighted and time-weighted returns (protected)
+	http.HandleFunc("/returns", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetReturns(w, r)
+	})))
+
+	// Quote ingestion and lookup endpoints (protected)
+	http.HandleFunc("/quotes", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.IngestQuotes(w, r)
+	})))
+
+	http.HandleFunc("/quotes/latest", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetLatestQuotes(w, r)
+	})))
+
+	// Candle endpoints (protected)
+	http.HandleFunc("/candles", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetCandles(w, r)
+	})))
+
+	http.HandleFunc("/candles/rebuild", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RebuildCandles(w, r)
+	})))
+
+	http.HandleFunc("/candles/import", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.ImportCandles(w, r)
+	})))
+
+	http.HandleFunc("/candles/backfill", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.BackfillCandles(w, r)
+	})))
+
+	http.HandleFunc("/candles/gaps", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetCandleGaps(w, r)
+	})))
+
 	// Alerts endpoints (protected)
 	http.HandleFunc("/alerts", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -177,7 +285,95 @@ func main() {
 		handlers.ToggleAlert(w, r)
 	})))
 
+	// Alert trigger history (protected)
+	http.HandleFunc("/alerts/events", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAlertHistory(w, r)
+	})))
+
+	http.HandleFunc("GET /alerts/{id}/events", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAlertEvents(w, r)
+	})))
+
+	// Alert notification channels (protected)
+	http.HandleFunc("/notification-channels", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handlers.CreateNotificationChannel(w, r)
+		case http.MethodGet:
+			handlers.GetNotificationChannels(w, r)
+		case http.MethodPut:
+			handlers.UpdateNotificationChannel(w, r)
+		case http.MethodDelete:
+			handlers.DeleteNotificationChannel(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	http.HandleFunc("/notification-channels/test", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.TestNotificationChannel(w, r)
+	})))
+
+	// Kite Connect login flow; the callback is reached by Kite's browser redirect, so it is not behind AuthMiddleware
+	http.HandleFunc("/kite/callback", handlers.LoggingMiddleware(handlers.HandleKiteCallback))
+
+	http.HandleFunc("/market/status", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMarketStatus(w, r)
+	})))
+
+	http.HandleFunc("/market/holidays", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handlers.LoadMarketHolidays(w, r)
+		case http.MethodGet:
+			handlers.GetMarketHolidays(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	http.HandleFunc("/instruments/search", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.SearchInstruments(w, r)
+	})))
+
+	http.HandleFunc("/instruments/import", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.ImportInstruments(w, r)
+	})))
+
+	// Live updates for the browser (protected)
+	http.HandleFunc("/events", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.StreamEvents(w, r)
+	})))
+
+	http.HandleFunc("/outbox", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetOutbox(w, r)
+	})))
+
+	http.HandleFunc("/outbox/dead", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetDeadLetters(w, r)
+	})))
+
+	http.HandleFunc("/outbox/retry", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RetryDeadLetter(w, r)
+	})))
+
+	http.HandleFunc("/kite/login-url", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetKiteLoginURL(w, r)
+	})))
+
+	http.HandleFunc("/kite/session", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetKiteSessionStatus(w, r)
+	})))
+
+	http.HandleFunc("/kite/logout", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.KiteLogout(w, r)
+	})))
+
 	// Test Kite API endpoint (protected)
+	http.HandleFunc("/alerts/reconcile", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.ReconcileAlerts(w, r)
+	})))
+
 	http.HandleFunc("/alerts/test-kite", handlers.LoggingMiddleware(handlers.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		handlers.TestKiteAPI(w, r)
 	})))
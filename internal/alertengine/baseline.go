@@ -0,0 +1,236 @@
+package alertengine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// ErrInvalidBaseline wraps errors in a percentage change alert's baseline or direction
+var ErrInvalidBaseline = errors.New("invalid baseline")
+
+// baselineType is the alert's baseline, defaulting to the price at creation
+func baselineType(a models.Alert) string {
+	if a.BaselineType == "" {
+		return models.BaselineCreationPrice
+	}
+	return a.BaselineType
+}
+
+// daily reports whether the baseline moves to each new trading day
+func daily(baseline string) bool {
+	return baseline == models.BaselinePreviousClose || baseline == models.BaselineDayOpen
+}
+
+// ResolveBaseline sets the baseline of a PERCENTAGE_CHANGE alert as of now and clears it for other
+// alert types. A creation price or day open without quotes yet is left at 0 and taken from the
+// next quote the engine sees.
+func ResolveBaseline(a *models.Alert, now time.Time) error {
+	if a.AlertType != models.AlertPercentageChange {
+		a.BaselineType, a.BaselineValue, a.BaselineDate, a.Direction = "", 0, "", ""
+		return nil
+	}
+
+	switch a.Direction {
+	case "":
+		a.Direction = models.DirectionSigned
+	case models.DirectionSigned, models.DirectionEither:
+	default:
+		return fmt.Errorf("%w: unknown direction %q, expected SIGNED or EITHER", ErrInvalidBaseline, a.Direction)
+	}
+
+	a.BaselineType = baselineType(*a)
+	a.BaselineDate = ""
+	switch a.BaselineType {
+	case models.BaselineFixed:
+		if a.BaselineValue <= 0 {
+			return fmt.Errorf("%w: a FIXED baseline needs a positive baseline_value", ErrInvalidBaseline)
+		}
+		return nil
+	case models.BaselineCreationPrice:
+		a.BaselineValue = 0
+		q, ok, err := quotes.Latest(a.Symbol)
+		if err != nil {
+			return err
+		}
+		if ok {
+			a.BaselineValue = q.LTP
+		}
+		return nil
+	case models.BaselinePreviousClose, models.BaselineDayOpen:
+		calendar := calendarOf(*a, "")
+		day := market.LastTradingDay(calendar, now)
+		value, err := dailyBaseline(*a, calendar, day)
+		if err != nil {
+			return err
+		}
+		a.BaselineValue = value
+		a.BaselineDate = day.Format(market.DateFormat)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown baseline type %q, expected PREVIOUS_CLOSE, CREATION_PRICE, DAY_OPEN or FIXED", ErrInvalidBaseline, a.BaselineType)
+}
+
+// KeepsBaseline reports whether an edited alert can keep its stored baseline: same symbol and
+// baseline type, and not a fixed baseline, whose value comes with the request
+func KeepsBaseline(stored, edited models.Alert) bool {
+	return stored.AlertType == models.AlertPercentageChange && edited.AlertType == models.AlertPercentageChange &&
+		stored.Symbol == edited.Symbol && baselineType(stored) == baselineType(edited) &&
+		baselineType(edited) != models.BaselineFixed && stored.BaselineValue > 0
+}
+
+// calendarOf is the market calendar of the alert's exchange, else the quote's, else of the
+// alert's instrument; it may query the instruments master
+func calendarOf(a models.Alert, quoteExchange string) string {
+	exchange := a.Exchange
+	if exchange == "" {
+		exchange = quoteExchange
+	}
+	return candles.CalendarFor(a.Symbol, exchange)
+}
+
+// dailyBaseline returns the previous close or the open of a trading day (midnight IST) on the
+// calendar, or 0 if it is not known yet. Both come from the daily candle, the previous close
+// falling back to the last quote before that day's close.
+func dailyBaseline(a models.Alert, calendar string, day time.Time) (float64, error) {
+	if baselineType(a) == models.BaselineDayOpen {
+		c, ok, err := dailyCandle(a.Symbol, day)
+		if err != nil || !ok {
+			return 0, err
+		}
+		return c.Open, nil
+	}
+
+	prev := market.PreviousTradingDay(calendar, day)
+	c, ok, err := dailyCandle(a.Symbol, prev)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return c.Close, nil
+	}
+	q, ok, err := quotes.LatestAt(a.Symbol, market.Close(calendar, prev))
+	if err != nil || !ok {
+		return 0, err
+	}
+	return q.LTP, nil
+}
+
+// calendarAt is the entry's calendar for a quote: the alert's exchange, else the quote's, else
+// the instrument's as resolved when the entry was built. It never queries the database.
+func (e *entry) calendarAt(quoteExchange string) string {
+	if e.alert.Exchange == "" && quoteExchange != "" {
+		return market.Calendar(quoteExchange)
+	}
+	return e.calendar
+}
+
+func dailyCandle(symbol string, day time.Time) (models.Candle, bool, error) {
+	list, err := candles.Query(symbol, "1d", day, day.AddDate(0, 0, 1))
+	if err != nil || len(list) == 0 {
+		return models.Candle{}, false, err
+	}
+	return list[0], true, nil
+}
+
+// baselineRetryDelay is how long a daily baseline that failed to load is left before trying again
+const baselineRetryDelay = time.Minute
+
+// baselineKey identifies a daily baseline; alerts on the same symbol share it
+type baselineKey struct {
+	symbol, baseline, calendar string
+	day                        time.Time
+}
+
+var (
+	baselineMu     sync.Mutex
+	baselineFailed = make(map[baselineKey]time.Time) // failed lookups and when they may be retried
+)
+
+// baselineKeyAt is the daily baseline an indexed percentage alert needs for a quote
+func (e *entry) baselineKeyAt(q models.Quote) baselineKey {
+	calendar := e.calendarAt(q.Exchange)
+	return baselineKey{symbol: e.alert.Symbol, baseline: baselineType(e.alert), calendar: calendar, day: market.LastTradingDay(calendar, q.Timestamp)}
+}
+
+// loadBaselines looks up the daily baselines that a batch moves percentage alerts to, so rebase
+// does not query the database under the write lock. A lookup that fails is not repeated until
+// baselineRetryDelay has passed. Takes mu for reading.
+func loadBaselines(batch []models.Quote) map[baselineKey]float64 {
+	due := make(map[baselineKey]models.Alert)
+	mu.RLock()
+	for _, q := range batch {
+		for _, e := range bySymbol[q.Symbol] {
+			if e.alert.AlertType != models.AlertPercentageChange || !daily(baselineType(e.alert)) {
+				continue
+			}
+			if key := e.baselineKeyAt(q); key.day.Format(market.DateFormat) != e.alert.BaselineDate {
+				due[key] = e.alert
+			}
+		}
+	}
+	mu.RUnlock()
+	if len(due) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	values := make(map[baselineKey]float64, len(due))
+	for key, a := range due {
+		baselineMu.Lock()
+		retryAt, failed := baselineFailed[key]
+		baselineMu.Unlock()
+		if failed && now.Before(retryAt) {
+			continue
+		}
+		value, err := dailyBaseline(a, key.calendar, key.day)
+		baselineMu.Lock()
+		if err != nil {
+			log.Printf("❌ Failed to load the %s baseline of %s for %s: %v", key.baseline, key.symbol, key.day.Format(market.DateFormat), err)
+			baselineFailed[key] = now.Add(baselineRetryDelay)
+			// Forget failures of past days, which are not asked for again
+			for k, retryAt := range baselineFailed {
+				if now.Sub(retryAt) > 24*time.Hour {
+					delete(baselineFailed, k)
+				}
+			}
+		} else {
+			delete(baselineFailed, key)
+			values[key] = value
+		}
+		baselineMu.Unlock()
+	}
+	return values
+}
+
+// rebase brings an indexed percentage alert's baseline up to date for a quote and reports whether
+// it changed. Daily baselines move to the quote's trading day once loadBaselines has found them;
+// a missing creation price or day open is taken from the quote itself. Expects mu to be held for
+// writing.
+func rebase(e *entry, q models.Quote, baselines map[baselineKey]float64) bool {
+	a := &e.alert
+	changed := false
+	if daily(baselineType(*a)) {
+		key := e.baselineKeyAt(q)
+		if date := key.day.Format(market.DateFormat); date != a.BaselineDate {
+			value, ok := baselines[key]
+			if !ok {
+				return false
+			}
+			a.BaselineValue, a.BaselineDate = value, date
+			changed = true
+		}
+	}
+	if t := baselineType(*a); a.BaselineValue <= 0 && (t == models.BaselineCreationPrice || t == models.BaselineDayOpen) && q.LTP > 0 {
+		a.BaselineValue = q.LTP
+		changed = true
+	}
+	return changed
+}
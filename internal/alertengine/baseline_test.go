@@ -0,0 +1,116 @@
+package alertengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+func TestRebaseAcrossTradingDays(t *testing.T) {
+	// Daily candles for Friday 16 and Monday 19 October
+	_, _, err := candles.Save("REBASE", "1d", []models.Candle{
+		{Start: ist(16, 0, 0), Open: 95, High: 101, Low: 94, Close: 100},
+		{Start: ist(19, 0, 0), Open: 101, High: 112, Low: 100, Close: 110},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := index(t, models.Alert{ID: 201, Symbol: "REBASE", Exchange: "NSE", AlertType: models.AlertPercentageChange, TargetValue: 2,
+		IsActive: true, BaselineType: models.BaselinePreviousClose, BaselineValue: 90, BaselineDate: "2026-10-16"})
+
+	step := func(q models.Quote) bool {
+		baselines := loadBaselines([]models.Quote{q})
+		mu.Lock()
+		defer mu.Unlock()
+		return rebase(e, q, baselines)
+	}
+
+	// Monday's quotes move the baseline to Friday's close
+	monday := models.Quote{Symbol: "REBASE", Exchange: "NSE", LTP: 105, Timestamp: ist(19, 10, 0)}
+	if !step(monday) || e.alert.BaselineValue != 100 || e.alert.BaselineDate != "2026-10-19" {
+		t.Fatalf("baseline %v on %s, want 100 on 2026-10-19", e.alert.BaselineValue, e.alert.BaselineDate)
+	}
+	monday.Timestamp = ist(19, 15, 0)
+	if step(monday) {
+		t.Error("the baseline should not change within a trading day")
+	}
+
+	// Without a loaded baseline the alert keeps its old one
+	tuesday := models.Quote{Symbol: "REBASE", Exchange: "NSE", LTP: 111, Timestamp: ist(20, 10, 0)}
+	mu.Lock()
+	changed := rebase(e, tuesday, nil)
+	mu.Unlock()
+	if changed || e.alert.BaselineValue != 100 {
+		t.Error("rebase should wait for loadBaselines")
+	}
+
+	// Tuesday's quotes move it to Monday's close
+	if !step(tuesday) || e.alert.BaselineValue != 110 || e.alert.BaselineDate != "2026-10-20" {
+		t.Fatalf("baseline %v on %s, want 110 on 2026-10-20", e.alert.BaselineValue, e.alert.BaselineDate)
+	}
+
+	// A Saturday quote belongs to the trading day of Friday 23 October
+	saturday := models.Quote{Symbol: "REBASE", Exchange: "NSE", LTP: 111, Timestamp: ist(24, 10, 0)}
+	if !step(saturday) || e.alert.BaselineDate != "2026-10-23" {
+		t.Errorf("baseline date %s, want Friday 2026-10-23", e.alert.BaselineDate)
+	}
+}
+
+func TestRebaseDayOpenFromQuote(t *testing.T) {
+	// Without a daily candle the day open is taken from the first quote of the day
+	e := index(t, models.Alert{ID: 202, Symbol: "NOCANDLES", Exchange: "NSE", AlertType: models.AlertPercentageChange, TargetValue: 2,
+		IsActive: true, BaselineType: models.BaselineDayOpen, BaselineDate: "2026-10-16"})
+	q := models.Quote{Symbol: "NOCANDLES", Exchange: "NSE", LTP: 250, Timestamp: ist(19, 9, 15)}
+	baselines := loadBaselines([]models.Quote{q})
+	mu.Lock()
+	changed := rebase(e, q, baselines)
+	mu.Unlock()
+	if !changed || e.alert.BaselineValue != 250 || e.alert.BaselineDate != "2026-10-19" {
+		t.Errorf("baseline %v on %s, want 250 on 2026-10-19", e.alert.BaselineValue, e.alert.BaselineDate)
+	}
+}
+
+func TestLoadBaselinesBacksOffAfterFailure(t *testing.T) {
+	// A daily candle that cannot be read makes the lookup fail
+	_, _, err := candles.Save("BROKEN", "1d", []models.Candle{{Start: ist(16, 0, 0), Open: 95, High: 101, Low: 94, Close: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec("UPDATE candles SET close = 'not a price' WHERE symbol = 'BROKEN'"); err != nil {
+		t.Fatal(err)
+	}
+	index(t, models.Alert{ID: 203, Symbol: "BROKEN", Exchange: "NSE", AlertType: models.AlertPercentageChange, TargetValue: 2,
+		IsActive: true, BaselineType: models.BaselinePreviousClose, BaselineDate: "2026-10-16"})
+	batch := []models.Quote{{Symbol: "BROKEN", Exchange: "NSE", LTP: 105, Timestamp: ist(19, 10, 0)}}
+	if values := loadBaselines(batch); len(values) != 0 {
+		t.Fatalf("loaded %v from an unreadable candle", values)
+	}
+
+	// Repaired, the candle is not read again until the retry delay has passed
+	if _, err := db.DB.Exec("UPDATE candles SET close = 100 WHERE symbol = 'BROKEN'"); err != nil {
+		t.Fatal(err)
+	}
+	if values := loadBaselines(batch); len(values) != 0 {
+		t.Error("a failed baseline was looked up again straight away")
+	}
+	baselineMu.Lock()
+	for key := range baselineFailed {
+		if key.symbol == "BROKEN" {
+			baselineFailed[key] = time.Now().Add(-time.Second)
+		}
+	}
+	baselineMu.Unlock()
+	values := loadBaselines(batch)
+	if len(values) != 1 {
+		t.Fatalf("loaded %v after the retry delay, want one baseline", values)
+	}
+	for _, v := range values {
+		if v != 100 {
+			t.Errorf("baseline %v, want 100", v)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+// Package alertengine evaluates active alerts against incoming quotes. Alerts are held in an
+// in-memory index by symbol, so a tick costs a map lookup and touches the database only when an
+// alert fires.
+package alertengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/alertexpr"
+	"github.com/vinaykotian/stock-panel/internal/alertsync"
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/events"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/notifier"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+const (
+	// reloadInterval rebuilds the index from the database, catching changes made outside the API
+	reloadInterval = 5 * time.Minute
+
+	// triggerQueueSize bounds fired alerts waiting to be recorded and notified
+	triggerQueueSize = 1024
+)
+
+// entry is an indexed alert
+type entry struct {
+	alert    models.Alert
+	symbols  []string  // symbols whose quotes evaluate the alert
+	calendar string    // market calendar of the alert's exchange, else of its instrument
+	rearmsAt time.Time // when a fired recurring or daily alert can fire again
+
+	// Parsed condition of expression alerts, and its state between evaluations
+	expr      *alertexpr.Expr
+	exprState *alertexpr.State
+}
+
+// trigger is an alert that matched a quote or a closed candle
+type trigger struct {
+	alert      models.Alert
+	price      float64
+	at         time.Time
+	indicators map[string]float64 // values of an indicator alert
+}
+
+var (
+	mu       sync.RWMutex
+	bySymbol = make(map[string]map[int]*entry)
+	byID     = make(map[int]*entry)
+
+	triggers = make(chan trigger, triggerQueueSize)
+)
+
+// MarketHoursOnly reports whether alerts only fire while their exchange is in continuous trading.
+// ALERT_MARKET_HOURS_ONLY=false lets simulated or replayed quotes fire alerts at any time.
+func MarketHoursOnly() bool {
+	if value := os.Getenv("ALERT_MARKET_HOURS_ONLY"); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("⚠️  Invalid ALERT_MARKET_HOURS_ONLY %q, alerts fire during market hours only", value)
+	}
+	return true
+}
+
+// Start loads active alerts, evaluates every stored quote batch and closed candle, records
+// triggers and expires alerts in the background
+func Start() {
+	if err := Load(); err != nil {
+		log.Printf("❌ Failed to load alerts for evaluation: %v", err)
+	}
+	quotes.OnSave(Evaluate)
+
+	go func() {
+		for t := range triggers {
+			fire(t)
+		}
+	}()
+	go func() {
+		for range time.Tick(reloadInterval) {
+			if err := Load(); err != nil {
+				log.Printf("❌ Failed to reload alerts for evaluation: %v", err)
+			}
+		}
+	}()
+	go func() {
+		for {
+			Expire()
+			time.Sleep(expireInterval)
+		}
+	}()
+	go func() {
+		for now := range time.Tick(candleSweepInterval) {
+			sweepCandles(now)
+		}
+	}()
+}
+
+// Load rebuilds the index from the active alerts in the database
+func Load() error {
+	alerts, err := db.GetActiveAlerts()
+	if err != nil {
+		return err
+	}
+
+	// Entries may look up calendars in the database, so build them before taking the lock
+	entries := make([]*entry, 0, len(alerts))
+	for _, a := range alerts {
+		e, err := newEntry(a)
+		if err != nil {
+			log.Printf("❌ Skipping alert %d: %v", a.ID, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	mu.Lock()
+	previous := byID
+	bySymbol = make(map[string]map[int]*entry)
+	byID = make(map[int]*entry, len(entries))
+	byUser = make(map[int]map[int]*entry)
+	for _, e := range entries {
+		// Keep the state of unchanged expressions, so a reload does not miss a cross
+		if old, ok := previous[e.alert.ID]; ok && old.expr != nil && e.expr != nil && old.alert.Expression == e.alert.Expression {
+			e.exprState = old.exprState
+		}
+		add(e)
+	}
+	mu.Unlock()
+	log.Printf("🔔 Evaluating %d active alerts", len(alerts))
+
+	loadBooks(portfolioUsers())
+	return nil
+}
+
+// Refresh re-reads one alert after it was created, edited or toggled
+func Refresh(alertID int) {
+	alert, err := db.GetAlert(alertID)
+	if err != nil {
+		Remove(alertID)
+		return
+	}
+
+	if !alert.IsActive {
+		Remove(alertID)
+		return
+	}
+	e, err := newEntry(alert)
+	if err != nil {
+		Remove(alertID)
+		log.Printf("❌ Skipping alert %d: %v", alertID, err)
+		return
+	}
+	mu.Lock()
+	remove(alertID)
+	add(e)
+	mu.Unlock()
+
+	// The first portfolio alert of a user needs their positions
+	if IsPortfolio(alert.AlertType) {
+		bookMu.Lock()
+		_, loaded := books[alert.UserID]
+		bookMu.Unlock()
+		if !loaded {
+			RefreshPortfolio(alert.UserID)
+		}
+	}
+}
+
+// Remove drops an alert from the index
+func Remove(alertID int) {
+	mu.Lock()
+	defer mu.Unlock()
+	remove(alertID)
+}
+
+// add and remove expect mu to be held for writing
+func add(e *entry) {
+	if IsPortfolio(e.alert.AlertType) {
+		userEntries, ok := byUser[e.alert.UserID]
+		if !ok {
+			userEntries = make(map[int]*entry)
+			byUser[e.alert.UserID] = userEntries
+		}
+		userEntries[e.alert.ID] = e
+	}
+	for _, symbol := range e.symbols {
+		symbolEntries, ok := bySymbol[symbol]
+		if !ok {
+			symbolEntries = make(map[int]*entry)
+			bySymbol[symbol] = symbolEntries
+		}
+		symbolEntries[e.alert.ID] = e
+	}
+	byID[e.alert.ID] = e
+}
+
+func remove(alertID int) {
+	e, ok := byID[alertID]
+	if !ok {
+		return
+	}
+	delete(byID, alertID)
+	if userEntries, ok := byUser[e.alert.UserID]; ok {
+		delete(userEntries, alertID)
+		if len(userEntries) == 0 {
+			delete(byUser, e.alert.UserID)
+		}
+	}
+	for _, symbol := range e.symbols {
+		delete(bySymbol[symbol], alertID)
+		if len(bySymbol[symbol]) == 0 {
+			delete(bySymbol, symbol)
+		}
+	}
+}
+
+// Evaluate checks a batch of quotes against the indexed alerts. Matching one-shot alerts leave the
+// index at once and recurring ones start waiting to re-arm, so later ticks cannot fire them again;
+// triggers are recorded and notified in the background. Indicator alerts are evaluated when a
+// quote closes their candle, portfolio alerts when it moves a position of their user.
+func Evaluate(batch []models.Quote) {
+	hoursOnly := MarketHoursOnly()
+	var fired []trigger
+	var rebased []models.Alert
+	var observed []observation
+	trailed := make(map[int]models.Alert) // trailing stops whose trail moved
+	evaluated := make(map[int]bool)       // expression alerts already evaluated in this batch
+
+	// Expressions read other symbols and stored candles, and daily baselines come from stored
+	// candles and quotes; load them before taking the write lock
+	exprSymbols := make(map[string]bool)
+	mu.RLock()
+	for _, q := range batch {
+		for _, e := range bySymbol[q.Symbol] {
+			if e.expr != nil {
+				for _, symbol := range e.symbols {
+					exprSymbols[symbol] = true
+				}
+			}
+		}
+	}
+	mu.RUnlock()
+	env := newQuoteEnv(batch, exprSymbols)
+	baselines := loadBaselines(batch)
+
+	mu.Lock()
+	for _, q := range batch {
+		for _, e := range bySymbol[q.Symbol] {
+			if IsIndicator(e.alert.AlertType) {
+				observed = append(observed, observation{key: candleKey{q.Symbol, e.alert.Interval}, exchange: exchangeOf(e.alert, q), at: q.Timestamp})
+				continue
+			}
+			if hoursOnly && !market.IsOpen(market.Calendar(exchangeOf(e.alert, q)), q.Timestamp) {
+				continue
+			}
+			if e.alert.AlertType == models.AlertPercentageChange && rebase(e, q, baselines) {
+				rebased = append(rebased, e.alert)
+			}
+			if !armed(e, q.Timestamp) {
+				continue
+			}
+			price := q.LTP
+			var matched bool
+			var err error
+			var values map[string]float64
+			switch {
+			case e.expr != nil:
+				if evaluated[e.alert.ID] {
+					continue
+				}
+				evaluated[e.alert.ID] = true
+				matched, err = e.expr.Eval(env, e.alert.Symbol, e.exprState)
+				price = expressionPrice(env, e.alert, q)
+			case e.alert.AlertType == models.AlertTrailingStop:
+				var changed bool
+				if changed, matched = trail(e, q.LTP); changed {
+					trailed[e.alert.ID] = e.alert
+				}
+				values = trailValues(e.alert)
+			default:
+				matched, err = matches(e, q.LTP)
+			}
+			if err != nil || !matched {
+				continue
+			}
+			fired = append(fired, trigger{alert: e.alert, price: price, at: q.Timestamp, indicators: values})
+			settle(e, price, q.Timestamp)
+			if e.alert.AlertType == models.AlertTrailingStop && policy(e.alert) != models.PolicyOnce {
+				resetTrail(e)
+				trailed[e.alert.ID] = e.alert
+			}
+		}
+	}
+	mu.Unlock()
+
+	for _, a := range rebased {
+		if err := db.SetAlertBaseline(a.ID, a.BaselineValue, a.BaselineDate); err != nil {
+			log.Printf("❌ Failed to store baseline of alert %d: %v", a.ID, err)
+		}
+	}
+	for _, a := range trailed {
+		if err := db.SetAlertTrail(a.ID, a.TrailExtreme, a.TrailLevel); err != nil {
+			log.Printf("❌ Failed to store trail of alert %d: %v", a.ID, err)
+		}
+	}
+	fired = append(fired, evaluatePortfolios(batch, hoursOnly)...)
+	queue(fired)
+	for _, c := range observe(observed) {
+		evaluateCandle(c)
+	}
+}
+
+// settle takes a fired alert out of the index, or starts it waiting to re-arm; mu must be held
+// for writing
+func settle(e *entry, price float64, at time.Time) {
+	if policy(e.alert) == models.PolicyOnce {
+		remove(e.alert.ID)
+		return
+	}
+	e.alert.TriggeredAt = &at
+	e.alert.TriggeredPrice = price
+	e.alert.TriggerCount++
+	e.rearmsAt, _ = rearmsAt(e.alert, e.calendar)
+}
+
+// queue hands triggers to the background recorder
+func queue(fired []trigger) {
+	for _, t := range fired {
+		select {
+		case triggers <- t:
+		default:
+			// Never drop a trigger; record it on the ingesting goroutine instead
+			fire(t)
+		}
+	}
+}
+
+// exchangeOf is the alert's exchange, else the quote's
+func exchangeOf(a models.Alert, q models.Quote) string {
+	if a.Exchange != "" {
+		return a.Exchange
+	}
+	return q.Exchange
+}
+
+// matches evaluates an alert at a price. Price alerts compare the price with the target;
+// PERCENTAGE_CHANGE compares the percentage move from the baseline, or its size for EITHER.
+func matches(e *entry, price float64) (bool, error) {
+	a := e.alert
+	if a.AlertType != models.AlertPercentageChange {
+		return compare(price, condition(a), a.TargetValue)
+	}
+	if a.BaselineValue <= 0 {
+		return false, nil
+	}
+	move := (price - a.BaselineValue) / a.BaselineValue * 100
+	if a.Direction == models.DirectionEither {
+		return compare(math.Abs(move), condition(a), math.Abs(a.TargetValue))
+	}
+	return compare(move, condition(a), a.TargetValue)
+}
+
+// condition is the alert's explicit condition, else the one implied by its type
+func condition(a models.Alert) string {
+	if a.Condition != "" {
+		return a.Condition
+	}
+	switch a.AlertType {
+	case models.AlertPriceBelow:
+		return "<="
+	}
+	return ">="
+}
+
+func compare(value float64, condition string, target float64) (bool, error) {
+	switch condition {
+	case ">":
+		return value > target, nil
+	case ">=":
+		return value >= target, nil
+	case "<":
+		return value < target, nil
+	case "<=":
+		return value <= target, nil
+	}
+	return false, fmt.Errorf("unknown condition %q", condition)
+}
+
+// fire records the trigger and notifies the user, unless another trigger won the race
+func fire(t trigger) {
+	eventID, err := db.TriggerAlert(t.alert, t.price, t.at, t.indicators)
+	if err != nil {
+		log.Printf("❌ Failed to record trigger of alert %d: %v", t.alert.ID, err)
+		return
+	}
+	if eventID == 0 {
+		return
+	}
+	log.Printf("🔔 Alert %d triggered: %s at %.2f", t.alert.ID, t.alert.Symbol, t.price)
+
+	// A one-shot alert is now inactive; bring the Kite copy in line
+	if policy(t.alert) == models.PolicyOnce {
+		if err := alertsync.QueuePush(t.alert.UserID, t.alert.ID); err != nil {
+			log.Printf("Warning: Failed to queue Kite sync for alert %d: %v", t.alert.ID, err)
+		}
+	}
+	notify(eventID, t)
+}
+
+// notify pushes the trigger to the user's browser streams and sends it over the alert's
+// notification channels, recording each channel's result on the alert event
+func notify(eventID int64, t trigger) {
+	message := t.alert.Message
+	if message == "" {
+		message = fmt.Sprintf("%s %s %s %g", t.alert.Symbol, t.alert.AlertType, condition(t.alert), t.alert.TargetValue)
+		switch {
+		case t.alert.AlertType == models.AlertExpression:
+			message = t.alert.Expression
+		case IsIndicator(t.alert.AlertType):
+			message = describeIndicator(t.alert)
+		case t.alert.AlertType == models.AlertTrailingStop:
+			message = describeTrail(t.alert)
+		case IsPortfolio(t.alert.AlertType):
+			message = describePortfolio(t.alert, t.indicators)
+		}
+	}
+	alertTrigger := models.AlertTrigger{
+		AlertID:     t.alert.ID,
+		Symbol:      t.alert.Symbol,
+		Price:       t.price,
+		Message:     message,
+		TriggeredAt: t.at,
+		Indicators:  t.indicators,
+	}
+	streams := events.PublishAlert(t.alert.UserID, alertTrigger)
+	if streams > 0 {
+		recordDelivery(eventID, "events", 0, models.DeliveryDelivered, "")
+	} else {
+		recordDelivery(eventID, "events", 0, models.DeliverySkipped, "no open event streams")
+	}
+
+	channels, err := notifier.ChannelsFor(t.alert.UserID, t.alert.Channels)
+	if err != nil {
+		log.Printf("❌ Failed to load notification channels of user %d: %v", t.alert.UserID, err)
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+	m := triggerMessage(t, message)
+	m.Payload = models.AlertWebhookPayload{Type: "alert.triggered", EventID: eventID, Trigger: alertTrigger, Alert: &t.alert}
+	for _, ch := range channels {
+		go func(ch models.NotificationChannel) {
+			status, detail := deliver(ch, m, t.alert.ID)
+			recordDelivery(eventID, strings.ToLower(ch.Type), ch.ID, status, detail)
+		}(ch)
+	}
+}
+
+// deliver sends a message over a channel and returns the delivery status and detail
+func deliver(ch models.NotificationChannel, m notifier.Message, alertID int) (string, string) {
+	err := notifier.Send(context.Background(), ch, m)
+	switch {
+	case errors.Is(err, notifier.ErrNotConfigured):
+		return models.DeliverySkipped, fmt.Sprintf("%s is not configured", strings.ToLower(ch.Type))
+	case err != nil:
+		log.Printf("❌ Failed to send trigger of alert %d over %s channel %q: %v", alertID, ch.Type, ch.Name, err)
+		return models.DeliveryFailed, err.Error()
+	}
+	return models.DeliveryDelivered, ""
+}
+
+// triggerMessage renders a trigger for email and chat channels
+func triggerMessage(t trigger, message string) notifier.Message {
+	subject := fmt.Sprintf("Alert triggered: %s at %.2f", t.alert.Symbol, t.price)
+	what, price := t.alert.Symbol+" traded at", fmt.Sprintf("%.2f", t.price)
+	if t.alert.AlertType == models.AlertDayPnL || t.alert.AlertType == models.AlertDrawdown {
+		subject = fmt.Sprintf("Alert triggered: day P&L at %.2f", t.price)
+		what = "Your P&L for the day was"
+	}
+	next := "The alert has been deactivated."
+	switch policy(t.alert) {
+	case models.PolicyRecurring:
+		next = fmt.Sprintf("It can fire again in %s.", time.Duration(t.alert.CooldownSeconds)*time.Second)
+	case models.PolicyDaily:
+		next = "It can fire again next trading day."
+	}
+	at := t.at.In(market.IST).Format("02 Jan 2006 15:04:05 MST")
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>%s</h2>
+			<p>%s <strong>%s</strong> at %s.</p>
+			<p>%s</p>
+		</body>
+		</html>
+	`, html.EscapeString(message), html.EscapeString(what), price, at, next)
+	return notifier.Message{
+		Subject: subject,
+		Text:    fmt.Sprintf("🔔 %s\n%s %s at %s.\n%s", message, what, price, at, next),
+		HTML:    body,
+	}
+}
+
+// recordDelivery appends a channel's result to the alert event
+func recordDelivery(eventID int64, channel string, channelID int, status, detail string) {
+	delivery := models.AlertDelivery{Channel: channel, ChannelID: channelID, Status: status, Detail: detail, At: time.Now()}
+	if err := db.AddAlertDelivery(eventID, delivery); err != nil {
+		log.Printf("❌ Failed to record %s delivery of alert event %d: %v", channel, eventID, err)
+	}
+}
@@ -0,0 +1,100 @@
+package alertengine
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// TestMain runs the tests against a fresh database in a temporary directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "alertengine")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	db.InitDB()
+	code := m.Run()
+	db.DB.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// ist returns a time on a day in October 2026, IST
+func ist(day, hour, minute int) time.Time {
+	return time.Date(2026, time.October, day, hour, minute, 0, 0, market.IST)
+}
+
+// index adds an alert to the engine's index for the duration of a test
+func index(t *testing.T, a models.Alert) *entry {
+	t.Helper()
+	e, err := newEntry(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	add(e)
+	mu.Unlock()
+	t.Cleanup(func() { Remove(a.ID) })
+	return e
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		value     float64
+		condition string
+		target    float64
+		want      bool
+	}{
+		{101, ">", 100, true},
+		{100, ">", 100, false},
+		{100, ">=", 100, true},
+		{99.99, ">=", 100, false},
+		{99, "<", 100, true},
+		{100, "<", 100, false},
+		{100, "<=", 100, true},
+		{100.01, "<=", 100, false},
+	}
+	for _, tt := range tests {
+		got, err := compare(tt.value, tt.condition, tt.target)
+		if err != nil || got != tt.want {
+			t.Errorf("compare(%v %s %v) = %v (%v), want %v", tt.value, tt.condition, tt.target, got, err, tt.want)
+		}
+	}
+	for _, condition := range []string{"==", "", "=>"} {
+		if _, err := compare(100, condition, 100); err == nil {
+			t.Errorf("compare with condition %q should fail", condition)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert models.Alert
+		price float64
+		want  bool
+	}{
+		{"above defaults to >=", models.Alert{AlertType: models.AlertPriceAbove, TargetValue: 100}, 100, true},
+		{"below defaults to <=", models.Alert{AlertType: models.AlertPriceBelow, TargetValue: 100}, 100, true},
+		{"explicit condition", models.Alert{AlertType: models.AlertPriceAbove, Condition: ">", TargetValue: 100}, 100, false},
+		{"percentage rise", models.Alert{AlertType: models.AlertPercentageChange, Condition: ">=", TargetValue: 2, BaselineValue: 100}, 102, true},
+		{"signed fall does not match a rise", models.Alert{AlertType: models.AlertPercentageChange, Condition: ">=", TargetValue: 2, BaselineValue: 100}, 97, false},
+		{"signed fall", models.Alert{AlertType: models.AlertPercentageChange, Condition: "<=", TargetValue: -2, BaselineValue: 100}, 97, true},
+		{"either direction", models.Alert{AlertType: models.AlertPercentageChange, Condition: ">=", TargetValue: 2, BaselineValue: 100, Direction: models.DirectionEither}, 97, true},
+		{"no baseline yet", models.Alert{AlertType: models.AlertPercentageChange, Condition: ">=", TargetValue: 2}, 1000, false},
+	}
+	for _, tt := range tests {
+		got, err := matches(&entry{alert: tt.alert}, tt.price)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: matches at %v = %v (%v), want %v", tt.name, tt.price, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package alertengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/alertexpr"
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// avgVolumeDays is the number of trading days AVG_VOLUME averages over
+const avgVolumeDays = 20
+
+// dayValues are the day attributes of a symbol on one trading day. They are read from stored
+// candles once per day and then follow quotes in memory; VWAP continues from the stored 1m
+// candles with the price of each quote weighted by the volume traded since the one before.
+type dayValues struct {
+	day                  time.Time
+	open, high, low      float64
+	prevClose, avgVolume float64
+	hasAvgVolume         bool
+	weighted, volume     float64 // VWAP sums
+	lastVolume           int64   // cumulative day volume at lastAt
+	lastAt               time.Time
+}
+
+var (
+	dayMu sync.Mutex
+	days  = make(map[string]*dayValues)
+)
+
+// observeDay folds a quote into its symbol's day attributes and returns them, reading stored
+// candles when the quote starts a new day. It queries the database, so mu must not be held.
+func observeDay(q models.Quote) (dayValues, error) {
+	dayMu.Lock()
+	defer dayMu.Unlock()
+
+	day := candles.BucketStart(q.Timestamp, "1d", market.Calendar(q.Exchange))
+	d, ok := days[q.Symbol]
+	switch {
+	case ok && day.Before(d.day):
+		return dayValues{}, alertexpr.ErrNoData // a late quote of a past day
+	case !ok || day.After(d.day):
+		loaded, err := loadDay(q, day)
+		if err != nil {
+			return dayValues{}, err
+		}
+		d = loaded
+		days[q.Symbol] = d
+	}
+
+	if d.open <= 0 {
+		d.open = q.LTP
+	}
+	d.high = max(d.high, q.LTP)
+	if d.low <= 0 || q.LTP < d.low {
+		d.low = q.LTP
+	}
+	if q.Timestamp.After(d.lastAt) {
+		delta := q.Volume - d.lastVolume
+		if delta < 0 {
+			delta = q.Volume // a new session
+		}
+		d.weighted += q.LTP * float64(delta)
+		d.volume += float64(delta)
+		d.lastVolume, d.lastAt = q.Volume, q.Timestamp
+	}
+	return *d, nil
+}
+
+// loadDay reads the stored day attributes of a quote's symbol for a trading day. Stored candles
+// already include the symbol's latest quote, so only later quotes add volume.
+func loadDay(q models.Quote, day time.Time) (*dayValues, error) {
+	d := &dayValues{day: day, lastVolume: q.Volume, lastAt: q.Timestamp}
+	if latest, ok, err := quotes.Latest(q.Symbol); err != nil {
+		return nil, err
+	} else if ok && !candles.BucketStart(latest.Timestamp, "1d", market.Calendar(latest.Exchange)).Before(day) {
+		d.lastVolume, d.lastAt = latest.Volume, latest.Timestamp
+	}
+
+	c, ok, err := dailyCandle(q.Symbol, day)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		d.open, d.high, d.low = c.Open, c.High, c.Low
+		vwap, ok, err := candles.VWAP(q.Symbol, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			d.weighted, d.volume = vwap*float64(c.Volume), float64(c.Volume)
+		}
+	}
+
+	a := models.Alert{Symbol: q.Symbol, Exchange: q.Exchange, BaselineType: models.BaselinePreviousClose}
+	calendar := calendarOf(a, "")
+	if d.prevClose, err = dailyBaseline(a, calendar, market.LastTradingDay(calendar, q.Timestamp)); err != nil {
+		return nil, err
+	}
+	if d.avgVolume, d.hasAvgVolume, err = candles.AverageVolume(q.Symbol, day, avgVolumeDays); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// quoteEnv supplies expression attributes for one batch of quotes. Everything it reads is loaded
+// up front, before mu is taken, so evaluating expressions never queries the database.
+type quoteEnv struct {
+	quotes map[string]models.Quote
+	days   map[string]dayValues
+	errs   map[string]error
+}
+
+// newQuoteEnv follows the batch's quotes of the given symbols into their day attributes and
+// loads the latest quote and day attributes of each symbol. mu must not be held.
+func newQuoteEnv(batch []models.Quote, symbols map[string]bool) *quoteEnv {
+	env := &quoteEnv{
+		quotes: make(map[string]models.Quote, len(symbols)),
+		days:   make(map[string]dayValues, len(symbols)),
+		errs:   make(map[string]error),
+	}
+	for _, q := range batch {
+		if symbols[q.Symbol] {
+			observeDay(q)
+		}
+	}
+	for symbol := range symbols {
+		q, ok, err := quotes.Latest(symbol)
+		if err != nil {
+			env.errs[symbol] = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+		env.quotes[symbol] = q
+		d, err := observeDay(q)
+		if err != nil {
+			env.errs[symbol] = err
+			continue
+		}
+		env.days[symbol] = d
+	}
+	return env
+}
+
+// Value implements alertexpr.Env; day attributes refer to the trading day of the symbol's latest quote
+func (env *quoteEnv) Value(symbol, attr string) (float64, error) {
+	if err, ok := env.errs[symbol]; ok {
+		return 0, err
+	}
+	q, ok := env.quotes[symbol]
+	if !ok {
+		return 0, alertexpr.ErrNoData
+	}
+	d := env.days[symbol]
+
+	present := func(v float64) (float64, error) {
+		if v <= 0 {
+			return 0, alertexpr.ErrNoData
+		}
+		return v, nil
+	}
+	switch attr {
+	case "LTP":
+		return q.LTP, nil
+	case "BID":
+		return present(q.Bid)
+	case "ASK":
+		return present(q.Ask)
+	case "VOLUME":
+		return float64(q.Volume), nil
+	case "OI":
+		return float64(q.OI), nil
+	case "OPEN":
+		return present(d.open)
+	case "HIGH":
+		return present(d.high)
+	case "LOW":
+		return present(d.low)
+	case "PREV_CLOSE":
+		return present(d.prevClose)
+	case "CHANGE_PCT":
+		prev, err := env.Value(symbol, "PREV_CLOSE")
+		if err != nil {
+			return 0, err
+		}
+		return (q.LTP - prev) / prev * 100, nil
+	case "VWAP":
+		if d.volume <= 0 {
+			return 0, alertexpr.ErrNoData
+		}
+		return d.weighted / d.volume, nil
+	case "AVG_VOLUME":
+		if !d.hasAvgVolume {
+			return 0, alertexpr.ErrNoData
+		}
+		return d.avgVolume, nil
+	}
+	return 0, alertexpr.ErrNoData
+}
+
+// expressionPrice is the price recorded when an expression alert fires: the last price of the
+// alert's own symbol, else of the quote that triggered the evaluation
+func expressionPrice(env *quoteEnv, a models.Alert, q models.Quote) float64 {
+	if v, err := env.Value(a.Symbol, "LTP"); err == nil {
+		return v
+	}
+	return q.LTP
+}
+
+// newEntry prepares an alert for the index. Expression alerts are parsed and indexed under every
+// symbol they read; portfolio alerts are indexed by user instead.
+func newEntry(a models.Alert) (*entry, error) {
+	e := &entry{alert: a, calendar: calendarOf(a, "")}
+	e.rearmsAt, _ = rearmsAt(a, e.calendar)
+	if IsPortfolio(a.AlertType) {
+		return e, nil
+	}
+	e.symbols = []string{a.Symbol}
+	if a.AlertType != models.AlertExpression {
+		return e, nil
+	}
+	expr, err := alertexpr.Parse(a.Expression)
+	if err != nil {
+		return nil, err
+	}
+	e.expr = expr
+	e.exprState = expr.NewState()
+	e.symbols = expr.Symbols(a.Symbol)
+	return e, nil
+}
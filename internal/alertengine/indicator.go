@@ -0,0 +1,316 @@
+package alertengine
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/indicators"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const (
+	// candleSweepInterval is how often candles are closed by the clock when their symbol stops ticking
+	candleSweepInterval = 5 * time.Second
+
+	// candleCloseGrace lets the last quotes of a candle arrive before the clock closes it
+	candleCloseGrace = 2 * time.Second
+
+	// indicatorWarmup is how many periods of history settle EMA, RSI and ATR values
+	indicatorWarmup = 5
+
+	// vwapHistory covers a full session of 1m candles
+	vwapHistory = 500
+)
+
+// indicatorTypes are evaluated when a candle closes rather than on every tick
+var indicatorTypes = map[string]bool{
+	models.AlertSMACross:   true,
+	models.AlertEMACross:   true,
+	models.AlertRSI:        true,
+	models.AlertBollinger:  true,
+	models.AlertSupertrend: true,
+	models.AlertVWAPCross:  true,
+}
+
+// IsIndicator reports whether an alert type is an indicator alert
+func IsIndicator(alertType string) bool {
+	return indicatorTypes[alertType]
+}
+
+// candleKey identifies the candles of a symbol and interval
+type candleKey struct {
+	symbol, interval string
+}
+
+// openCandle is the latest candle seen for a key with indicator alerts
+type openCandle struct {
+	start    time.Time
+	closeAt  time.Time
+	exchange string
+	seenAt   time.Time // wall clock time of its last quote
+	closed   bool
+}
+
+// observation is a quote for a key with indicator alerts
+type observation struct {
+	key      candleKey
+	exchange string
+	at       time.Time
+}
+
+// closedCandle is a candle ready for evaluation
+type closedCandle struct {
+	key candleKey
+	openCandle
+}
+
+var (
+	candleMu    sync.Mutex
+	openCandles = make(map[candleKey]*openCandle)
+)
+
+// closeTime is when a candle closes: the end of its bucket, or the close of the trading day for
+// daily candles
+func closeTime(start time.Time, interval, exchange string) time.Time {
+	if interval == "1d" {
+		if c := market.Close(market.Calendar(exchange), start); c.After(start) {
+			return c
+		}
+	}
+	return start.Add(candles.Intervals[interval])
+}
+
+// observe follows quotes into new candles and returns the candles they closed, in order: a quote
+// in a later bucket closes the open candle of its key
+func observe(list []observation) []closedCandle {
+	var closed []closedCandle
+	now := time.Now()
+
+	candleMu.Lock()
+	defer candleMu.Unlock()
+	for _, o := range list {
+		start := candles.BucketStart(o.at, o.key.interval, market.Calendar(o.exchange))
+		if c, ok := openCandles[o.key]; ok {
+			if !start.After(c.start) {
+				if start.Equal(c.start) {
+					c.seenAt = now
+				}
+				continue
+			}
+			if !c.closed {
+				closed = append(closed, closedCandle{o.key, *c})
+			}
+		}
+		openCandles[o.key] = &openCandle{
+			start:    start,
+			closeAt:  closeTime(start, o.key.interval, o.exchange),
+			exchange: o.exchange,
+			seenAt:   now,
+		}
+	}
+	return closed
+}
+
+// sweepCandles closes candles whose close time has passed without a quote in the next bucket. A
+// candle still receiving quotes after its close time, as in a replay, waits for the next bucket.
+// Keys without indicator alerts are dropped.
+func sweepCandles(now time.Time) {
+	watched := make(map[candleKey]bool)
+	mu.RLock()
+	for _, e := range byID {
+		if IsIndicator(e.alert.AlertType) {
+			watched[candleKey{e.alert.Symbol, e.alert.Interval}] = true
+		}
+	}
+	mu.RUnlock()
+
+	var closed []closedCandle
+	candleMu.Lock()
+	for key, c := range openCandles {
+		switch {
+		case !watched[key]:
+			delete(openCandles, key)
+		case !c.closed && !now.Before(c.closeAt.Add(candleCloseGrace)) && c.seenAt.Before(c.closeAt):
+			c.closed = true
+			closed = append(closed, closedCandle{key, *c})
+		}
+	}
+	candleMu.Unlock()
+
+	for _, c := range closed {
+		evaluateCandle(c)
+	}
+}
+
+// evaluateCandle checks the indicator alerts of a closed candle. Alerts fire at the candle's close
+// time and price.
+func evaluateCandle(c closedCandle) {
+	key := c.key
+	if MarketHoursOnly() && key.interval != "1d" && !market.IsOpen(market.Calendar(c.exchange), c.start) {
+		return
+	}
+
+	var alerts []models.Alert
+	mu.RLock()
+	for _, e := range bySymbol[key.symbol] {
+		if IsIndicator(e.alert.AlertType) && e.alert.Interval == key.interval && armed(e, c.closeAt) {
+			alerts = append(alerts, e.alert)
+		}
+	}
+	mu.RUnlock()
+	if len(alerts) == 0 {
+		return
+	}
+
+	n := 0
+	for _, a := range alerts {
+		n = max(n, history(a))
+	}
+	list, err := candles.Recent(key.symbol, key.interval, c.start.Add(time.Millisecond), n)
+	if err != nil {
+		log.Printf("❌ Failed to load %s %s candles for indicator alerts: %v", key.symbol, key.interval, err)
+		return
+	}
+	if len(list) < 2 || !list[len(list)-1].Start.Equal(c.start) {
+		return
+	}
+	price := list[len(list)-1].Close
+
+	var fired []trigger
+	mu.Lock()
+	for _, a := range alerts {
+		matched, values := signal(a, list)
+		if !matched {
+			continue
+		}
+		// The alert may have fired or changed while the candles were read
+		e, ok := byID[a.ID]
+		if !ok || e.alert.Interval != key.interval || !armed(e, c.closeAt) {
+			continue
+		}
+		fired = append(fired, trigger{alert: e.alert, price: price, at: c.closeAt, indicators: values})
+		settle(e, price, c.closeAt)
+	}
+	mu.Unlock()
+	queue(fired)
+}
+
+// history is the number of candles an alert's indicators need
+func history(a models.Alert) int {
+	if a.AlertType == models.AlertVWAPCross {
+		return vwapHistory
+	}
+	return indicatorWarmup*max(a.Period, a.SlowPeriod) + 2
+}
+
+// signal evaluates an indicator alert on candles ending with the closed one, returning the
+// indicator values at that candle
+func signal(a models.Alert, list []models.Candle) (bool, map[string]float64) {
+	closes := indicators.Closes(list)
+	last := len(list) - 1
+	up := condition(a) == ">" || condition(a) == ">="
+	values := map[string]float64{"close": closes[last]}
+	put := func(name string, v float64) {
+		if !math.IsNaN(v) {
+			values[name] = v
+		}
+	}
+
+	switch a.AlertType {
+	case models.AlertSMACross, models.AlertEMACross:
+		average, name := indicators.SMA, "sma"
+		if a.AlertType == models.AlertEMACross {
+			average, name = indicators.EMA, "ema"
+		}
+		fast, slow := average(closes, a.Period), average(closes, a.SlowPeriod)
+		put(fmt.Sprintf("%s_%d", name, a.Period), fast[last])
+		put(fmt.Sprintf("%s_%d", name, a.SlowPeriod), slow[last])
+		return crossed(fast, slow, last, up), values
+	case models.AlertRSI:
+		rsi := indicators.RSI(closes, a.Period)
+		put(fmt.Sprintf("rsi_%d", a.Period), rsi[last])
+		if math.IsNaN(rsi[last]) {
+			return false, values
+		}
+		matched, _ := compare(rsi[last], condition(a), a.TargetValue)
+		return matched, values
+	case models.AlertBollinger:
+		middle, upper, lower := indicators.Bollinger(closes, a.Period, a.Multiplier)
+		put("bb_upper", upper[last])
+		put("bb_middle", middle[last])
+		put("bb_lower", lower[last])
+		if up {
+			return crossed(closes, upper, last, true), values
+		}
+		return crossed(closes, lower, last, false), values
+	case models.AlertSupertrend:
+		line, trendUp := indicators.Supertrend(list, a.Period, a.Multiplier)
+		put("supertrend", line[last])
+		if math.IsNaN(line[last-1]) {
+			return false, values
+		}
+		return trendUp[last] == up && trendUp[last-1] != up, values
+	case models.AlertVWAPCross:
+		vwap := indicators.VWAP(list)
+		put("vwap", vwap[last])
+		// Only a cross within one session counts; the VWAP restarts each day
+		calendar := market.Calendar(a.Exchange)
+		if !candles.BucketStart(list[last-1].Start, "1d", calendar).Equal(candles.BucketStart(list[last].Start, "1d", calendar)) {
+			return false, values
+		}
+		return crossed(closes, vwap, last, up), values
+	}
+	return false, values
+}
+
+// crossed reports whether a moved from at or below b to above it at candle i, or from at or above
+// to below when !up
+func crossed(a, b []float64, i int, up bool) bool {
+	for _, v := range []float64{a[i-1], b[i-1], a[i], b[i]} {
+		if math.IsNaN(v) {
+			return false
+		}
+	}
+	if up {
+		return a[i-1] <= b[i-1] && a[i] > b[i]
+	}
+	return a[i-1] >= b[i-1] && a[i] < b[i]
+}
+
+// describeIndicator is the default message of an indicator alert, e.g. "RELIANCE 5m EMA 9 crossed above EMA 21"
+func describeIndicator(a models.Alert) string {
+	above := condition(a) == ">" || condition(a) == ">="
+	side := "below"
+	if above {
+		side = "above"
+	}
+	var what string
+	switch a.AlertType {
+	case models.AlertSMACross:
+		what = fmt.Sprintf("SMA %d crossed %s SMA %d", a.Period, side, a.SlowPeriod)
+	case models.AlertEMACross:
+		what = fmt.Sprintf("EMA %d crossed %s EMA %d", a.Period, side, a.SlowPeriod)
+	case models.AlertRSI:
+		what = fmt.Sprintf("RSI %d %s %g", a.Period, condition(a), a.TargetValue)
+	case models.AlertBollinger:
+		band := "lower"
+		if above {
+			band = "upper"
+		}
+		what = fmt.Sprintf("closed %s the %s Bollinger band (%d, %g)", side, band, a.Period, a.Multiplier)
+	case models.AlertSupertrend:
+		trend := "down"
+		if above {
+			trend = "up"
+		}
+		what = fmt.Sprintf("Supertrend (%d, %g) turned %s", a.Period, a.Multiplier, trend)
+	case models.AlertVWAPCross:
+		what = fmt.Sprintf("closed %s VWAP", side)
+	}
+	return fmt.Sprintf("%s %s %s", a.Symbol, a.Interval, what)
+}
@@ -0,0 +1,83 @@
+package alertengine
+
+import (
+	"log"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/alertsync"
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// expireInterval is how often alerts past their valid_until are deactivated
+const expireInterval = time.Minute
+
+// policy is the alert's trigger policy, defaulting to ONCE
+func policy(a models.Alert) string {
+	if a.TriggerPolicy == "" {
+		return models.PolicyOnce
+	}
+	return a.TriggerPolicy
+}
+
+// rearmsAt returns when a recurring or daily alert that has fired can fire again: after the
+// cooldown, or at the start of the next trading day on the calendar
+func rearmsAt(a models.Alert, calendar string) (time.Time, bool) {
+	if a.TriggeredAt == nil {
+		return time.Time{}, false
+	}
+	switch policy(a) {
+	case models.PolicyRecurring:
+		return a.TriggeredAt.Add(time.Duration(a.CooldownSeconds) * time.Second), true
+	case models.PolicyDaily:
+		return market.NextTradingDay(calendar, *a.TriggeredAt), true
+	}
+	return time.Time{}, false
+}
+
+// armed reports whether an indexed alert may fire at t: it has not expired and is not waiting
+// out its cooldown or trading day, as worked out when it was indexed or last fired
+func armed(e *entry, t time.Time) bool {
+	if e.alert.ValidUntil != nil && !t.Before(*e.alert.ValidUntil) {
+		return false
+	}
+	return !t.Before(e.rearmsAt)
+}
+
+// SetState fills in an alert's state, and when it re-arms, as of now
+func SetState(a *models.Alert, now time.Time) {
+	a.RearmsAt = nil
+	switch {
+	case !a.IsActive && a.TriggeredAt != nil && policy(*a) == models.PolicyOnce:
+		a.State = models.StateTriggered
+	case a.ValidUntil != nil && !now.Before(*a.ValidUntil):
+		a.State = models.StateExpired
+	case !a.IsActive:
+		a.State = models.StateDisabled
+	default:
+		a.State = models.StateArmed
+		if at, ok := rearmsAt(*a, calendarOf(*a, "")); ok && now.Before(at) {
+			a.RearmsAt = &at
+			a.State = models.StateTriggered
+			if policy(*a) == models.PolicyRecurring {
+				a.State = models.StateCoolingDown
+			}
+		}
+	}
+}
+
+// Expire deactivates alerts past their valid_until and removes them from the index and from Kite
+func Expire() {
+	expired, err := db.ExpireAlerts(time.Now())
+	if err != nil {
+		log.Printf("❌ Failed to expire alerts: %v", err)
+	}
+	for _, a := range expired {
+		log.Printf("⌛ Alert %d (%s) expired", a.ID, a.Symbol)
+		Remove(a.ID)
+		if err := alertsync.QueuePush(a.UserID, a.ID); err != nil {
+			log.Printf("Warning: Failed to queue Kite sync for alert %d: %v", a.ID, err)
+		}
+	}
+}
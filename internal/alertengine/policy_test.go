@@ -0,0 +1,82 @@
+package alertengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+func TestArmedRecurring(t *testing.T) {
+	e := index(t, models.Alert{ID: 101, Symbol: "INFY", Exchange: "NSE", AlertType: models.AlertPriceAbove, TargetValue: 100,
+		IsActive: true, TriggerPolicy: models.PolicyRecurring, CooldownSeconds: 300})
+	fired := ist(16, 10, 0)
+	if !armed(e, fired) {
+		t.Fatal("a recurring alert that never fired should be armed")
+	}
+
+	mu.Lock()
+	settle(e, 101, fired)
+	mu.Unlock()
+	if _, indexed := byID[101]; !indexed {
+		t.Fatal("a recurring alert stays indexed after firing")
+	}
+	if armed(e, fired.Add(299*time.Second)) {
+		t.Error("armed during the cooldown")
+	}
+	if !armed(e, fired.Add(300*time.Second)) {
+		t.Error("not armed once the cooldown has passed")
+	}
+	if e.alert.TriggerCount != 1 || e.alert.TriggeredPrice != 101 {
+		t.Errorf("trigger count %d at %v, want 1 at 101", e.alert.TriggerCount, e.alert.TriggeredPrice)
+	}
+}
+
+func TestArmedDaily(t *testing.T) {
+	e := index(t, models.Alert{ID: 102, Symbol: "INFY", Exchange: "NSE", AlertType: models.AlertPriceAbove, TargetValue: 100,
+		IsActive: true, TriggerPolicy: models.PolicyDaily})
+
+	// Fired on Friday 16 October, it re-arms on Monday, the next trading day
+	mu.Lock()
+	settle(e, 101, ist(16, 10, 0))
+	mu.Unlock()
+	for _, at := range []time.Time{ist(16, 15, 0), ist(17, 10, 0), ist(18, 23, 59)} {
+		if armed(e, at) {
+			t.Errorf("armed at %v, before the next trading day", at)
+		}
+	}
+	if !armed(e, ist(19, 9, 15)) {
+		t.Error("not armed on the next trading day")
+	}
+}
+
+func TestArmedAfterReload(t *testing.T) {
+	// The re-arm time is worked out from the stored trigger when the alert is indexed again
+	fired := ist(16, 10, 0)
+	e := index(t, models.Alert{ID: 103, Symbol: "INFY", Exchange: "NSE", AlertType: models.AlertPriceAbove, TargetValue: 100,
+		IsActive: true, TriggerPolicy: models.PolicyRecurring, CooldownSeconds: 60, TriggeredAt: &fired})
+	if armed(e, fired.Add(30*time.Second)) || !armed(e, fired.Add(time.Minute)) {
+		t.Error("a reloaded alert should keep waiting out its cooldown")
+	}
+}
+
+func TestArmedValidUntil(t *testing.T) {
+	until := ist(16, 12, 0)
+	e := index(t, models.Alert{ID: 104, Symbol: "INFY", Exchange: "NSE", AlertType: models.AlertPriceAbove, TargetValue: 100,
+		IsActive: true, ValidUntil: &until})
+	if !armed(e, until.Add(-time.Second)) || armed(e, until) {
+		t.Error("an alert should be armed until valid_until only")
+	}
+}
+
+func TestSettleOnceRemoves(t *testing.T) {
+	e := index(t, models.Alert{ID: 105, Symbol: "INFY", Exchange: "NSE", AlertType: models.AlertPriceAbove, TargetValue: 100, IsActive: true})
+	mu.Lock()
+	settle(e, 101, ist(16, 10, 0))
+	_, indexed := byID[105]
+	_, bySym := bySymbol["INFY"][105]
+	mu.Unlock()
+	if indexed || bySym {
+		t.Error("a ONCE alert should leave the index when it fires")
+	}
+}
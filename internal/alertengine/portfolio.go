@@ -0,0 +1,277 @@
+package alertengine
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+)
+
+// portfolioTypes are evaluated against a user's positions rather than the quotes of one symbol
+var portfolioTypes = map[string]bool{
+	models.AlertDayPnL:       true,
+	models.AlertPositionLoss: true,
+	models.AlertDrawdown:     true,
+}
+
+// IsPortfolio reports whether an alert type is a portfolio alert
+func IsPortfolio(alertType string) bool {
+	return portfolioTypes[alertType]
+}
+
+// book is a user's positions, marked at the latest quotes, and the P&L their trading day started from
+type book struct {
+	positions map[string]*models.Position
+	day       string  // trading day (YYYY-MM-DD) the book was loaded for
+	dayStart  float64 // realized plus unrealized P&L at the previous trading day's close
+}
+
+var (
+	bookMu sync.Mutex
+	books  = make(map[int]*book) // by user, for users with portfolio alerts
+
+	// byUser indexes portfolio alerts by user; guarded by mu like bySymbol
+	byUser = make(map[int]map[int]*entry)
+)
+
+// loadBook reads a user's positions as of now and the P&L at the close of the trading day before
+// the current one, from its snapshot when there is one
+func loadBook(userID int, now time.Time) (*book, error) {
+	day := market.LastTradingDay(market.NSE, now)
+	positions, err := portfolio.Positions(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	previous, _, err := portfolio.AsOf(userID, market.PreviousTradingDay(market.NSE, day))
+	if err != nil {
+		return nil, err
+	}
+
+	b := &book{positions: make(map[string]*models.Position, len(positions)), day: day.Format(market.DateFormat)}
+	for i := range positions {
+		b.positions[positions[i].Symbol] = &positions[i]
+	}
+	realized, unrealized := portfolio.Totals(previous)
+	b.dayStart = realized + unrealized
+	return b, nil
+}
+
+// loadBooks reloads the books of the given users and drops the others
+func loadBooks(userIDs []int) {
+	loaded := make(map[int]*book, len(userIDs))
+	for _, userID := range userIDs {
+		b, err := loadBook(userID, time.Now())
+		if err != nil {
+			log.Printf("❌ Failed to load positions of user %d for portfolio alerts: %v", userID, err)
+			continue
+		}
+		loaded[userID] = b
+	}
+
+	bookMu.Lock()
+	books = loaded
+	bookMu.Unlock()
+}
+
+// portfolioUsers lists the users with indexed portfolio alerts
+func portfolioUsers() []int {
+	mu.RLock()
+	defer mu.RUnlock()
+	userIDs := make([]int, 0, len(byUser))
+	for userID := range byUser {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// RefreshPortfolio re-reads a user's positions after a trade, when they have portfolio alerts
+func RefreshPortfolio(userID int) {
+	mu.RLock()
+	_, ok := byUser[userID]
+	mu.RUnlock()
+	if !ok {
+		return
+	}
+	b, err := loadBook(userID, time.Now())
+	if err != nil {
+		log.Printf("❌ Failed to load positions of user %d for portfolio alerts: %v", userID, err)
+		return
+	}
+	bookMu.Lock()
+	books[userID] = b
+	bookMu.Unlock()
+}
+
+// dayPnL is the realized plus unrealized P&L of the book since its trading day started
+func (b *book) dayPnL() float64 {
+	var total float64
+	for _, p := range b.positions {
+		total += p.RealizedPnL + p.UnrealizedPnL
+	}
+	return total - b.dayStart
+}
+
+// worstLoss is the open position, or the given one, with the largest unrealized loss in percent
+// of its cost
+func worstLoss(positions map[string]models.Position, symbol string) (models.Position, float64, bool) {
+	var worst models.Position
+	loss, found := math.Inf(-1), false
+	for _, p := range positions {
+		if p.NetQuantity == 0 || p.AvgCost <= 0 || (symbol != "" && p.Symbol != symbol) {
+			continue
+		}
+		pct := -p.UnrealizedPnL / (math.Abs(p.NetQuantity) * p.AvgCost) * 100
+		if pct > loss {
+			worst, loss, found = p, pct, true
+		}
+	}
+	return worst, loss, found
+}
+
+// bookState is what the portfolio alerts of one user are evaluated against
+type bookState struct {
+	day       string
+	pnl       float64
+	quote     models.Quote // last quote that moved the book
+	positions map[string]models.Position
+}
+
+// evaluatePortfolios marks the books of users holding the quoted symbols and checks their
+// portfolio alerts. A book left over from an earlier trading day is reloaded first.
+func evaluatePortfolios(batch []models.Quote, hoursOnly bool) []trigger {
+	touched := make(map[int]models.Quote)
+	bookMu.Lock()
+	for _, q := range batch {
+		for userID, b := range books {
+			if p, ok := b.positions[q.Symbol]; ok {
+				p.MarkPrice = q.LTP
+				p.UnrealizedPnL = (p.MarkPrice - p.AvgCost) * p.NetQuantity
+				touched[userID] = q
+			}
+		}
+	}
+	stale := make(map[int]bool)
+	today := market.LastTradingDay(market.NSE, time.Now()).Format(market.DateFormat)
+	for userID := range touched {
+		if books[userID].day != today {
+			stale[userID] = true
+		}
+	}
+	bookMu.Unlock()
+	if len(touched) == 0 {
+		return nil
+	}
+
+	for userID := range stale {
+		RefreshPortfolio(userID)
+	}
+
+	// Evaluate on copies, so alerts see one consistent view of each book
+	states := make(map[int]bookState, len(touched))
+	bookMu.Lock()
+	for userID, q := range touched {
+		b, ok := books[userID]
+		if !ok {
+			continue
+		}
+		state := bookState{day: b.day, pnl: b.dayPnL(), quote: q, positions: make(map[string]models.Position, len(b.positions))}
+		for symbol, p := range b.positions {
+			state.positions[symbol] = *p
+		}
+		states[userID] = state
+	}
+	bookMu.Unlock()
+
+	var fired []trigger
+	peaked := make(map[int]models.Alert) // drawdown alerts whose peak moved
+	mu.Lock()
+	for userID, state := range states {
+		q := state.quote
+		if hoursOnly && !market.IsOpen(market.Calendar(q.Exchange), q.Timestamp) {
+			continue
+		}
+		for _, e := range byUser[userID] {
+			if e.alert.AlertType == models.AlertDrawdown && follow(e, state) {
+				peaked[e.alert.ID] = e.alert
+			}
+			if !armed(e, q.Timestamp) {
+				continue
+			}
+			t, matched := checkPortfolio(e.alert, state)
+			if !matched {
+				continue
+			}
+			t.at = q.Timestamp
+			fired = append(fired, t)
+			settle(e, t.price, q.Timestamp)
+		}
+	}
+	mu.Unlock()
+
+	for _, a := range peaked {
+		if err := db.SetAlertPeak(a.ID, a.PeakPnL, a.PeakDate); err != nil {
+			log.Printf("❌ Failed to store P&L peak of alert %d: %v", a.ID, err)
+		}
+	}
+	return fired
+}
+
+// follow moves a drawdown alert's peak with the day's P&L and reports whether it changed. Each
+// trading day starts from a peak of 0, the P&L at the previous close. Expects mu to be held for
+// writing.
+func follow(e *entry, state bookState) bool {
+	a := &e.alert
+	if a.PeakDate != state.day {
+		a.PeakPnL, a.PeakDate = math.Max(state.pnl, 0), state.day
+		return true
+	}
+	if state.pnl > a.PeakPnL {
+		a.PeakPnL = state.pnl
+		return true
+	}
+	return false
+}
+
+// checkPortfolio evaluates a portfolio alert. The trigger price is the day's P&L, or the mark of
+// the losing position; a POSITION_LOSS alert on any position fires with that position's symbol.
+func checkPortfolio(a models.Alert, state bookState) (trigger, bool) {
+	t := trigger{alert: a, price: state.pnl}
+	var matched bool
+	switch a.AlertType {
+	case models.AlertDayPnL:
+		matched, _ = compare(state.pnl, condition(a), a.TargetValue)
+		t.indicators = map[string]float64{"day_pnl": state.pnl}
+	case models.AlertDrawdown:
+		drawdown := a.PeakPnL - state.pnl
+		matched = drawdown >= a.TargetValue
+		t.indicators = map[string]float64{"day_pnl": state.pnl, "peak_pnl": a.PeakPnL, "drawdown": drawdown}
+	case models.AlertPositionLoss:
+		p, loss, ok := worstLoss(state.positions, a.Symbol)
+		if !ok {
+			return t, false
+		}
+		matched = loss >= a.TargetValue
+		t.alert.Symbol = p.Symbol
+		t.price = p.MarkPrice
+		t.indicators = map[string]float64{"loss_pct": loss, "unrealized_pnl": p.UnrealizedPnL, "avg_cost": p.AvgCost, "net_quantity": p.NetQuantity}
+	}
+	return t, matched
+}
+
+// describePortfolio is the default message of a portfolio alert, e.g. "Day P&L -5234.50 reached
+// the limit of <= -5000"
+func describePortfolio(a models.Alert, values map[string]float64) string {
+	switch a.AlertType {
+	case models.AlertDayPnL:
+		return fmt.Sprintf("Day P&L %.2f reached the limit of %s %g", values["day_pnl"], condition(a), a.TargetValue)
+	case models.AlertDrawdown:
+		return fmt.Sprintf("Day P&L %.2f is %.2f below its peak of %.2f", values["day_pnl"], values["drawdown"], values["peak_pnl"])
+	}
+	return fmt.Sprintf("%s is down %.2f%% on its cost, beyond the %g%% loss limit", a.Symbol, values["loss_pct"], a.TargetValue)
+}
@@ -0,0 +1,80 @@
+package alertengine
+
+import (
+	"fmt"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// trail moves an indexed trailing stop with a price and reports whether its trail changed and
+// whether the price reached the stop. Before activation the stop waits for the activation price;
+// the quote that activates it starts the trail and cannot fire it. Expects mu to be held for
+// writing.
+func trail(e *entry, price float64) (changed, hit bool) {
+	a := &e.alert
+	long := a.TrailSide != models.TrailShort
+	if a.TrailExtreme <= 0 {
+		if a.ActivationPrice > 0 && (long && price < a.ActivationPrice || !long && price > a.ActivationPrice) {
+			return false, false
+		}
+		a.TrailExtreme, a.TrailLevel = price, trailLevel(*a, price)
+		return true, false
+	}
+
+	if long && price > a.TrailExtreme || !long && price < a.TrailExtreme {
+		a.TrailExtreme, a.TrailLevel = price, trailLevel(*a, price)
+		changed = true
+	}
+	if long {
+		return changed, price <= a.TrailLevel
+	}
+	return changed, price >= a.TrailLevel
+}
+
+// trailLevel is the stop for a running high or low
+func trailLevel(a models.Alert, extreme float64) float64 {
+	offset := a.TrailValue
+	if a.TrailType != models.TrailAmount {
+		offset = extreme * a.TrailValue / 100
+	}
+	if a.TrailSide == models.TrailShort {
+		return extreme + offset
+	}
+	return extreme - offset
+}
+
+// resetTrail restarts a recurring or daily trailing stop that fired, which trails again from its
+// next activation. Expects mu to be held for writing.
+func resetTrail(e *entry) {
+	e.alert.TrailExtreme, e.alert.TrailLevel = 0, 0
+}
+
+// trailValues are recorded with a trailing stop's trigger
+func trailValues(a models.Alert) map[string]float64 {
+	extreme := "running_high"
+	if a.TrailSide == models.TrailShort {
+		extreme = "running_low"
+	}
+	return map[string]float64{extreme: a.TrailExtreme, "trail_level": a.TrailLevel}
+}
+
+// KeepsTrail reports whether an edited trailing stop keeps its stored trail: only while the
+// symbol, side, trail and activation price are unchanged
+func KeepsTrail(stored, edited models.Alert) bool {
+	return stored.AlertType == models.AlertTrailingStop && edited.AlertType == models.AlertTrailingStop &&
+		stored.Symbol == edited.Symbol && stored.TrailSide == edited.TrailSide && stored.TrailType == edited.TrailType &&
+		stored.TrailValue == edited.TrailValue && stored.ActivationPrice == edited.ActivationPrice
+}
+
+// describeTrail is the default message of a trailing stop, e.g. "RELIANCE fell to its trailing
+// stop 2450 (2% below the high of 2500)"
+func describeTrail(a models.Alert) string {
+	offset := fmt.Sprintf("%g", a.TrailValue)
+	if a.TrailType != models.TrailAmount {
+		offset += "%"
+	}
+	if a.TrailSide == models.TrailShort {
+		return fmt.Sprintf("%s rose to its trailing stop %.2f (%s above the low of %.2f)", a.Symbol, a.TrailLevel, offset, a.TrailExtreme)
+	}
+	return fmt.Sprintf("%s fell to its trailing stop %.2f (%s below the high of %.2f)", a.Symbol, a.TrailLevel, offset, a.TrailExtreme)
+}
@@ -0,0 +1,62 @@
+package alertengine
+
+import (
+	"testing"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+type trailStep struct {
+	price          float64
+	changed, hit   bool
+	extreme, level float64
+}
+
+func runTrail(t *testing.T, a models.Alert, steps []trailStep) *entry {
+	t.Helper()
+	e := &entry{alert: a}
+	for i, s := range steps {
+		changed, hit := trail(e, s.price)
+		if changed != s.changed || hit != s.hit || e.alert.TrailExtreme != s.extreme || e.alert.TrailLevel != s.level {
+			t.Errorf("step %d at %v: changed %v hit %v, trail %v/%v; want %v %v, %v/%v", i, s.price,
+				changed, hit, e.alert.TrailExtreme, e.alert.TrailLevel, s.changed, s.hit, s.extreme, s.level)
+		}
+	}
+	return e
+}
+
+func TestTrailLongPercent(t *testing.T) {
+	runTrail(t, models.Alert{AlertType: models.AlertTrailingStop, TrailSide: models.TrailLong, TrailType: models.TrailPercent, TrailValue: 10}, []trailStep{
+		{price: 100, changed: true, extreme: 100, level: 90}, // the first quote starts the trail
+		{price: 120, changed: true, extreme: 120, level: 108},
+		{price: 110, extreme: 120, level: 108}, // pullbacks do not move the trail
+		{price: 108, hit: true, extreme: 120, level: 108},
+	})
+}
+
+func TestTrailShortAmountWithActivation(t *testing.T) {
+	e := runTrail(t, models.Alert{AlertType: models.AlertTrailingStop, TrailSide: models.TrailShort, TrailType: models.TrailAmount, TrailValue: 5, ActivationPrice: 90}, []trailStep{
+		{price: 95}, // waiting for the price to fall to 90
+		{price: 89, changed: true, extreme: 89, level: 94},
+		{price: 85, changed: true, extreme: 85, level: 90},
+		{price: 88, extreme: 85, level: 90},
+		{price: 90, hit: true, extreme: 85, level: 90},
+	})
+
+	resetTrail(e)
+	if e.alert.TrailExtreme != 0 || e.alert.TrailLevel != 0 {
+		t.Error("resetTrail should clear the trail")
+	}
+	if changed, hit := trail(e, 95); changed || hit {
+		t.Error("a reset trail waits for the activation price again")
+	}
+}
+
+func TestTrailActivatingQuoteDoesNotFire(t *testing.T) {
+	// The quote that activates a stop starts the trail even if it is already below the level
+	runTrail(t, models.Alert{AlertType: models.AlertTrailingStop, TrailSide: models.TrailLong, TrailType: models.TrailAmount, TrailValue: 1, ActivationPrice: 100}, []trailStep{
+		{price: 99},
+		{price: 100, changed: true, extreme: 100, level: 99},
+		{price: 99, hit: true, extreme: 100, level: 99},
+	})
+}
@@ -0,0 +1,211 @@
+package alertexpr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNoData is returned when an attribute has no value yet, e.g. a symbol without quotes
+var ErrNoData = errors.New("no data")
+
+// Env supplies attribute values; symbol is already resolved to the alert's own symbol when omitted
+type Env interface {
+	Value(symbol, attr string) (float64, error)
+}
+
+// State keeps the previous sides of each CROSSES operator between evaluations of one alert
+type State struct {
+	prev []crossSides
+}
+
+type crossSides struct {
+	left, right float64
+	ok          bool
+}
+
+// NewState returns empty evaluation state for the expression
+func (e *Expr) NewState() *State {
+	return &State{prev: make([]crossSides, e.crosses)}
+}
+
+// Eval evaluates the expression for an alert on symbol own. A CROSSES operator is true when its
+// sides crossed since the previous evaluation with the same state, so the first evaluation never
+// crosses. Every operand is evaluated, keeping cross state current; the error of any of them,
+// such as ErrNoData, is returned instead of a result.
+func (e *Expr) Eval(env Env, own string, st *State) (bool, error) {
+	if st == nil || len(st.prev) != e.crosses {
+		st = e.NewState()
+	}
+	ctx := &evalContext{env: env, own: own, state: st}
+	result := e.root.evalBool(ctx)
+	if ctx.err != nil {
+		return false, ctx.err
+	}
+	return result, nil
+}
+
+type evalContext struct {
+	env   Env
+	own   string
+	state *State
+	err   error // first error, evaluation carries on to update cross state
+}
+
+func (c *evalContext) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// node is a parsed expression; boolean nodes implement evalBool, numeric ones evalNumber
+type node interface {
+	boolean() bool
+	evalBool(c *evalContext) bool
+	evalNumber(c *evalContext) float64
+}
+
+// errType is reported if a node is evaluated as the wrong type, which the parser's type checks
+// should rule out; it fails the evaluation rather than the engine
+var errType = errors.New("internal error: condition and number mixed up")
+
+// numeric and condition supply the type of a node and the evaluation it does not have
+type numeric struct{}
+
+func (numeric) boolean() bool { return false }
+func (numeric) evalBool(c *evalContext) bool {
+	c.fail(errType)
+	return false
+}
+
+type condition struct{}
+
+func (condition) boolean() bool { return true }
+func (condition) evalNumber(c *evalContext) float64 {
+	c.fail(errType)
+	return math.NaN()
+}
+
+type numberNode struct {
+	numeric
+	value float64
+}
+
+func (n *numberNode) evalNumber(*evalContext) float64 { return n.value }
+
+type attrNode struct {
+	numeric
+	symbol string // empty for the alert's own symbol
+	attr   string
+}
+
+func (n *attrNode) evalNumber(c *evalContext) float64 {
+	symbol := n.symbol
+	if symbol == "" {
+		symbol = c.own
+	}
+	v, err := c.env.Value(symbol, n.attr)
+	if err != nil {
+		c.fail(fmt.Errorf("%s %s: %w", symbol, n.attr, err))
+		return math.NaN()
+	}
+	return v
+}
+
+type negNode struct {
+	numeric
+	operand node
+}
+
+func (n *negNode) evalNumber(c *evalContext) float64 { return -n.operand.evalNumber(c) }
+
+type arithNode struct {
+	numeric
+	op          byte
+	left, right node
+}
+
+func (n *arithNode) evalNumber(c *evalContext) float64 {
+	l, r := n.left.evalNumber(c), n.right.evalNumber(c)
+	switch n.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	}
+	if r == 0 {
+		c.fail(errors.New("division by zero"))
+		return math.NaN()
+	}
+	return l / r
+}
+
+type compareNode struct {
+	condition
+	op          string
+	left, right node
+}
+
+func (n *compareNode) evalBool(c *evalContext) bool {
+	l, r := n.left.evalNumber(c), n.right.evalNumber(c)
+	switch n.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	}
+	return l != r
+}
+
+type crossNode struct {
+	condition
+	above       bool
+	left, right node
+	id          int // index of the operator's state
+}
+
+func (n *crossNode) evalBool(c *evalContext) bool {
+	l, r := n.left.evalNumber(c), n.right.evalNumber(c)
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return false
+	}
+	prev := c.state.prev[n.id]
+	c.state.prev[n.id] = crossSides{left: l, right: r, ok: true}
+	if !prev.ok {
+		return false
+	}
+	if n.above {
+		return prev.left <= prev.right && l > r
+	}
+	return prev.left >= prev.right && l < r
+}
+
+type logicalNode struct {
+	condition
+	and         bool
+	left, right node
+}
+
+func (n *logicalNode) evalBool(c *evalContext) bool {
+	// No short-circuit: both sides run so every CROSSES operator sees each evaluation
+	l, r := n.left.evalBool(c), n.right.evalBool(c)
+	if n.and {
+		return l && r
+	}
+	return l || r
+}
+
+type notNode struct {
+	condition
+	operand node
+}
+
+func (n *notNode) evalBool(c *evalContext) bool { return !n.operand.evalBool(c) }
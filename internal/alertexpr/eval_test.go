@@ -0,0 +1,165 @@
+package alertexpr
+
+import (
+	"errors"
+	"testing"
+)
+
+// env maps "SYMBOL ATTR" to a value; missing values are ErrNoData
+type env map[string]float64
+
+func (e env) Value(symbol, attr string) (float64, error) {
+	v, ok := e[symbol+" "+attr]
+	if !ok {
+		return 0, ErrNoData
+	}
+	return v, nil
+}
+
+// eval parses and evaluates src once for an alert on INFY
+func eval(t *testing.T, src string, values env) (bool, error) {
+	t.Helper()
+	e, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	return e.Eval(values, "INFY", e.NewState())
+}
+
+func TestEvalPrecedenceAndAssociativity(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"2 + 3 * 4 == 14", true},
+		{"(2 + 3) * 4 == 20", true},
+		{"10 - 4 - 3 == 3", true},
+		{"100 / 10 / 2 == 5", true},
+		{"8 / 2 * 2 == 8", true},
+		{"-2 * 3 == -6", true},
+		{"- -2 == 2", true},
+		{"2 - -3 == 5", true},
+		{"1 + 2 > 2 + 0", true},
+		{"1 > 2 AND 1 > 2 OR 2 > 1", true},
+		{"1 > 2 AND (1 > 2 OR 2 > 1)", false},
+		{"2 > 1 OR 2 > 1 AND 1 > 2", true},
+		{"NOT 1 > 2 AND 1 > 2", false},
+		{"NOT (1 > 2 AND 1 > 2)", true},
+		{"NOT NOT 2 > 1", true},
+		{"!(1 > 2) && 2 > 1 || 1 > 2", true},
+		{"1 != 2", true},
+		{"2 <= 2 and 2 >= 2 and 1 < 2", true},
+	}
+	for _, tt := range tests {
+		got, err := eval(t, tt.src, nil)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v (%v), want %v", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestEvalAttributes(t *testing.T) {
+	values := env{"INFY LTP": 1510, "INFY VWAP": 1500, "NIFTY 50 LTP": 21400, "INFY VOLUME": 300, "INFY AVG_VOLUME": 100}
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"LTP > VWAP", true},
+		{"INFY LTP > 1500", true},
+		{`"NIFTY 50" LTP < 21500 AND LTP > 1500`, true},
+		{"VOLUME > 2 * AVG_VOLUME", true},
+		{"VOLUME > 3 * AVG_VOLUME", false},
+	}
+	for _, tt := range tests {
+		got, err := eval(t, tt.src, values)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v (%v), want %v", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	values := env{"INFY LTP": 1510}
+	if _, err := eval(t, "LTP > 1 OR OI > 0", values); !errors.Is(err, ErrNoData) {
+		t.Errorf("err = %v, want ErrNoData", err)
+	}
+	if _, err := eval(t, "LTP / (LTP - 1510) > 1", values); err == nil || err.Error() != "division by zero" {
+		t.Errorf("err = %v, want division by zero", err)
+	}
+}
+
+func TestEvalTypeMismatchFailsInsteadOfPanicking(t *testing.T) {
+	// Trees the parser rejects, built by hand
+	for _, root := range []node{
+		&logicalNode{and: true, left: &numberNode{value: 1}, right: &compareNode{op: "<", left: &numberNode{value: 1}, right: &numberNode{value: 2}}},
+		&compareNode{op: "<", left: &notNode{operand: &compareNode{op: "<", left: &numberNode{value: 1}, right: &numberNode{value: 2}}}, right: &numberNode{value: 2}},
+	} {
+		e := &Expr{root: root}
+		if _, err := e.Eval(nil, "INFY", nil); !errors.Is(err, errType) {
+			t.Errorf("err = %v, want %v", err, errType)
+		}
+	}
+}
+
+func TestEvalCrosses(t *testing.T) {
+	e, err := Parse("LTP CROSSES ABOVE VWAP OR LTP CROSSES BELOW 90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := e.NewState()
+	steps := []struct {
+		ltp  float64
+		want bool
+	}{
+		{99, false},  // first evaluation never crosses
+		{101, true},  // crossed above the VWAP
+		{102, false}, // still above
+		{100, false}, // touching is not crossing
+		{101, true},  // from equal to above counts
+		{95, false},
+		{89, true}, // crossed below 90, the second operator's own state
+		{80, false},
+	}
+	for i, step := range steps {
+		got, err := e.Eval(env{"INFY LTP": step.ltp, "INFY VWAP": 100}, "INFY", st)
+		if err != nil || got != step.want {
+			t.Errorf("step %d (LTP %v) = %v (%v), want %v", i, step.ltp, got, err, step.want)
+		}
+	}
+}
+
+func TestEvalCrossesKeepsStateAcrossErrors(t *testing.T) {
+	e, err := Parse("VOLUME > 0 AND LTP CROSSES ABOVE 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := e.NewState()
+	// VOLUME is missing, but the cross still records LTP below 100
+	if _, err := e.Eval(env{"INFY LTP": 99}, "INFY", st); !errors.Is(err, ErrNoData) {
+		t.Fatalf("err = %v, want ErrNoData", err)
+	}
+	if got, err := e.Eval(env{"INFY LTP": 101, "INFY VOLUME": 5}, "INFY", st); err != nil || !got {
+		t.Errorf("got %v (%v), want a cross above", got, err)
+	}
+
+	// A missing side leaves the previous sides in place
+	st = e.NewState()
+	e.Eval(env{"INFY LTP": 99, "INFY VOLUME": 5}, "INFY", st)
+	e.Eval(env{"INFY VOLUME": 5}, "INFY", st)
+	if got, err := e.Eval(env{"INFY LTP": 101, "INFY VOLUME": 5}, "INFY", st); err != nil || !got {
+		t.Errorf("got %v (%v), want a cross above after a tick without LTP", got, err)
+	}
+}
+
+func TestEvalCrossesWithoutState(t *testing.T) {
+	e, err := Parse("LTP CROSSES ABOVE 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without state every evaluation is a first one
+	for _, ltp := range []float64{99, 101} {
+		if got, err := e.Eval(env{"INFY LTP": ltp}, "INFY", nil); err != nil || got {
+			t.Errorf("LTP %v = %v (%v), want false without state", ltp, got, err)
+		}
+	}
+}
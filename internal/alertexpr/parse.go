@@ -0,0 +1,437 @@
+// Package alertexpr parses and evaluates the conditions of expression alerts, such as
+//
+//	NIFTY LTP < 21500 AND BANKNIFTY LTP < 46000
+//	LTP CROSSES ABOVE VWAP AND VOLUME > 2 * AVG_VOLUME
+//
+// An expression combines instrument attributes and numbers with arithmetic (+ - * /),
+// comparisons (< <= > >= == !=), CROSSES ABOVE / CROSSES BELOW, and AND, OR, NOT (also &&, ||, !).
+// An attribute may be preceded by a symbol, bare or in double quotes ("NIFTY 50" LTP); without
+// one it refers to the alert's own symbol. Expressions are parsed into a tree and never executed
+// as code.
+package alertexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxLength bounds the source of an expression
+const maxLength = 1000
+
+// AttributeNames lists the instrument attributes an expression can use, described in Attributes
+var AttributeNames = []string{"LTP", "BID", "ASK", "VOLUME", "OI", "OPEN", "HIGH", "LOW", "PREV_CLOSE", "CHANGE_PCT", "VWAP", "AVG_VOLUME"}
+
+// Attributes describes each attribute
+var Attributes = map[string]string{
+	"LTP":        "last traded price",
+	"BID":        "best bid",
+	"ASK":        "best ask",
+	"VOLUME":     "traded volume for the day",
+	"OI":         "open interest",
+	"OPEN":       "day open",
+	"HIGH":       "day high",
+	"LOW":        "day low",
+	"PREV_CLOSE": "previous trading day's close",
+	"CHANGE_PCT": "percentage change from the previous close",
+	"VWAP":       "volume-weighted average price for the day",
+	"AVG_VOLUME": "average daily volume over the last 20 trading days",
+}
+
+// SyntaxError is an invalid expression; Pos is the byte offset of the problem
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+// Expr is a parsed expression
+type Expr struct {
+	src     string
+	root    node
+	symbols []string // qualified symbols, in order of first use
+	own     bool     // whether an attribute refers to the alert's own symbol
+	crosses int      // number of CROSSES operators, each keeping state between evaluations
+}
+
+// Parse parses an expression, which must be a condition (true or false) rather than a number
+func Parse(src string) (*Expr, error) {
+	if len(src) > maxLength {
+		return nil, &SyntaxError{Pos: maxLength, Msg: fmt.Sprintf("expression is longer than %d characters", maxLength)}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, expr: &Expr{src: src}}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+	}
+	if !root.boolean() {
+		return nil, &SyntaxError{Pos: 0, Msg: "expression must be a condition, e.g. LTP > 100"}
+	}
+	p.expr.root = root
+	return p.expr, nil
+}
+
+// String returns the expression's source
+func (e *Expr) String() string { return e.src }
+
+// Symbols returns the symbols an expression reads, the alert's own symbol first when used
+func (e *Expr) Symbols(own string) []string {
+	var symbols []string
+	ownUsed := e.own && own != ""
+	if ownUsed {
+		symbols = append(symbols, own)
+	}
+	for _, s := range e.symbols {
+		if !ownUsed || s != own {
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
+
+// UsesOwnSymbol reports whether an attribute has no symbol and so refers to the alert's own symbol
+func (e *Expr) UsesOwnSymbol() bool { return e.own }
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string // upper-cased for identifiers
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &SyntaxError{Pos: start, Msg: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: n, pos: start})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: strings.ToUpper(src[start:i]), pos: start})
+		case c == '"':
+			start := i
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: start, Msg: "unterminated symbol"}
+			}
+			symbol := strings.ToUpper(strings.TrimSpace(src[i+1 : i+1+end]))
+			if symbol == "" {
+				return nil, &SyntaxError{Pos: start, Msg: "empty symbol"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: symbol, pos: start})
+			i += end + 2
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "==", "!=", "&&", "||", "<", ">", "+", "-", "*", "/", "!"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// Parser: a recursive descent over
+//
+//	or      = and { (OR | ||) and }
+//	and     = not { (AND | &&) not }
+//	not     = (NOT | !) not | compare
+//	compare = sum [ (< | <= | > | >= | == | !=) sum | CROSSES (ABOVE | BELOW) sum ]
+//	sum     = product { (+ | -) product }
+//	product = unary { (* | /) unary }
+//	unary   = - unary | primary
+//	primary = number | [ symbol ] attribute | ( or )
+
+type parser struct {
+	tokens []token
+	i      int
+	expr   *Expr
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(texts ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return t, false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) or() (node, error) {
+	return p.logical(p.and, "OR", "||")
+}
+
+func (p *parser) and() (node, error) {
+	return p.logical(p.not, "AND", "&&")
+}
+
+func (p *parser) logical(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireBool(t, left, right); err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: ops[0] == "AND", left: left, right: right}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	if t, ok := p.accept("NOT", "!"); ok {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireBool(t, operand); err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := p.accept("CROSSES"); ok {
+		dir, ok := p.accept("ABOVE", "BELOW")
+		if !ok {
+			return nil, &SyntaxError{Pos: dir.pos, Msg: "expected ABOVE or BELOW after CROSSES"}
+		}
+		right, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireNumber(t, left, right); err != nil {
+			return nil, err
+		}
+		n := &crossNode{above: dir.text == "ABOVE", left: left, right: right, id: p.expr.crosses}
+		p.expr.crosses++
+		return n, nil
+	}
+
+	t, ok := p.accept("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if err := requireNumber(t, left, right); err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind == tokOp && strings.ContainsAny(next.text, "<>=") {
+		return nil, &SyntaxError{Pos: next.pos, Msg: "comparisons cannot be chained, combine them with AND"}
+	}
+	return &compareNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arithmetic(p.unary, "*", "/")
+}
+
+func (p *parser) arithmetic(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireNumber(t, left, right); err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: t.text[0], left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if t, ok := p.accept("-"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if err := requireNumber(t, operand); err != nil {
+			return nil, err
+		}
+		return &negNode{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &numberNode{value: t.num}, nil
+	case tokLParen:
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\", found %s", closing)}
+		}
+		return inner, nil
+	case tokIdent, tokString:
+		if _, ok := Attributes[t.text]; ok && t.kind == tokIdent {
+			p.expr.own = true
+			return &attrNode{attr: t.text}, nil
+		}
+		// Keywords are not symbols unless quoted, so "OR OR LTP" is not OR's LTP
+		if isKeyword(t.text) && t.kind == tokIdent {
+			return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+		}
+		attr := p.peek()
+		if _, ok := Attributes[attr.text]; ok && attr.kind == tokIdent {
+			p.next()
+			p.addSymbol(t.text)
+			return &attrNode{symbol: t.text, attr: attr.text}, nil
+		}
+		return nil, &SyntaxError{Pos: attr.pos, Msg: fmt.Sprintf("expected an attribute after symbol %s, one of %s", t.text, attributeList())}
+	case tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	}
+	return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s", t)}
+}
+
+func (p *parser) addSymbol(symbol string) {
+	for _, s := range p.expr.symbols {
+		if s == symbol {
+			return
+		}
+	}
+	p.expr.symbols = append(p.expr.symbols, symbol)
+}
+
+func isKeyword(text string) bool {
+	switch text {
+	case "AND", "OR", "NOT", "CROSSES", "ABOVE", "BELOW":
+		return true
+	}
+	return false
+}
+
+func attributeList() string {
+	return strings.Join(AttributeNames, ", ")
+}
+
+func requireBool(op token, operands ...node) error {
+	for _, n := range operands {
+		if !n.boolean() {
+			return &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("%s needs conditions on both sides, not numbers", op.text)}
+		}
+	}
+	return nil
+}
+
+func requireNumber(op token, operands ...node) error {
+	for _, n := range operands {
+		if n.boolean() {
+			return &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("%s needs numbers, not conditions", op.text)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package alertexpr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"", 0, "unexpected end of expression"},
+		{"LTP >", 5, "unexpected end of expression"},
+		{"LTP > 100 )", 10, `unexpected ")"`},
+		{"(LTP > 100", 10, `expected ")", found end of expression`},
+		{"LTP > 1..2", 6, `invalid number "1..2"`},
+		{"LTP # 3", 4, `unexpected character '#'`},
+		{`"NIFTY 50 LTP > 1`, 0, "unterminated symbol"},
+		{`" " LTP > 1`, 0, "empty symbol"},
+		{"LTP CROSSES 100", 12, "expected ABOVE or BELOW after CROSSES"},
+		{"LTP CROSSES", 11, "expected ABOVE or BELOW after CROSSES"},
+		{"1 < LTP < 3", 8, "comparisons cannot be chained"},
+		{"LTP > 1 AND", 11, "unexpected end of expression"},
+		{"LTP > 1 OR OR LTP < 2", 11, `unexpected "OR"`},
+		{strings.Repeat("1", maxLength+1), maxLength, "longer than 1000 characters"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) = %v, want a syntax error", tt.src, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos || !strings.Contains(syntaxErr.Msg, tt.msg) {
+			t.Errorf("Parse(%q) = %q at %d, want %q at %d", tt.src, syntaxErr.Msg, syntaxErr.Pos, tt.msg, tt.pos)
+		}
+	}
+}
+
+func TestSyntaxErrorPositionIsOneBased(t *testing.T) {
+	_, err := Parse("LTP >")
+	if err == nil || err.Error() != "unexpected end of expression at position 6" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestParseTypeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"LTP", 0, "expression must be a condition"},
+		{"LTP + 1", 0, "expression must be a condition"},
+		{"(LTP > 1)", -1, ""},
+		{"LTP > 1 + (LTP > 2)", 8, "+ needs numbers"},
+		{"(LTP > 1) * 2 > 0", 10, "* needs numbers"},
+		{"-(LTP > 1) < 0", 0, "- needs numbers"},
+		{"LTP AND LTP > 1", 4, "AND needs conditions"},
+		{"LTP > 1 || VWAP", 8, "|| needs conditions"},
+		{"NOT LTP", 0, "NOT needs conditions"},
+		{"!VWAP", 0, "! needs conditions"},
+		{"(LTP > 1) CROSSES ABOVE 2", 10, "CROSSES needs numbers"},
+		{"(LTP > 1) == (VWAP > 1)", 10, "== needs numbers"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if tt.pos < 0 {
+			if err != nil {
+				t.Errorf("Parse(%q) = %v", tt.src, err)
+			}
+			continue
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Pos != tt.pos || !strings.Contains(syntaxErr.Msg, tt.msg) {
+			t.Errorf("Parse(%q) = %v, want %q at %d", tt.src, err, tt.msg, tt.pos)
+		}
+	}
+}
+
+func TestParseUnknownIdentifiers(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"PRICE > 100", 6, "expected an attribute after symbol PRICE"},
+		{"INFY PRICE > 100", 5, "expected an attribute after symbol INFY"},
+		{`"NIFTY 50" > 100`, 11, "expected an attribute after symbol NIFTY 50"},
+		{"SMA(20) > 100", 3, "expected an attribute after symbol SMA"},
+		{"MAX(LTP, VWAP) > 100", 7, "unexpected character ','"},
+		{"AND LTP > 1", 0, `unexpected "AND"`},
+		{`"AND" LTP > 1`, -1, ""},
+		{"AND > 1", 0, `unexpected "AND"`},
+		{"LTP > ABOVE", 6, `unexpected "ABOVE"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if tt.pos < 0 {
+			if err != nil {
+				t.Errorf("Parse(%q) = %v", tt.src, err)
+			}
+			continue
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Pos != tt.pos || !strings.Contains(syntaxErr.Msg, tt.msg) {
+			t.Errorf("Parse(%q) = %v, want %q at %d", tt.src, err, tt.msg, tt.pos)
+		}
+	}
+}
+
+func TestParseSymbols(t *testing.T) {
+	e, err := Parse(`nifty ltp < 21500 and "NIFTY BANK" LTP < 46000 AND LTP > VWAP AND NIFTY LOW > 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.UsesOwnSymbol() {
+		t.Error("LTP without a symbol should refer to the alert's own symbol")
+	}
+	if got, want := e.Symbols("INFY"), []string{"INFY", "NIFTY", "NIFTY BANK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols = %v, want %v", got, want)
+	}
+	if got, want := e.Symbols("NIFTY"), []string{"NIFTY", "NIFTY BANK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols on an alert for NIFTY = %v, want %v", got, want)
+	}
+
+	e, err = Parse("NIFTY LTP > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.UsesOwnSymbol() {
+		t.Error("a qualified attribute does not use the alert's own symbol")
+	}
+	if got, want := e.Symbols("INFY"), []string{"NIFTY"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,341 @@
+package alertsync
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/kite"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/outbox"
+)
+
+// defaultReconcileInterval is used when ALERT_RECONCILE_INTERVAL is unset or invalid
+const defaultReconcileInterval = 15 * time.Minute
+
+// Outbox operations
+const (
+	OpPushAlert   = "alert.push"
+	OpDeleteAlert = "alert.delete"
+)
+
+// loginRetryDelay is how long queued calls wait for a user without a Kite session
+const loginRetryDelay = 5 * time.Minute
+
+// deletePayload is the outbox payload of OpDeleteAlert
+type deletePayload struct {
+	KiteUUID string `json:"kite_uuid"`
+}
+
+// Report counts the repairs made by a reconcile run
+type Report struct {
+	Created   int `json:"created"`   // active alerts missing on Kite
+	Modified  int `json:"modified"`  // Kite copies that differed from the stored alert
+	Deleted   int `json:"deleted"`   // Kite copies of inactive alerts
+	Disabled  int `json:"disabled"`  // alerts disabled on Kite and deactivated here
+	Failed    int `json:"failed"`    // repairs that failed
+	Untracked int `json:"untracked"` // Kite alerts not linked to any stored alert, left alone
+}
+
+// Start registers the alert operations with the outbox and starts the reconcile job
+func Start() {
+	outbox.Register(OpPushAlert, func(item models.OutboxItem) error {
+		err := Push(item.AlertID)
+		if err == sql.ErrNoRows {
+			// Deleted since it was queued; the delete operation cleans up Kite
+			return nil
+		}
+		return classify(err)
+	})
+	outbox.Register(OpDeleteAlert, func(item models.OutboxItem) error {
+		var payload deletePayload
+		if err := json.Unmarshal([]byte(item.Payload), &payload); err != nil {
+			return outbox.Permanent(err)
+		}
+		return classify(Remove(item.UserID, payload.KiteUUID))
+	})
+	StartReconciler()
+}
+
+// QueuePush queues bringing Kite in line with a stored alert.
+// Alerts Kite cannot represent and that have no Kite copy to remove are marked local without queueing.
+func QueuePush(userID, alertID int) error {
+	alert, err := db.GetAlert(alertID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && localOnly(alert) {
+		record(alert.ID, "", models.SyncLocal, nil)
+		return nil
+	}
+	return outbox.Enqueue(userID, OpPushAlert, alertID, nil)
+}
+
+// localOnly reports whether an alert has nothing to sync: Kite cannot represent it and holds no copy of it
+func localOnly(alert models.Alert) bool {
+	return !kite.Supported(alert) && alert.KiteUUID == ""
+}
+
+// settledStatus is the sync status of an alert once Kite matches it
+func settledStatus(alert models.Alert) string {
+	if !kite.Supported(alert) {
+		return models.SyncLocal
+	}
+	return models.SyncSynced
+}
+
+// QueueRemove queues deleting the Kite copy of a deleted alert
+func QueueRemove(userID, alertID int, kiteUUID string) error {
+	if kiteUUID == "" {
+		return nil
+	}
+	return outbox.Enqueue(userID, OpDeleteAlert, alertID, deletePayload{KiteUUID: kiteUUID})
+}
+
+// classify tells the outbox whether a failed call is worth retrying
+func classify(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == kite.ErrLoginRequired || kite.IsTokenError(err):
+		return outbox.Defer(err, loginRetryDelay)
+	case err == kite.ErrNotConfigured || isUnsupported(err) || !kite.IsRetryable(err):
+		return outbox.Permanent(err)
+	}
+	return err
+}
+
+func isUnsupported(err error) bool {
+	_, ok := err.(*unsupportedError)
+	return ok
+}
+
+// Push makes Kite match a stored alert: active alerts are created or modified, inactive ones removed.
+// The outcome is recorded on the alert; without a Kite session the alert stays pending.
+func Push(alertID int) error {
+	alert, err := db.GetAlert(alertID)
+	if err != nil {
+		return err
+	}
+	if (!alert.IsActive || !kite.Supported(alert)) && alert.KiteUUID == "" {
+		record(alert.ID, "", settledStatus(alert), nil)
+		return nil
+	}
+	service, err := kite.ForUser(alert.UserID)
+	if err != nil {
+		record(alert.ID, alert.KiteUUID, models.SyncPending, err)
+		return err
+	}
+	return push(service, alert)
+}
+
+// Remove deletes the Kite copy of an alert that was deleted locally
+func Remove(userID int, kiteUUID string) error {
+	if kiteUUID == "" {
+		return nil
+	}
+	service, err := kite.ForUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := service.DeleteAlerts(kiteUUID); err != nil && !kite.IsNotFound(err) {
+		kite.CheckTokenError(userID, err)
+		return err
+	}
+	return nil
+}
+
+func push(service *kite.KiteService, alert models.Alert) error {
+	if !alert.IsActive || !kite.Supported(alert) {
+		// Inactive and local only alerts must not exist on Kite
+		if alert.KiteUUID != "" {
+			if err := service.DeleteAlerts(alert.KiteUUID); err != nil && !kite.IsNotFound(err) {
+				return fail(alert, err)
+			}
+		}
+		record(alert.ID, "", settledStatus(alert), nil)
+		return nil
+	}
+	params, err := kite.AlertParamsFromModel(alert)
+	if err != nil {
+		record(alert.ID, alert.KiteUUID, models.SyncFailed, err)
+		return &unsupportedError{err}
+	}
+
+	if alert.KiteUUID != "" {
+		_, err := service.ModifyAlert(alert.KiteUUID, params)
+		if err == nil {
+			record(alert.ID, alert.KiteUUID, models.SyncSynced, nil)
+			return nil
+		}
+		if !kite.IsNotFound(err) {
+			return fail(alert, err)
+		}
+		log.Printf("⚠️  Alert %d is gone from Kite, creating it again", alert.ID)
+	}
+
+	remote, err := service.CreateAlert(params)
+	if err != nil {
+		alert.KiteUUID = ""
+		return fail(alert, err)
+	}
+	if err := db.SetAlertSync(alert.ID, remote.UUID, models.SyncSynced, ""); err == sql.ErrNoRows {
+		// Deleted while it was being created, so nothing else will remove the Kite copy
+		log.Printf("⚠️  Alert %d was deleted during sync, removing it from Kite", alert.ID)
+		if err := service.DeleteAlerts(remote.UUID); err != nil && !kite.IsNotFound(err) {
+			return err
+		}
+	} else if err != nil {
+		log.Printf("Failed to record sync state for alert %d: %v", alert.ID, err)
+	}
+	return nil
+}
+
+// unsupportedError is returned for alerts Kite would reject, so retrying cannot help
+type unsupportedError struct{ err error }
+
+func (e *unsupportedError) Error() string { return e.err.Error() }
+
+// fail records a failed Kite call; token errors leave the alert pending until the user logs in again
+func fail(alert models.Alert, err error) error {
+	log.Printf("❌ Failed to sync alert %d to Kite: %v", alert.ID, err)
+	status := models.SyncFailed
+	if kite.IsTokenError(err) {
+		kite.CheckTokenError(alert.UserID, err)
+		status = models.SyncPending
+	}
+	record(alert.ID, alert.KiteUUID, status, err)
+	return err
+}
+
+func record(alertID int, kiteUUID, status string, syncErr error) {
+	message := ""
+	if syncErr != nil {
+		message = syncErr.Error()
+	}
+	if err := db.SetAlertSync(alertID, kiteUUID, status, message); err != nil {
+		log.Printf("Failed to record sync state for alert %d: %v", alertID, err)
+	}
+}
+
+// Reconcile compares a user's stored alerts with Kite and repairs drift in either direction.
+// Stored alerts are the source of truth for definitions; alerts disabled on Kite are deactivated here.
+func Reconcile(userID int) (Report, error) {
+	var report Report
+	service, err := kite.ForUser(userID)
+	if err != nil {
+		return report, err
+	}
+	remote, err := service.GetAlertStatus()
+	if err != nil {
+		kite.CheckTokenError(userID, err)
+		return report, err
+	}
+	alerts, err := db.GetUserAlerts(userID)
+	if err != nil {
+		return report, err
+	}
+
+	byUUID := make(map[string]kite.Alert, len(remote))
+	for _, r := range remote {
+		if r.Status != "deleted" {
+			byUUID[r.UUID] = r
+		}
+	}
+
+	for _, alert := range alerts {
+		r, exists := byUUID[alert.KiteUUID]
+		if alert.KiteUUID == "" {
+			exists = false
+		}
+		delete(byUUID, alert.KiteUUID)
+
+		params, paramsErr := kite.AlertParamsFromModel(alert)
+		settled := settledStatus(alert)
+		switch {
+		case alert.IsActive && exists && r.Status == "disabled":
+			log.Printf("🔄 Alert %d was disabled on Kite, deactivating it", alert.ID)
+			if err := db.SetAlertActive(alert.ID, false); err != nil {
+				log.Printf("Failed to deactivate alert %d: %v", alert.ID, err)
+				report.Failed++
+				continue
+			}
+			record(alert.ID, alert.KiteUUID, models.SyncSynced, nil)
+			report.Disabled++
+		case alert.IsActive && paramsErr == nil && !exists:
+			alert.KiteUUID = ""
+			count(&report.Created, &report.Failed, push(service, alert))
+		case alert.IsActive && paramsErr == nil && !matches(r, params):
+			count(&report.Modified, &report.Failed, push(service, alert))
+		case exists && (!alert.IsActive || paramsErr != nil):
+			count(&report.Deleted, &report.Failed, push(service, alert))
+		case !exists && alert.KiteUUID != "":
+			// Inactive or local only alert whose Kite copy is already gone
+			record(alert.ID, "", settled, nil)
+		case paramsErr != nil && alert.IsActive && settled == models.SyncSynced:
+			if alert.SyncStatus != models.SyncFailed {
+				record(alert.ID, "", models.SyncFailed, paramsErr)
+			}
+		case alert.SyncStatus != settled:
+			record(alert.ID, alert.KiteUUID, settled, nil)
+		}
+	}
+	report.Untracked = len(byUUID)
+
+	return report, nil
+}
+
+// matches reports whether a Kite alert has the definition we would send
+func matches(r kite.Alert, p kite.AlertParams) bool {
+	return r.Name == p.Name && r.LHSExchange == p.LHSExchange && r.LHSTradingSymbol == p.LHSTradingSymbol &&
+		r.LHSAttribute == p.LHSAttribute && r.Operator == p.Operator && r.RHSType == p.RHSType && r.RHSConstant == p.RHSConstant
+}
+
+func count(ok, failed *int, err error) {
+	if err != nil {
+		*failed++
+	} else {
+		*ok++
+	}
+}
+
+// ReconcileInterval reads ALERT_RECONCILE_INTERVAL (a Go duration such as "15m")
+func ReconcileInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("ALERT_RECONCILE_INTERVAL")); err == nil && d > 0 {
+		return d
+	}
+	return defaultReconcileInterval
+}
+
+// StartReconciler starts a goroutine that periodically reconciles every user with a Kite session
+func StartReconciler() {
+	interval := ReconcileInterval()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			userIDs, err := db.UserIDsWithAlerts()
+			if err != nil {
+				log.Printf("❌ Failed to list users for alert reconcile: %v", err)
+				continue
+			}
+			for _, userID := range userIDs {
+				report, err := Reconcile(userID)
+				if err == kite.ErrLoginRequired || err == kite.ErrNotConfigured {
+					continue
+				}
+				if err != nil {
+					log.Printf("❌ Alert reconcile failed for user %d: %v", userID, err)
+					continue
+				}
+				if report != (Report{}) {
+					log.Printf("🔄 Reconciled alerts for user %d: %+v", userID, report)
+				}
+			}
+		}
+	}()
+	log.Printf("🔄 Kite alert reconcile every %v", interval)
+}
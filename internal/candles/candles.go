@@ -0,0 +1,289 @@
+package candles
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// Intervals lists the supported candle intervals and their lengths
+var Intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// IntervalOrder is the order in which intervals are built and listed
+var IntervalOrder = []string{"1m", "5m", "15m", "1h", "1d"}
+
+var (
+	// Last cumulative day volume seen per symbol, used to turn quote volume into per-candle volume
+	volumeMu   sync.Mutex
+	lastVolume = make(map[string]int64)
+)
+
+// BucketStart returns the start of the interval bucket that contains t. Days start at IST midnight;
+// intraday buckets are counted from the calendar's session open, so NSE hourly candles start at
+// 09:15, 10:15 and so on, as on the exchange.
+func BucketStart(t time.Time, interval, calendar string) time.Time {
+	local := t.In(market.IST)
+	y, m, d := local.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, market.IST)
+	length := Intervals[interval]
+	if length >= 24*time.Hour {
+		return midnight
+	}
+	open := market.Open(calendar, midnight)
+	n := local.Sub(open) / length
+	if local.Before(open.Add(n * length)) {
+		n-- // before the open, round down rather than towards it
+	}
+	if start := open.Add(n * length); start.After(midnight) {
+		return start
+	}
+	return midnight
+}
+
+// Start subscribes to stored quotes and keeps candles for every interval up to date
+func Start() {
+	quotes.OnSave(func(batch []models.Quote) {
+		if err := Apply(batch); err != nil {
+			log.Printf("❌ Failed to update candles: %v", err)
+		}
+	})
+}
+
+// Apply folds quotes into the stored candles of every interval, leaving imported candles alone.
+// Quote volume is the cumulative traded volume for the day, so each quote adds the
+// increase since the previous quote of the same symbol. A quote arriving late still becomes
+// the open or close when it is the earliest or latest of its bucket.
+func Apply(batch []models.Quote) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO candles (symbol, interval, bucket_start, open, high, low, close, volume, oi, first_ts, last_ts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, interval, bucket_start) DO UPDATE SET
+			open = CASE WHEN excluded.first_ts < first_ts THEN excluded.open ELSE open END,
+			first_ts = MIN(first_ts, excluded.first_ts),
+			high = MAX(high, excluded.high),
+			low = MIN(low, excluded.low),
+			close = CASE WHEN excluded.last_ts >= last_ts THEN excluded.close ELSE close END,
+			oi = CASE WHEN excluded.last_ts >= last_ts THEN excluded.oi ELSE oi END,
+			last_ts = MAX(last_ts, excluded.last_ts),
+			volume = volume + excluded.volume
+		WHERE imported = 0`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare candle upsert: %v", err)
+	}
+	defer stmt.Close()
+
+	calendars := make(map[string]string)
+	volumeMu.Lock()
+	defer volumeMu.Unlock()
+	for _, q := range batch {
+		delta := volumeDelta(lastVolume, q)
+		calendar, ok := calendars[q.Symbol+"|"+q.Exchange]
+		if !ok {
+			calendar = CalendarFor(q.Symbol, q.Exchange)
+			calendars[q.Symbol+"|"+q.Exchange] = calendar
+		}
+		for _, interval := range IntervalOrder {
+			start := BucketStart(q.Timestamp, interval, calendar)
+			if _, err := stmt.Exec(q.Symbol, interval, start.UnixMilli(), q.LTP, q.LTP, q.LTP, q.LTP, delta, q.OI, q.Timestamp.UnixMilli(), q.Timestamp.UnixMilli()); err != nil {
+				return fmt.Errorf("failed to upsert %s candle for %s: %v", interval, q.Symbol, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// volumeDelta returns the volume traded since the previous quote and records the new total.
+// A drop in cumulative volume means a new session started.
+func volumeDelta(seen map[string]int64, q models.Quote) int64 {
+	prev, ok := seen[q.Symbol]
+	seen[q.Symbol] = q.Volume
+	switch {
+	case !ok:
+		return 0
+	case q.Volume < prev:
+		return q.Volume
+	}
+	return q.Volume - prev
+}
+
+// Rebuild recomputes the candles of every interval for a symbol from raw quotes in [from, to),
+// bucketed on the calendar's sessions. Candles whose buckets overlap the range are replaced from
+// all of their quotes, even those past to; imported candles are kept.
+func Rebuild(symbol, calendar string, from, to time.Time) (int, error) {
+	// The day bucket holding the end of the range is the longest one to rebuild
+	end := BucketStart(to.Add(-time.Millisecond), "1d", calendar).AddDate(0, 0, 1)
+	raw, err := quotes.Range(symbol, BucketStart(from, "1d", calendar), end)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, interval := range IntervalOrder {
+		first := BucketStart(from, interval, calendar)
+		if _, err := tx.Exec("DELETE FROM candles WHERE symbol = ? AND interval = ? AND bucket_start >= ? AND bucket_start < ? AND imported = 0",
+			symbol, interval, first.UnixMilli(), to.UnixMilli()); err != nil {
+			return 0, fmt.Errorf("failed to clear candles: %v", err)
+		}
+		for _, b := range aggregate(raw, interval, calendar) {
+			if b.Start.Before(first) || !b.Start.Before(to) {
+				continue
+			}
+			// Only imported candles are left in the range, and they win over quotes
+			res, err := tx.Exec("INSERT INTO candles (symbol, interval, bucket_start, open, high, low, close, volume, oi, first_ts, last_ts) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (symbol, interval, bucket_start) DO NOTHING",
+				symbol, interval, b.Start.UnixMilli(), b.Open, b.High, b.Low, b.Close, b.Volume, b.OI, b.first.UnixMilli(), b.last.UnixMilli())
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert candle: %v", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				count++
+			}
+		}
+	}
+	return count, tx.Commit()
+}
+
+// bucket is a candle built from quotes along with the times of its first and last quote
+type bucket struct {
+	models.Candle
+	first, last time.Time
+}
+
+// Aggregate builds candles of one interval from quotes sorted by time, bucketed on the calendar's sessions
+func Aggregate(raw []models.Quote, interval, calendar string) []models.Candle {
+	var result []models.Candle
+	for _, b := range aggregate(raw, interval, calendar) {
+		result = append(result, b.Candle)
+	}
+	return result
+}
+
+func aggregate(raw []models.Quote, interval, calendar string) []bucket {
+	var result []bucket
+	seen := make(map[string]int64)
+	for _, q := range raw {
+		delta := volumeDelta(seen, q)
+		start := BucketStart(q.Timestamp, interval, calendar)
+		if n := len(result); n > 0 && result[n-1].Start.Equal(start) {
+			b := &result[n-1]
+			b.High = max(b.High, q.LTP)
+			b.Low = min(b.Low, q.LTP)
+			b.Close = q.LTP
+			b.Volume += delta
+			b.OI = q.OI
+			b.last = q.Timestamp
+			continue
+		}
+		result = append(result, bucket{
+			Candle: models.Candle{
+				Symbol:   q.Symbol,
+				Interval: interval,
+				Start:    start,
+				Open:     q.LTP,
+				High:     q.LTP,
+				Low:      q.LTP,
+				Close:    q.LTP,
+				Volume:   delta,
+				OI:       q.OI,
+			},
+			first: q.Timestamp,
+			last:  q.Timestamp,
+		})
+	}
+	return result
+}
+
+// VWAP returns the volume-weighted average of the typical price, (high + low + close) / 3, over
+// the 1m candles starting in [from, to); it reports false when they have no volume
+func VWAP(symbol string, from, to time.Time) (float64, bool, error) {
+	var weighted, volume float64
+	err := db.DB.QueryRow(
+		"SELECT COALESCE(SUM((high + low + close) / 3 * volume), 0), COALESCE(SUM(volume), 0) FROM candles WHERE symbol = ? AND interval = '1m' AND bucket_start >= ? AND bucket_start < ?",
+		symbol, from.UnixMilli(), to.UnixMilli(),
+	).Scan(&weighted, &volume)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query VWAP: %v", err)
+	}
+	if volume <= 0 {
+		return 0, false, nil
+	}
+	return weighted / volume, true, nil
+}
+
+// AverageVolume returns the average volume of the last n daily candles starting before a time;
+// it reports false when there are none
+func AverageVolume(symbol string, before time.Time, n int) (float64, bool, error) {
+	var avg float64
+	var count int
+	err := db.DB.QueryRow(
+		"SELECT COALESCE(AVG(volume), 0), COUNT(*) FROM (SELECT volume FROM candles WHERE symbol = ? AND interval = '1d' AND bucket_start < ? ORDER BY bucket_start DESC LIMIT ?)",
+		symbol, before.UnixMilli(), n,
+	).Scan(&avg, &count)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query average volume: %v", err)
+	}
+	return avg, count > 0, nil
+}
+
+// Recent returns up to n stored candles for a symbol and interval starting before a time, oldest first
+func Recent(symbol, interval string, before time.Time, n int) ([]models.Candle, error) {
+	rows, err := db.DB.Query(
+		"SELECT bucket_start, open, high, low, close, volume, oi FROM (SELECT * FROM candles WHERE symbol = ? AND interval = ? AND bucket_start < ? ORDER BY bucket_start DESC LIMIT ?) ORDER BY bucket_start",
+		symbol, interval, before.UnixMilli(), n,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candles: %v", err)
+	}
+	return scanCandles(rows, symbol, interval)
+}
+
+// Query returns stored candles for a symbol and interval with start in [from, to), oldest first
+func Query(symbol, interval string, from, to time.Time) ([]models.Candle, error) {
+	rows, err := db.DB.Query(
+		"SELECT bucket_start, open, high, low, close, volume, oi FROM candles WHERE symbol = ? AND interval = ? AND bucket_start >= ? AND bucket_start < ? ORDER BY bucket_start",
+		symbol, interval, from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candles: %v", err)
+	}
+	return scanCandles(rows, symbol, interval)
+}
+
+func scanCandles(rows *sql.Rows, symbol, interval string) ([]models.Candle, error) {
+	defer rows.Close()
+
+	result := []models.Candle{}
+	for rows.Next() {
+		c := models.Candle{Symbol: symbol, Interval: interval}
+		var start int64
+		if err := rows.Scan(&start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.OI); err != nil {
+			return nil, fmt.Errorf("failed to scan candle: %v", err)
+		}
+		c.Start = time.UnixMilli(start).In(market.IST)
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,336 @@
+package candles
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/instruments"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// maxReportedGaps bounds the gap list in an import report
+const maxReportedGaps = 100
+
+// timeColumns are the accepted names of the timestamp column
+var timeColumns = []string{"timestamp", "date", "datetime", "time"}
+
+// timeLayouts are tried in order; layouts without a zone are read as IST
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700", // Kite historical data
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// CalendarFor picks the trading calendar for a symbol: the given exchange, else the
+// instruments master, else NSE
+func CalendarFor(symbol, exchange string) string {
+	if exchange != "" {
+		return market.Calendar(exchange)
+	}
+	if inst, err := instruments.Resolve(symbol, false); err == nil {
+		return market.Calendar(inst.Exchange)
+	}
+	return market.NSE
+}
+
+// ParseTime reads a CSV timestamp: one of timeLayouts, or Unix seconds or milliseconds
+func ParseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, market.IST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// ParseCSV reads OHLCV rows (columns matched by header: timestamp or date, open, high, low, close,
+// and optional volume and oi). Timestamps must fall on the calendar's interval boundaries.
+func ParseCSV(symbol, interval, calendar string, r io.Reader) ([]models.Candle, error) {
+	if _, ok := Intervals[interval]; !ok {
+		return nil, fmt.Errorf("interval must be one of 1m, 5m, 15m, 1h, 1d")
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	timeCol := -1
+	for _, name := range timeColumns {
+		if i, ok := cols[name]; ok {
+			timeCol = i
+			break
+		}
+	}
+	if timeCol < 0 {
+		return nil, errors.New("CSV needs a timestamp or date column")
+	}
+	for _, name := range []string{"open", "high", "low", "close"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var result []models.Candle
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if timeCol >= len(record) {
+			return nil, fmt.Errorf("line %d: missing timestamp", line)
+		}
+
+		ts, err := ParseTime(strings.TrimSpace(record[timeCol]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		start := BucketStart(ts, interval, calendar)
+		if !start.Equal(ts) {
+			return nil, fmt.Errorf("line %d: %s is not the start of a %s candle", line, ts.In(market.IST).Format(time.RFC3339), interval)
+		}
+
+		c := models.Candle{Symbol: symbol, Interval: interval, Start: start.In(market.IST)}
+		for _, f := range []struct {
+			name string
+			dst  *float64
+		}{{"open", &c.Open}, {"high", &c.High}, {"low", &c.Low}, {"close", &c.Close}} {
+			if *f.dst, err = strconv.ParseFloat(field(record, f.name), 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s", line, f.name)
+			}
+		}
+		if c.Low <= 0 || c.High < c.Low || c.Open < c.Low || c.Open > c.High || c.Close < c.Low || c.Close > c.High {
+			return nil, fmt.Errorf("line %d: inconsistent OHLC values", line)
+		}
+		if v := field(record, "volume"); v != "" {
+			if c.Volume, err = parseCount(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid volume", line)
+			}
+		}
+		if v := field(record, "oi"); v != "" {
+			if c.OI, err = parseCount(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid oi", line)
+			}
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// parseCount reads a volume or open interest, which some exports write as decimals
+func parseCount(s string) (int64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	return int64(f), err
+}
+
+// Import stores historical candles for one symbol and interval from a CSV file, replacing stored
+// candles with the same timestamp, and reports the gaps on the calendar's trading sessions
+// between the first and last imported candle.
+func Import(symbol, interval, calendar string, r io.Reader) (*models.CandleImportResult, error) {
+	parsed, err := ParseCSV(symbol, interval, calendar, r)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("CSV has no candles")
+	}
+
+	result := &models.CandleImportResult{
+		Success:  true,
+		Symbol:   symbol,
+		Interval: interval,
+		Exchange: market.Calendar(calendar),
+		Rows:     len(parsed),
+	}
+
+	// Deduplicate by timestamp, keeping the last row
+	byStart := make(map[int64]models.Candle, len(parsed))
+	for _, c := range parsed {
+		if _, ok := byStart[c.Start.UnixMilli()]; ok {
+			result.Duplicates++
+		}
+		byStart[c.Start.UnixMilli()] = c
+	}
+	starts := make([]int64, 0, len(byStart))
+	for start := range byStart {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	result.From = time.UnixMilli(starts[0]).In(market.IST)
+	result.To = time.UnixMilli(starts[len(starts)-1]).In(market.IST)
+
+	deduped := make([]models.Candle, 0, len(starts))
+	for _, start := range starts {
+		deduped = append(deduped, byStart[start])
+	}
+	if result.Inserted, result.Updated, err = Save(symbol, interval, deduped); err != nil {
+		return nil, err
+	}
+
+	gaps, missing, err := Gaps(symbol, interval, calendar, result.From, result.To)
+	if err != nil {
+		return nil, err
+	}
+	result.Gaps, result.MissingCandles = gaps, missing
+	return result, nil
+}
+
+// Save stores candles from an outside source (a CSV import or a market-data provider), replacing
+// stored candles with the same start. Saved candles survive Rebuild. It returns how many candles
+// were new and how many replaced stored ones.
+func Save(symbol, interval string, list []models.Candle) (inserted, updated int, err error) {
+	if len(list) == 0 {
+		return 0, 0, nil
+	}
+	from, to := list[0].Start.UnixMilli(), list[0].Start.UnixMilli()
+	for _, c := range list {
+		from, to = min(from, c.Start.UnixMilli()), max(to, c.Start.UnixMilli())
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	existing := make(map[int64]bool)
+	rows, err := tx.Query("SELECT bucket_start FROM candles WHERE symbol = ? AND interval = ? AND bucket_start >= ? AND bucket_start <= ?",
+		symbol, interval, from, to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query candles: %v", err)
+	}
+	for rows.Next() {
+		var start int64
+		if err := rows.Scan(&start); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan candle: %v", err)
+		}
+		existing[start] = true
+	}
+	rows.Close()
+
+	stmt, err := tx.Prepare(`INSERT INTO candles (symbol, interval, bucket_start, open, high, low, close, volume, oi, first_ts, last_ts, imported)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)
+		ON CONFLICT (symbol, interval, bucket_start) DO UPDATE SET open = excluded.open, high = excluded.high, low = excluded.low,
+			close = excluded.close, volume = excluded.volume, oi = excluded.oi, first_ts = excluded.first_ts, last_ts = excluded.last_ts, imported = 1`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prepare candle upsert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range list {
+		start := c.Start.UnixMilli()
+		if _, err := stmt.Exec(symbol, interval, start, c.Open, c.High, c.Low, c.Close, c.Volume, c.OI, start, start); err != nil {
+			return 0, 0, fmt.Errorf("failed to store candle at %s: %v", c.Start.Format(time.RFC3339), err)
+		}
+		if existing[start] {
+			updated++
+		} else {
+			inserted++
+			existing[start] = true
+		}
+	}
+	return inserted, updated, tx.Commit()
+}
+
+// SessionBuckets lists the bucket starts in [from, to] that overlap a continuous trading session
+func SessionBuckets(interval, calendar string, from, to time.Time) []time.Time {
+	length := Intervals[interval]
+	var expected []time.Time
+	for day := BucketStart(from, "1d", calendar); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !market.IsTradingDay(calendar, day) {
+			continue
+		}
+		if length >= 24*time.Hour {
+			if !day.Before(from) {
+				expected = append(expected, day)
+			}
+			continue
+		}
+		seen := make(map[int64]bool)
+		for _, s := range market.Sessions(calendar, day) {
+			if s.Name != market.SessionNormal && s.Name != market.SessionEvening {
+				continue
+			}
+			end := day.Add(time.Duration(s.End) * time.Minute)
+			for t := BucketStart(day.Add(time.Duration(s.Start)*time.Minute), interval, calendar); t.Before(end); t = t.Add(length) {
+				if t.Before(from) || t.After(to) || seen[t.UnixMilli()] {
+					continue
+				}
+				seen[t.UnixMilli()] = true
+				expected = append(expected, t)
+			}
+		}
+	}
+	return expected
+}
+
+// Gaps finds missing candles in [from, to] on the calendar's trading sessions. It returns up to
+// maxReportedGaps runs of consecutive missing candles and the total number missing.
+func Gaps(symbol, interval, calendar string, from, to time.Time) ([]models.CandleGap, int, error) {
+	stored, err := Query(symbol, interval, from, to.Add(time.Millisecond))
+	if err != nil {
+		return nil, 0, err
+	}
+	have := make(map[int64]bool, len(stored))
+	for _, c := range stored {
+		have[c.Start.UnixMilli()] = true
+	}
+
+	gaps := []models.CandleGap{}
+	missing := 0
+	inGap := false
+	for _, t := range SessionBuckets(interval, calendar, from, to) {
+		if have[t.UnixMilli()] {
+			inGap = false
+			continue
+		}
+		missing++
+		if inGap {
+			gaps[len(gaps)-1].To = t
+			gaps[len(gaps)-1].Missing++
+			continue
+		}
+		inGap = true
+		if len(gaps) < maxReportedGaps {
+			gaps = append(gaps, models.CandleGap{From: t, To: t, Missing: 1})
+		} else {
+			// Stop extending the last reported gap once the list is full
+			inGap = false
+		}
+	}
+	return gaps, missing, nil
+}
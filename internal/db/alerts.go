@@ -0,0 +1,300 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const alertColumns = `id, symbol, COALESCE(underlying_symbol, ''), COALESCE(option_type, ''), COALESCE(strike_price, 0), COALESCE(expiry, ''),
+	alert_type, target_value, condition, COALESCE(message, ''), is_active, created_at, updated_at, user_id,
+	COALESCE(exchange, ''), COALESCE(instrument_token, 0), COALESCE(lot_size, 0),
+	COALESCE(kite_uuid, ''), COALESCE(sync_status, 'pending'), COALESCE(sync_error, ''), last_sync_at,
+	triggered_at, COALESCE(triggered_price, 0),
+	COALESCE(baseline_type, ''), COALESCE(baseline_value, 0), COALESCE(baseline_date, ''), COALESCE(direction, ''),
+	COALESCE(expression, ''), COALESCE(interval, ''), COALESCE(period, 0), COALESCE(slow_period, 0), COALESCE(multiplier, 0),
+	COALESCE(trail_side, ''), COALESCE(trail_type, ''), COALESCE(trail_value, 0), COALESCE(activation_price, 0), COALESCE(trail_extreme, 0), COALESCE(trail_level, 0),
+	COALESCE(peak_pnl, 0), COALESCE(peak_date, ''), COALESCE(channels, ''),
+	COALESCE(trigger_policy, 'ONCE'), COALESCE(cooldown_seconds, 0), valid_until, COALESCE(trigger_count, 0)`
+
+// scanAlert reads a row selected with alertColumns
+func scanAlert(row interface{ Scan(...interface{}) error }) (models.Alert, error) {
+	var a models.Alert
+	var lastSyncAt, triggeredAt, validUntil sql.NullInt64
+	var channels string
+	err := row.Scan(&a.ID, &a.Symbol, &a.UnderlyingSymbol, &a.OptionType, &a.StrikePrice, &a.Expiry,
+		&a.AlertType, &a.TargetValue, &a.Condition, &a.Message, &a.IsActive, &a.CreatedAt, &a.UpdatedAt, &a.UserID,
+		&a.Exchange, &a.InstrumentToken, &a.LotSize,
+		&a.KiteUUID, &a.SyncStatus, &a.SyncError, &lastSyncAt,
+		&triggeredAt, &a.TriggeredPrice,
+		&a.BaselineType, &a.BaselineValue, &a.BaselineDate, &a.Direction,
+		&a.Expression, &a.Interval, &a.Period, &a.SlowPeriod, &a.Multiplier,
+		&a.TrailSide, &a.TrailType, &a.TrailValue, &a.ActivationPrice, &a.TrailExtreme, &a.TrailLevel,
+		&a.PeakPnL, &a.PeakDate, &channels,
+		&a.TriggerPolicy, &a.CooldownSeconds, &validUntil, &a.TriggerCount)
+	if err != nil {
+		return a, err
+	}
+	if lastSyncAt.Valid {
+		t := time.Unix(lastSyncAt.Int64, 0)
+		a.LastSyncAt = &t
+	}
+	if triggeredAt.Valid {
+		t := time.Unix(triggeredAt.Int64, 0)
+		a.TriggeredAt = &t
+	}
+	if validUntil.Valid {
+		t := time.Unix(validUntil.Int64, 0)
+		a.ValidUntil = &t
+	}
+	if channels != "" {
+		if err := json.Unmarshal([]byte(channels), &a.Channels); err != nil {
+			return a, fmt.Errorf("invalid channels of alert %d: %v", a.ID, err)
+		}
+	}
+	return a, nil
+}
+
+// GetAlert returns an alert by ID; it returns sql.ErrNoRows if there is none
+func GetAlert(alertID int) (models.Alert, error) {
+	return scanAlert(DB.QueryRow("SELECT "+alertColumns+" FROM alerts WHERE id = ?", alertID))
+}
+
+// GetUserAlerts returns a user's alerts, newest first
+func GetUserAlerts(userID int) ([]models.Alert, error) {
+	return queryAlerts("SELECT "+alertColumns+" FROM alerts WHERE user_id = ? ORDER BY created_at DESC", userID)
+}
+
+// GetActiveAlerts returns every active alert of every user
+func GetActiveAlerts() ([]models.Alert, error) {
+	return queryAlerts("SELECT " + alertColumns + " FROM alerts WHERE is_active = 1 ORDER BY id")
+}
+
+func queryAlerts(query string, args ...interface{}) ([]models.Alert, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// ExpireAlerts deactivates active alerts whose valid_until has passed and returns them
+func ExpireAlerts(now time.Time) ([]models.Alert, error) {
+	expired, err := queryAlerts("SELECT "+alertColumns+" FROM alerts WHERE is_active = 1 AND valid_until IS NOT NULL AND valid_until <= ?", now.Unix())
+	if err != nil || len(expired) == 0 {
+		return nil, err
+	}
+	for i := range expired {
+		if _, err := DB.Exec("UPDATE alerts SET is_active = 0, updated_at = ? WHERE id = ?", time.Now(), expired[i].ID); err != nil {
+			return expired[:i], err
+		}
+		expired[i].IsActive = false
+	}
+	return expired, nil
+}
+
+// CountUserAlerts counts a user's alerts, optionally only those on one symbol, leaving out excludeID
+func CountUserAlerts(userID int, symbol string, excludeID int) (int, error) {
+	var n int
+	err := DB.QueryRow("SELECT COUNT(*) FROM alerts WHERE user_id = ? AND (? = '' OR symbol = ?) AND id != ?",
+		userID, symbol, symbol, excludeID).Scan(&n)
+	return n, err
+}
+
+// ChannelsOrNil stores an alert's selected notification channels as a JSON list, or NULL for all
+func ChannelsOrNil(channels []int) interface{} {
+	if len(channels) == 0 {
+		return nil
+	}
+	encoded, _ := json.Marshal(channels)
+	return string(encoded)
+}
+
+// SetAlertSync records the outcome of a Kite sync attempt; it returns sql.ErrNoRows if the alert is gone
+func SetAlertSync(alertID int, kiteUUID, status, syncError string) error {
+	result, err := DB.Exec("UPDATE alerts SET kite_uuid = ?, sync_status = ?, sync_error = ?, last_sync_at = ? WHERE id = ?",
+		kiteUUID, status, syncError, time.Now().Unix(), alertID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetAlertActive enables or disables an alert without touching its definition
+func SetAlertActive(alertID int, active bool) error {
+	_, err := DB.Exec("UPDATE alerts SET is_active = ?, updated_at = ? WHERE id = ?", active, time.Now(), alertID)
+	return err
+}
+
+// SetAlertBaseline stores the reference price of a percentage change alert
+func SetAlertBaseline(alertID int, value float64, date string) error {
+	_, err := DB.Exec("UPDATE alerts SET baseline_value = ?, baseline_date = ? WHERE id = ?", value, date, alertID)
+	return err
+}
+
+// SetAlertTrail stores the running high or low and the stop level of a trailing stop
+func SetAlertTrail(alertID int, extreme, level float64) error {
+	_, err := DB.Exec("UPDATE alerts SET trail_extreme = ?, trail_level = ? WHERE id = ?", extreme, level, alertID)
+	return err
+}
+
+// SetAlertPeak stores the intraday peak of the day's P&L followed by a drawdown alert
+func SetAlertPeak(alertID int, peak float64, date string) error {
+	_, err := DB.Exec("UPDATE alerts SET peak_pnl = ?, peak_date = ? WHERE id = ?", peak, date, alertID)
+	return err
+}
+
+// TriggerAlert stores the trigger on an active alert, deactivating ONCE alerts, and records it in
+// alert_events in one transaction. It returns the event ID, or 0 when the alert was already
+// inactive or gone, so a one-shot alert is only ever triggered once. indicators holds the values
+// of an indicator alert, nil for other alerts.
+func TriggerAlert(alert models.Alert, price float64, at time.Time, indicators map[string]float64) (int64, error) {
+	snapshot, err := json.Marshal(alert)
+	if err != nil {
+		return 0, err
+	}
+	var values sql.NullString
+	if indicators != nil {
+		encoded, err := json.Marshal(indicators)
+		if err != nil {
+			return 0, err
+		}
+		values = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	keepActive := alert.TriggerPolicy == models.PolicyRecurring || alert.TriggerPolicy == models.PolicyDaily
+	result, err := tx.Exec("UPDATE alerts SET is_active = ?, triggered_at = ?, triggered_price = ?, trigger_count = COALESCE(trigger_count, 0) + 1, updated_at = ? WHERE id = ? AND is_active = 1",
+		keepActive, at.Unix(), price, time.Now(), alert.ID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return 0, err
+	}
+	result, err = tx.Exec("INSERT INTO alert_events (alert_id, user_id, symbol, price, triggered_at, alert, indicators) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		alert.ID, alert.UserID, alert.Symbol, price, at.UnixMilli(), string(snapshot), values)
+	if err != nil {
+		return 0, err
+	}
+	eventID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return eventID, tx.Commit()
+}
+
+// AddAlertDelivery appends a notification result to an alert event
+func AddAlertDelivery(eventID int64, delivery models.AlertDelivery) error {
+	entry, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Exec("UPDATE alert_events SET deliveries = json_insert(deliveries, '$[#]', json(?)) WHERE id = ?", string(entry), eventID)
+	return err
+}
+
+// AlertEventFilter narrows a user's alert trigger history; zero values match everything
+type AlertEventFilter struct {
+	AlertID  int
+	Symbol   string
+	From, To time.Time // triggered in [From, To)
+	Limit    int
+}
+
+// GetAlertEvents returns a user's alert triggers matching the filter, newest first
+func GetAlertEvents(userID int, filter AlertEventFilter) ([]models.AlertEvent, error) {
+	query := "SELECT id, alert_id, symbol, price, triggered_at, alert, deliveries, COALESCE(indicators, '') FROM alert_events WHERE user_id = ?"
+	args := []interface{}{userID}
+	if filter.AlertID != 0 {
+		query += " AND alert_id = ?"
+		args = append(args, filter.AlertID)
+	}
+	if filter.Symbol != "" {
+		query += " AND symbol = ?"
+		args = append(args, filter.Symbol)
+	}
+	if !filter.From.IsZero() {
+		query += " AND triggered_at >= ?"
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		query += " AND triggered_at < ?"
+		args = append(args, filter.To.UnixMilli())
+	}
+	query += " ORDER BY triggered_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AlertEvent{}
+	for rows.Next() {
+		var e models.AlertEvent
+		var triggeredAt int64
+		var snapshot, deliveries, indicators string
+		if err := rows.Scan(&e.ID, &e.AlertID, &e.Symbol, &e.Price, &triggeredAt, &snapshot, &deliveries, &indicators); err != nil {
+			return nil, err
+		}
+		e.TriggeredAt = time.UnixMilli(triggeredAt)
+		if err := json.Unmarshal([]byte(snapshot), &e.Alert); err != nil {
+			return nil, fmt.Errorf("invalid snapshot of alert event %d: %v", e.ID, err)
+		}
+		if err := json.Unmarshal([]byte(deliveries), &e.Deliveries); err != nil {
+			return nil, fmt.Errorf("invalid deliveries of alert event %d: %v", e.ID, err)
+		}
+		if indicators != "" {
+			if err := json.Unmarshal([]byte(indicators), &e.Indicators); err != nil {
+				return nil, fmt.Errorf("invalid indicators of alert event %d: %v", e.ID, err)
+			}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// UserIDsWithAlerts returns every user that has at least one alert
+func UserIDsWithAlerts() ([]int, error) {
+	rows, err := DB.Query("SELECT DISTINCT user_id FROM alerts")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
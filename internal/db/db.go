@@ -11,7 +11,8 @@ var DB *sql.DB
 
 func InitDB() {
 	var err error
-	DB, err = sql.Open("sqlite", "stocks.db")
+	// Background workers write concurrently, so wait for locks instead of failing with SQLITE_BUSY
+	DB, err = sql.Open("sqlite", "stocks.db?_pragma=busy_timeout(5000)")
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
@@ -26,13 +27,23 @@ func InitDB() {
 		expiry TEXT,
 		price REAL NOT NULL,
 		side TEXT,
-		timestamp DATETIME NOT NULL
+		timestamp DATETIME NOT NULL,
+		user_id INTEGER,
+		exchange TEXT,
+		instrument_token INTEGER,
+		lot_size INTEGER
 	);`
 	_, err = DB.Exec(createStocksTable)
 	if err != nil {
 		log.Fatalf("Failed to create stocks table: %v", err)
 	}
 
+	// Older databases predate per-user trades and instrument details
+	addColumnIfMissing("stocks", "user_id", "INTEGER")
+	addColumnIfMissing("stocks", "exchange", "TEXT")
+	addColumnIfMissing("stocks", "instrument_token", "INTEGER")
+	addColumnIfMissing("stocks", "lot_size", "INTEGER")
+
 	// Create users table
 	createUsersTable := `CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -63,12 +74,296 @@ func InitDB() {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		user_id INTEGER NOT NULL,
+		exchange TEXT,
+		instrument_token INTEGER,
+		lot_size INTEGER,
+		kite_uuid TEXT,
+		sync_status TEXT DEFAULT 'pending',
+		sync_error TEXT,
+		last_sync_at INTEGER,
+		triggered_at INTEGER,
+		triggered_price REAL,
+		baseline_type TEXT,
+		baseline_value REAL,
+		baseline_date TEXT,
+		direction TEXT,
+		expression TEXT,
+		interval TEXT,
+		period INTEGER DEFAULT 0,
+		slow_period INTEGER DEFAULT 0,
+		multiplier REAL DEFAULT 0,
+		trail_side TEXT,
+		trail_type TEXT,
+		trail_value REAL DEFAULT 0,
+		activation_price REAL DEFAULT 0,
+		trail_extreme REAL DEFAULT 0,
+		trail_level REAL DEFAULT 0,
+		peak_pnl REAL DEFAULT 0,
+		peak_date TEXT,
+		channels TEXT,
+		trigger_policy TEXT DEFAULT 'ONCE',
+		cooldown_seconds INTEGER DEFAULT 0,
+		valid_until INTEGER,
+		trigger_count INTEGER DEFAULT 0,
 		FOREIGN KEY (user_id) REFERENCES users (id)
 	);`
 	_, err = DB.Exec(createAlertsTable)
 	if err != nil {
 		log.Fatalf("Failed to create alerts table: %v", err)
 	}
+	// Older databases predate instrument details and Kite sync state (last_sync_at is unix seconds)
+	addColumnIfMissing("alerts", "exchange", "TEXT")
+	addColumnIfMissing("alerts", "instrument_token", "INTEGER")
+	addColumnIfMissing("alerts", "lot_size", "INTEGER")
+	addColumnIfMissing("alerts", "kite_uuid", "TEXT")
+	addColumnIfMissing("alerts", "sync_status", "TEXT DEFAULT 'pending'")
+	addColumnIfMissing("alerts", "sync_error", "TEXT")
+	addColumnIfMissing("alerts", "last_sync_at", "INTEGER")
+	// Last trigger by the alert engine (unix seconds)
+	addColumnIfMissing("alerts", "triggered_at", "INTEGER")
+	addColumnIfMissing("alerts", "triggered_price", "REAL")
+	// Reference price of percentage change alerts
+	addColumnIfMissing("alerts", "baseline_type", "TEXT")
+	addColumnIfMissing("alerts", "baseline_value", "REAL")
+	addColumnIfMissing("alerts", "baseline_date", "TEXT")
+	addColumnIfMissing("alerts", "direction", "TEXT")
+	// Condition of expression alerts
+	addColumnIfMissing("alerts", "expression", "TEXT")
+	// Candle interval and parameters of indicator alerts
+	addColumnIfMissing("alerts", "interval", "TEXT")
+	addColumnIfMissing("alerts", "period", "INTEGER DEFAULT 0")
+	addColumnIfMissing("alerts", "slow_period", "INTEGER DEFAULT 0")
+	addColumnIfMissing("alerts", "multiplier", "REAL DEFAULT 0")
+	// Trailing stop definition and its current trail
+	addColumnIfMissing("alerts", "trail_side", "TEXT")
+	addColumnIfMissing("alerts", "trail_type", "TEXT")
+	addColumnIfMissing("alerts", "trail_value", "REAL DEFAULT 0")
+	addColumnIfMissing("alerts", "activation_price", "REAL DEFAULT 0")
+	addColumnIfMissing("alerts", "trail_extreme", "REAL DEFAULT 0")
+	addColumnIfMissing("alerts", "trail_level", "REAL DEFAULT 0")
+	// Intraday P&L peak of drawdown alerts
+	addColumnIfMissing("alerts", "peak_pnl", "REAL DEFAULT 0")
+	addColumnIfMissing("alerts", "peak_date", "TEXT")
+	// Notification channels selected for the alert (JSON list of IDs)
+	addColumnIfMissing("alerts", "channels", "TEXT")
+	// Trigger policy and expiry (valid_until is unix seconds)
+	addColumnIfMissing("alerts", "trigger_policy", "TEXT DEFAULT 'ONCE'")
+	addColumnIfMissing("alerts", "cooldown_seconds", "INTEGER DEFAULT 0")
+	addColumnIfMissing("alerts", "valid_until", "INTEGER")
+	addColumnIfMissing("alerts", "trigger_count", "INTEGER DEFAULT 0")
+	// "==" is no longer a condition; alerts stored with it fire when the price reaches the target
+	if _, err := DB.Exec(`UPDATE alerts SET condition = CASE alert_type WHEN 'PRICE_BELOW' THEN '<=' ELSE '>=' END WHERE condition = '=='`); err != nil {
+		log.Fatalf("Failed to migrate == alert conditions: %v", err)
+	}
+
+	// Create portfolio snapshots table (one row per user, day and instrument)
+	createPortfolioSnapshotsTable := `CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		snapshot_date TEXT NOT NULL,
+		symbol TEXT NOT NULL,
+		underlying_symbol TEXT,
+		option_type TEXT,
+		strike_price REAL,
+		expiry TEXT,
+		net_quantity REAL NOT NULL,
+		avg_cost REAL NOT NULL,
+		mark_price REAL NOT NULL,
+		realized_pnl REAL NOT NULL,
+		unrealized_pnl REAL NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, snapshot_date, symbol),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);`
+	_, err = DB.Exec(createPortfolioSnapshotsTable)
+	if err != nil {
+		log.Fatalf("Failed to create portfolio_snapshots table: %v", err)
+	}
+
+	// Create daily prices table (benchmark and index closes)
+	createDailyPricesTable := `CREATE TABLE IF NOT EXISTS daily_prices (
+		symbol TEXT NOT NULL,
+		price_date TEXT NOT NULL,
+		close REAL NOT NULL,
+		PRIMARY KEY (symbol, price_date)
+	);`
+	_, err = DB.Exec(createDailyPricesTable)
+	if err != nil {
+		log.Fatalf("Failed to create daily_prices table: %v", err)
+	}
+
+	// Create cash ledger table
+	createCashLedgerTable := `CREATE TABLE IF NOT EXISTS cash_ledger (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		entry_type TEXT NOT NULL,
+		amount REAL NOT NULL,
+		entry_date TEXT NOT NULL,
+		note TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);`
+	_, err = DB.Exec(createCashLedgerTable)
+	if err != nil {
+		log.Fatalf("Failed to create cash_ledger table: %v", err)
+	}
+
+	// Create quotes table (ts is Unix milliseconds so range scans stay cheap)
+	createQuotesTable := `CREATE TABLE IF NOT EXISTS quotes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		symbol TEXT NOT NULL,
+		exchange TEXT,
+		ltp REAL NOT NULL,
+		bid REAL,
+		ask REAL,
+		volume INTEGER,
+		oi INTEGER,
+		ts INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_quotes_symbol_ts ON quotes (symbol, ts);
+	CREATE INDEX IF NOT EXISTS idx_quotes_ts ON quotes (ts);`
+	_, err = DB.Exec(createQuotesTable)
+	if err != nil {
+		log.Fatalf("Failed to create quotes table: %v", err)
+	}
+
+	// Create candles table (bucket_start and last_ts are Unix milliseconds)
+	createCandlesTable := `CREATE TABLE IF NOT EXISTS candles (
+		symbol TEXT NOT NULL,
+		interval TEXT NOT NULL,
+		bucket_start INTEGER NOT NULL,
+		open REAL NOT NULL,
+		high REAL NOT NULL,
+		low REAL NOT NULL,
+		close REAL NOT NULL,
+		volume INTEGER NOT NULL DEFAULT 0,
+		oi INTEGER NOT NULL DEFAULT 0,
+		first_ts INTEGER NOT NULL DEFAULT 0,
+		last_ts INTEGER NOT NULL,
+		imported INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (symbol, interval, bucket_start)
+	);`
+	_, err = DB.Exec(createCandlesTable)
+	if err != nil {
+		log.Fatalf("Failed to create candles table: %v", err)
+	}
+	// Older databases predate historical imports
+	addColumnIfMissing("candles", "imported", "INTEGER NOT NULL DEFAULT 0")
+	// Older candles do not know when their open was quoted, so late quotes leave it as is
+	addColumnIfMissing("candles", "first_ts", "INTEGER NOT NULL DEFAULT 0")
+
+	// Create Kite sessions table (one daily access token per user; times are Unix seconds)
+	createKiteSessionsTable := `CREATE TABLE IF NOT EXISTS kite_sessions (
+		user_id INTEGER PRIMARY KEY,
+		kite_user_id TEXT NOT NULL,
+		access_token TEXT NOT NULL,
+		public_token TEXT,
+		login_time INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);`
+	_, err = DB.Exec(createKiteSessionsTable)
+	if err != nil {
+		log.Fatalf("Failed to create kite_sessions table: %v", err)
+	}
+
+	// Create instruments master, loaded from the Kite instruments CSV dump
+	createInstrumentsTable := `CREATE TABLE IF NOT EXISTS instruments (
+		instrument_token INTEGER PRIMARY KEY,
+		exchange_token INTEGER,
+		tradingsymbol TEXT NOT NULL COLLATE NOCASE,
+		name TEXT,
+		expiry TEXT,
+		strike REAL,
+		tick_size REAL,
+		lot_size INTEGER,
+		instrument_type TEXT,
+		segment TEXT,
+		exchange TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_instruments_symbol ON instruments (tradingsymbol);`
+	_, err = DB.Exec(createInstrumentsTable)
+	if err != nil {
+		log.Fatalf("Failed to create instruments table: %v", err)
+	}
+
+	// Create market holidays table, one row per exchange calendar and closed date
+	createMarketHolidaysTable := `CREATE TABLE IF NOT EXISTS market_holidays (
+		calendar TEXT NOT NULL,
+		holiday_date TEXT NOT NULL,
+		description TEXT,
+		PRIMARY KEY (calendar, holiday_date)
+	);`
+	_, err = DB.Exec(createMarketHolidaysTable)
+	if err != nil {
+		log.Fatalf("Failed to create market_holidays table: %v", err)
+	}
+
+	// Create alert events table, one row per trigger (triggered_at is Unix milliseconds).
+	// alert is a JSON snapshot of the definition that fired, deliveries a JSON array of
+	// per-channel notification results and indicators a JSON object of indicator values.
+	createAlertEventsTable := `CREATE TABLE IF NOT EXISTS alert_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		alert_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		symbol TEXT NOT NULL,
+		price REAL NOT NULL,
+		triggered_at INTEGER NOT NULL,
+		alert TEXT NOT NULL,
+		deliveries TEXT NOT NULL DEFAULT '[]',
+		indicators TEXT,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_alert_events_user_time ON alert_events (user_id, triggered_at);
+	CREATE INDEX IF NOT EXISTS idx_alert_events_alert ON alert_events (alert_id, triggered_at);`
+	_, err = DB.Exec(createAlertEventsTable)
+	if err != nil {
+		log.Fatalf("Failed to create alert_events table: %v", err)
+	}
+	addColumnIfMissing("alert_events", "indicators", "TEXT")
+
+	// Create notification channels table (per-user alert destinations)
+	createNotificationChannelsTable := `CREATE TABLE IF NOT EXISTS notification_channels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		name TEXT NOT NULL,
+		address TEXT,
+		url TEXT,
+		secret TEXT,
+		bot_token TEXT,
+		chat_id TEXT,
+		is_active BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_notification_channels_user ON notification_channels (user_id);`
+	_, err = DB.Exec(createNotificationChannelsTable)
+	if err != nil {
+		log.Fatalf("Failed to create notification_channels table: %v", err)
+	}
+
+	// Create outbox table for outbound broker calls (next_attempt_at, created_at and updated_at are Unix milliseconds)
+	createOutboxTable := `CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		operation TEXT NOT NULL,
+		alert_id INTEGER,
+		payload TEXT,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL,
+		last_error TEXT,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_status_next ON outbox (status, next_attempt_at);`
+	_, err = DB.Exec(createOutboxTable)
+	if err != nil {
+		log.Fatalf("Failed to create outbox table: %v", err)
+	}
 
 	// Insert default users if they don't exist
 	insertDefaultUsers := `INSERT OR IGNORE INTO users (username, email, password_hash) VALUES 
@@ -83,6 +378,53 @@ func InitDB() {
 	log.Printf("✅ Database initialized successfully")
 }
 
+// addColumnIfMissing adds a column to an existing table created by an older version
+func addColumnIfMissing(table, column, definition string) {
+	rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		log.Fatalf("Failed to inspect %s table: %v", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			log.Fatalf("Failed to inspect %s table: %v", table, err)
+		}
+		if name == column {
+			return
+		}
+	}
+	if _, err := DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition); err != nil {
+		log.Fatalf("Failed to add %s.%s column: %v", table, column, err)
+	}
+}
+
+// GetUserIDs returns the IDs of all users
+func GetUserIDs() ([]int, error) {
+	rows, err := DB.Query("SELECT id FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetUserEmail returns the email address of a user
+func GetUserEmail(userID int) (string, error) {
+	var email string
+	err := DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	return email, err
+}
+
 // GetUserByEmail retrieves a user by email
 func GetUserByEmail(email string) (string, error) {
 	var username string
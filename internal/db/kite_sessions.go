@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// KiteSession is a user's stored Kite Connect access token
+type KiteSession struct {
+	UserID      int
+	KiteUserID  string
+	AccessToken string
+	PublicToken string
+	LoginTime   time.Time
+	ExpiresAt   time.Time
+}
+
+// Expired reports whether the access token has passed its daily expiry
+func (s *KiteSession) Expired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// SaveKiteSession stores or replaces the user's Kite access token
+func SaveKiteSession(s KiteSession) error {
+	_, err := DB.Exec(
+		`INSERT INTO kite_sessions (user_id, kite_user_id, access_token, public_token, login_time, expires_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET kite_user_id = excluded.kite_user_id, access_token = excluded.access_token,
+			public_token = excluded.public_token, login_time = excluded.login_time, expires_at = excluded.expires_at, updated_at = excluded.updated_at`,
+		s.UserID, s.KiteUserID, s.AccessToken, s.PublicToken, s.LoginTime.Unix(), s.ExpiresAt.Unix(), time.Now(),
+	)
+	return err
+}
+
+// GetKiteSession retrieves the user's Kite session; it returns sql.ErrNoRows if the user never logged in
+func GetKiteSession(userID int) (*KiteSession, error) {
+	return scanKiteSession(DB.QueryRow("SELECT user_id, kite_user_id, access_token, public_token, login_time, expires_at FROM kite_sessions WHERE user_id = ?", userID))
+}
+
+// LatestKiteSession retrieves the most recent login of any user; it returns sql.ErrNoRows if nobody logged in
+func LatestKiteSession() (*KiteSession, error) {
+	return scanKiteSession(DB.QueryRow("SELECT user_id, kite_user_id, access_token, public_token, login_time, expires_at FROM kite_sessions ORDER BY login_time DESC LIMIT 1"))
+}
+
+func scanKiteSession(row *sql.Row) (*KiteSession, error) {
+	s := &KiteSession{}
+	var loginTime, expiresAt int64
+	if err := row.Scan(&s.UserID, &s.KiteUserID, &s.AccessToken, &s.PublicToken, &loginTime, &expiresAt); err != nil {
+		return nil, err
+	}
+	s.LoginTime = time.Unix(loginTime, 0)
+	s.ExpiresAt = time.Unix(expiresAt, 0)
+	return s, nil
+}
+
+// ExpireKiteSession marks the user's token as expired, e.g. after Kite rejects it
+func ExpireKiteSession(userID int) error {
+	_, err := DB.Exec("UPDATE kite_sessions SET expires_at = ?, updated_at = ? WHERE user_id = ?", time.Now().Unix(), time.Now(), userID)
+	return err
+}
+
+// DeleteKiteSession removes the user's stored token
+func DeleteKiteSession(userID int) error {
+	_, err := DB.Exec("DELETE FROM kite_sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// KiteSessionsExpiredSince returns the users whose session expired after the given time
+func KiteSessionsExpiredSince(since time.Time) ([]int, error) {
+	rows, err := DB.Query("SELECT user_id FROM kite_sessions WHERE expires_at > ? AND expires_at <= ?", since.Unix(), time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const channelColumns = `id, user_id, type, name, COALESCE(address, ''), COALESCE(url, ''), COALESCE(secret, ''),
+	COALESCE(bot_token, ''), COALESCE(chat_id, ''), is_active, created_at`
+
+func scanChannel(row interface{ Scan(...interface{}) error }) (models.NotificationChannel, error) {
+	var ch models.NotificationChannel
+	err := row.Scan(&ch.ID, &ch.UserID, &ch.Type, &ch.Name, &ch.Address, &ch.URL, &ch.Secret,
+		&ch.BotToken, &ch.ChatID, &ch.IsActive, &ch.CreatedAt)
+	return ch, err
+}
+
+// CreateNotificationChannel stores a user's channel and returns its ID
+func CreateNotificationChannel(ch models.NotificationChannel) (int, error) {
+	result, err := DB.Exec(
+		"INSERT INTO notification_channels (user_id, type, name, address, url, secret, bot_token, chat_id, is_active, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		ch.UserID, ch.Type, ch.Name, ch.Address, ch.URL, ch.Secret, ch.BotToken, ch.ChatID, ch.IsActive, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateNotificationChannel replaces a user's channel; the boolean is false when nothing matched
+func UpdateNotificationChannel(ch models.NotificationChannel) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE notification_channels SET type = ?, name = ?, address = ?, url = ?, secret = ?, bot_token = ?, chat_id = ?, is_active = ? WHERE id = ? AND user_id = ?",
+		ch.Type, ch.Name, ch.Address, ch.URL, ch.Secret, ch.BotToken, ch.ChatID, ch.IsActive, ch.ID, ch.UserID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteNotificationChannel removes a user's channel; the boolean is false when nothing matched.
+// Alerts that selected it notify their remaining channels.
+func DeleteNotificationChannel(userID, channelID int) (bool, error) {
+	result, err := DB.Exec("DELETE FROM notification_channels WHERE id = ? AND user_id = ?", channelID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetNotificationChannel returns one of a user's channels; it returns sql.ErrNoRows if there is none
+func GetNotificationChannel(userID, channelID int) (models.NotificationChannel, error) {
+	return scanChannel(DB.QueryRow("SELECT "+channelColumns+" FROM notification_channels WHERE id = ? AND user_id = ?", channelID, userID))
+}
+
+// GetNotificationChannels returns a user's channels, oldest first
+func GetNotificationChannels(userID int) ([]models.NotificationChannel, error) {
+	rows, err := DB.Query("SELECT "+channelColumns+" FROM notification_channels WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []models.NotificationChannel{}
+	for rows.Next() {
+		ch, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
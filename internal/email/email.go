@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"gopkg.in/mail.v2"
 )
@@ -18,6 +19,11 @@ type EmailService struct {
 func NewEmailService() *EmailService {
 	// Get SMTP configuration from environment variables
 	smtpHost := getEnvOrDefault("SMTP_HOST", "smtp.gmail.com")
+	smtpPort, err := strconv.Atoi(getEnvOrDefault("SMTP_PORT", "587"))
+	if err != nil {
+		log.Printf("⚠️  Invalid SMTP_PORT %q, using 587", os.Getenv("SMTP_PORT"))
+		smtpPort = 587
+	}
 	smtpUser := os.Getenv("SMTP_USER")
 	smtpPass := os.Getenv("SMTP_PASS")
 	fromEmail := getEnvOrDefault("FROM_EMAIL", smtpUser)
@@ -29,9 +35,16 @@ func NewEmailService() *EmailService {
 		log.Printf("⚠️  Email service not configured - Set SMTP_USER and SMTP_PASS environment variables")
 	}
 
-	// Create dialer
-	dialer := mail.NewDialer(smtpHost, 587, smtpUser, smtpPass)
-	dialer.StartTLSPolicy = mail.MandatoryStartTLS
+	// Create dialer; SMTP_STARTTLS=opportunistic or none allows a local stand-in server without TLS
+	dialer := mail.NewDialer(smtpHost, smtpPort, smtpUser, smtpPass)
+	switch os.Getenv("SMTP_STARTTLS") {
+	case "opportunistic":
+		dialer.StartTLSPolicy = mail.OpportunisticStartTLS
+	case "none":
+		dialer.StartTLSPolicy = mail.NoStartTLS
+	default:
+		dialer.StartTLSPolicy = mail.MandatoryStartTLS
+	}
 
 	return &EmailService{
 		dialer: dialer,
@@ -67,7 +80,7 @@ func (es *EmailService) SendPasswordResetEmail(to, resetLink string) error {
 // SendEmail sends an email using the configured SMTP settings
 func (es *EmailService) SendEmail(to, subject, body string) error {
 	log.Printf("📧 Connecting to SMTP server...")
-	log.Printf("📧 SMTP Details - Host: %s, Port: 587, User: %s", es.dialer.Host, es.dialer.Username)
+	log.Printf("📧 SMTP Details - Host: %s, Port: %d, User: %s", es.dialer.Host, es.dialer.Port, es.dialer.Username)
 
 	m := mail.NewMessage()
 	m.SetHeader("From", es.from)
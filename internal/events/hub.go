@@ -0,0 +1,185 @@
+// Package events is an in-process pub/sub hub that fans live updates out to browser streams
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// Event types
+const (
+	TypeQuote = "quote" // market data for a symbol, delivered to subscribers watching it
+	TypeAlert = "alert" // an alert of the user triggered
+	TypeTrade = "trade" // the user recorded a trade
+)
+
+// bufferSize is how many events a subscriber may fall behind before events are dropped
+const bufferSize = 256
+
+// Event is one update pushed to subscribers
+type Event struct {
+	Type   string
+	UserID int    // owner of alert and trade events; 0 for market data
+	Symbol string // symbol the event is about
+	Data   interface{}
+}
+
+// Subscriber receives the events of one user for the symbols it watches.
+// A subscriber that cannot keep up loses quotes; if it falls behind on its own alert or trade
+// events it is disconnected (Done is closed) so the client reconnects and reloads its state.
+type Subscriber struct {
+	UserID int
+
+	events chan Event
+	done   chan struct{}
+
+	mu      sync.Mutex
+	symbols map[string]bool
+	dropped int
+	closed  bool
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[*Subscriber]struct{})
+)
+
+// Subscribe registers a subscriber for the user, watching the given symbols. Call Close when done.
+func Subscribe(userID int, symbols []string) *Subscriber {
+	s := &Subscriber{
+		UserID:  userID,
+		events:  make(chan Event, bufferSize),
+		done:    make(chan struct{}),
+		symbols: make(map[string]bool),
+	}
+	s.Watch(symbols...)
+
+	mu.Lock()
+	subscribers[s] = struct{}{}
+	mu.Unlock()
+	return s
+}
+
+// Events returns the subscriber's event channel
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed when the subscriber is closed or disconnected for falling behind
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Watch adds symbols whose quotes the subscriber receives
+func (s *Subscriber) Watch(symbols ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, symbol := range symbols {
+		if symbol != "" {
+			s.symbols[symbol] = true
+		}
+	}
+}
+
+// WatchForUser adds symbols to every subscriber of the user, e.g. when an alert is created
+func WatchForUser(userID int, symbols ...string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range subscribers {
+		if s.UserID == userID {
+			s.Watch(symbols...)
+		}
+	}
+}
+
+// Symbols returns the watched symbols
+func (s *Subscriber) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbols := make([]string, 0, len(s.symbols))
+	for symbol := range s.symbols {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// Close unregisters the subscriber
+func (s *Subscriber) Close() {
+	mu.Lock()
+	delete(subscribers, s)
+	mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// wants reports whether the event is for this subscriber
+func (s *Subscriber) wants(e Event) bool {
+	if e.UserID != 0 {
+		return e.UserID == s.UserID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.symbols[e.Symbol]
+}
+
+// deliver queues the event without blocking the publisher and reports whether it was queued
+func (s *Subscriber) deliver(e Event) bool {
+	select {
+	case s.events <- e:
+		return true
+	default:
+	}
+
+	if e.Type == TypeQuote {
+		// A later quote supersedes this one, so losing it is harmless
+		s.mu.Lock()
+		s.dropped++
+		if s.dropped == 1 || s.dropped%1000 == 0 {
+			log.Printf("⚠️ Event stream of user %d is lagging, %d quotes dropped", s.UserID, s.dropped)
+		}
+		s.mu.Unlock()
+		return false
+	}
+	log.Printf("⚠️ Disconnecting event stream of user %d: too far behind to deliver %s event", s.UserID, e.Type)
+	go s.Close()
+	return false
+}
+
+// Publish fans the event out to every interested subscriber and returns how many received it.
+// It never blocks.
+func Publish(e Event) int {
+	mu.RLock()
+	defer mu.RUnlock()
+	delivered := 0
+	for s := range subscribers {
+		if s.wants(e) && s.deliver(e) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// PublishQuotes publishes a quote event per quote
+func PublishQuotes(batch []models.Quote) {
+	for _, q := range batch {
+		Publish(Event{Type: TypeQuote, Symbol: q.Symbol, Data: q})
+	}
+}
+
+// PublishAlert notifies the user's event streams that an alert fired and returns how many received it
+func PublishAlert(userID int, t models.AlertTrigger) int {
+	return Publish(Event{Type: TypeAlert, UserID: userID, Symbol: t.Symbol, Data: t})
+}
+
+// Start publishes stored quotes to subscribers
+func Start() {
+	quotes.OnSave(PublishQuotes)
+}
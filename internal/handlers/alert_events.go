@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// defaultEventsLimit and maxEventsLimit bound alert history results
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// GetAlertEvents returns the trigger history of one alert (GET /alerts/{id}/events?limit=)
+func GetAlertEvents(w http.ResponseWriter, r *http.Request) {
+	alertID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+	filter, ok := alertEventFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.AlertID = alertID
+	writeAlertEvents(w, r, filter)
+}
+
+// GetAlertHistory returns the user's alert triggers, newest first
+// (?alert_id=&symbol=&from=&to=&limit=; from and to are RFC 3339 timestamps or YYYY-MM-DD dates)
+func GetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	filter, ok := alertEventFilter(w, r)
+	if !ok {
+		return
+	}
+	if idStr := r.URL.Query().Get("alert_id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid alert ID")
+			return
+		}
+		filter.AlertID = id
+	}
+	filter.Symbol = r.URL.Query().Get("symbol")
+	writeAlertEvents(w, r, filter)
+}
+
+// alertEventFilter reads the from, to and limit parameters, writing an error response if invalid
+func alertEventFilter(w http.ResponseWriter, r *http.Request) (db.AlertEventFilter, bool) {
+	query := r.URL.Query()
+	filter := db.AlertEventFilter{Limit: defaultEventsLimit}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = parseTimeParam(from); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return filter, false
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = parseTimeParam(to); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return filter, false
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		writeJSONError(w, http.StatusBadRequest, errInvalidRange.Error())
+		return filter, false
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive number")
+			return filter, false
+		}
+		filter.Limit = min(n, maxEventsLimit)
+	}
+	return filter, true
+}
+
+func writeAlertEvents(w http.ResponseWriter, r *http.Request, filter db.AlertEventFilter) {
+	userID := r.Context().Value("userID").(int)
+	events, err := db.GetAlertEvents(userID, filter)
+	if err != nil {
+		log.Printf("Failed to query alert events: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AlertEventsResponse{
+		Success: true,
+		Events:  events,
+	})
+}
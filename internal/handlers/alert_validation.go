@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/alertengine"
+	"github.com/vinaykotian/stock-panel/internal/alertexpr"
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/instruments"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const (
+	// Default alert quotas, overridable with ALERT_MAX_PER_USER and ALERT_MAX_PER_SYMBOL
+	defaultMaxAlertsPerUser   = 500
+	defaultMaxAlertsPerSymbol = 50
+
+	maxSymbolLength  = 50
+	maxMessageLength = 500
+
+	// maxIndicatorPeriod bounds the candle history an indicator alert reads
+	maxIndicatorPeriod = 200
+	maxMultiplier      = 10
+)
+
+var (
+	alertTypes = map[string]bool{
+		models.AlertPriceAbove:       true,
+		models.AlertPriceBelow:       true,
+		models.AlertPercentageChange: true,
+		models.AlertExpression:       true,
+		models.AlertTrailingStop:     true,
+		models.AlertDayPnL:           true,
+		models.AlertPositionLoss:     true,
+		models.AlertDrawdown:         true,
+		models.AlertSMACross:         true,
+		models.AlertEMACross:         true,
+		models.AlertRSI:              true,
+		models.AlertBollinger:        true,
+		models.AlertSupertrend:       true,
+		models.AlertVWAPCross:        true,
+	}
+	alertConditions = map[string]bool{">": true, "<": true, ">=": true, "<=": true}
+	optionTypes     = map[string]string{"CALL": "CE", "PUT": "PE"} // option type to instrument type
+	baselineTypes   = map[string]bool{
+		models.BaselinePreviousClose: true,
+		models.BaselineCreationPrice: true,
+		models.BaselineDayOpen:       true,
+		models.BaselineFixed:         true,
+	}
+	directions      = map[string]bool{models.DirectionSigned: true, models.DirectionEither: true}
+	triggerPolicies = map[string]bool{models.PolicyOnce: true, models.PolicyRecurring: true, models.PolicyDaily: true}
+	trailSides      = map[string]bool{models.TrailLong: true, models.TrailShort: true}
+	trailTypes      = map[string]bool{models.TrailAmount: true, models.TrailPercent: true}
+
+	// indicatorDefaults fill in the period, slow period and multiplier an indicator alert leaves out
+	indicatorDefaults = map[string]struct {
+		period, slowPeriod int
+		multiplier         float64
+	}{
+		models.AlertSMACross:   {period: 9, slowPeriod: 21},
+		models.AlertEMACross:   {period: 9, slowPeriod: 21},
+		models.AlertRSI:        {period: 14},
+		models.AlertBollinger:  {period: 20, multiplier: 2},
+		models.AlertSupertrend: {period: 10, multiplier: 3},
+		models.AlertVWAPCross:  {},
+	}
+)
+
+// fieldErrors collects every invalid field of a request
+type fieldErrors []models.FieldError
+
+func (e *fieldErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e fieldErrors) has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAlert normalises and checks an alert request and resolves its instrument; alertID is 0
+// for new alerts. Invalid fields are answered with a 400 listing all of them, exceeded quotas with
+// a 409; either way it returns false.
+func validateAlert(w http.ResponseWriter, userID, alertID int, req *models.AlertRequest) (models.Instrument, bool) {
+	normaliseAlert(req)
+
+	errs := checkAlert(*req, time.Now())
+	var inst models.Instrument
+	if !errs.has("symbol") && req.Symbol != "" {
+		var ok bool
+		if inst, ok = checkInstrument(&errs, *req, time.Now()); !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return inst, false
+		}
+	}
+	if !errs.has("expression") && !checkExpressionSymbols(&errs, *req) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return inst, false
+	}
+	if !checkAlertChannels(&errs, userID, *req) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return inst, false
+	}
+	if len(errs) > 0 {
+		writeAlertErrors(w, http.StatusBadRequest, "Invalid alert", errs)
+		return inst, false
+	}
+
+	symbol := req.Symbol
+	if inst.TradingSymbol != "" {
+		symbol = inst.TradingSymbol
+	}
+	quotaErr, err := checkAlertQuota(userID, alertID, symbol)
+	if err != nil {
+		log.Printf("Failed to count alerts: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return inst, false
+	}
+	if quotaErr != nil {
+		writeAlertErrors(w, http.StatusConflict, "Alert quota exceeded", fieldErrors{*quotaErr})
+		return inst, false
+	}
+	return inst, true
+}
+
+func writeAlertErrors(w http.ResponseWriter, status int, message string, errs fieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.AlertResponse{
+		Success: false,
+		Message: message,
+		Errors:  errs,
+	})
+}
+
+// normaliseAlert trims the request and upper-cases its symbols and enum values
+func normaliseAlert(req *models.AlertRequest) {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	req.UnderlyingSymbol = strings.ToUpper(strings.TrimSpace(req.UnderlyingSymbol))
+	req.OptionType = strings.ToUpper(strings.TrimSpace(req.OptionType))
+	req.Expiry = strings.TrimSpace(req.Expiry)
+	req.AlertType = strings.ToUpper(strings.TrimSpace(req.AlertType))
+	req.Condition = strings.TrimSpace(req.Condition)
+	req.Message = strings.TrimSpace(req.Message)
+	req.BaselineType = strings.ToUpper(strings.TrimSpace(req.BaselineType))
+	req.Direction = strings.ToUpper(strings.TrimSpace(req.Direction))
+	req.TriggerPolicy = strings.ToUpper(strings.TrimSpace(req.TriggerPolicy))
+	if req.TriggerPolicy == "" {
+		req.TriggerPolicy = models.PolicyOnce
+	}
+	req.Expression = strings.TrimSpace(req.Expression)
+	req.Interval = strings.ToLower(strings.TrimSpace(req.Interval))
+	req.TrailSide = strings.ToUpper(strings.TrimSpace(req.TrailSide))
+	req.TrailType = strings.ToUpper(strings.TrimSpace(req.TrailType))
+	if req.AlertType == models.AlertTrailingStop && req.TrailType == "" {
+		req.TrailType = models.TrailPercent
+	}
+	if defaults, ok := indicatorDefaults[req.AlertType]; ok {
+		if req.Period == 0 {
+			req.Period = defaults.period
+		}
+		if req.SlowPeriod == 0 {
+			req.SlowPeriod = defaults.slowPeriod
+		}
+		if req.Multiplier == 0 {
+			req.Multiplier = defaults.multiplier
+		}
+	}
+
+	// An expression that names the symbol of every attribute belongs to the first one it reads
+	if req.AlertType == models.AlertExpression && req.Symbol == "" {
+		if expr, err := alertexpr.Parse(req.Expression); err == nil && !expr.UsesOwnSymbol() {
+			if symbols := expr.Symbols(""); len(symbols) > 0 {
+				req.Symbol = symbols[0]
+			}
+		}
+	}
+}
+
+// checkAlert validates the fields of a normalised request and their combinations
+func checkAlert(req models.AlertRequest, now time.Time) fieldErrors {
+	var errs fieldErrors
+
+	wholePortfolio := req.AlertType == models.AlertDayPnL || req.AlertType == models.AlertDrawdown
+	switch {
+	case wholePortfolio && req.Symbol != "":
+		errs.add("symbol", "does not apply to %s alerts, which watch the whole portfolio", req.AlertType)
+	case wholePortfolio, req.Symbol == "" && req.AlertType == models.AlertPositionLoss:
+	case req.Symbol == "" && req.AlertType == models.AlertExpression:
+		errs.add("symbol", "is required when an attribute in the expression names no symbol")
+	case req.Symbol == "":
+		errs.add("symbol", "is required")
+	case len(req.Symbol) > maxSymbolLength:
+		errs.add("symbol", "must be at most %d characters", maxSymbolLength)
+	}
+	if len(req.Message) > maxMessageLength {
+		errs.add("message", "must be at most %d characters", maxMessageLength)
+	}
+
+	switch {
+	case req.AlertType == "":
+		errs.add("alert_type", "is required")
+	case !alertTypes[req.AlertType]:
+		errs.add("alert_type", "must be PRICE_ABOVE, PRICE_BELOW, PERCENTAGE_CHANGE, EXPRESSION, TRAILING_STOP, DAY_PNL, POSITION_LOSS, DRAWDOWN, SMA_CROSS, EMA_CROSS, RSI, BOLLINGER, SUPERTREND or VWAP_CROSS")
+	}
+	if req.AlertType != models.AlertExpression && req.Expression != "" {
+		errs.add("expression", "only applies to EXPRESSION alerts")
+	}
+	if !alertengine.IsIndicator(req.AlertType) {
+		if req.Interval != "" {
+			errs.add("interval", "only applies to indicator alerts")
+		}
+		if req.Period != 0 || req.SlowPeriod != 0 || req.Multiplier != 0 {
+			errs.add("period", "only applies to indicator alerts")
+		}
+	}
+	if req.AlertType != models.AlertTrailingStop && (req.TrailSide != "" || req.TrailType != "" || req.TrailValue != 0 || req.ActivationPrice != 0) {
+		errs.add("trail_side", "only applies to TRAILING_STOP alerts")
+	}
+	switch {
+	case req.AlertType == models.AlertExpression:
+		checkExpression(&errs, req)
+	case req.AlertType == models.AlertTrailingStop:
+		checkTrail(&errs, req)
+	case alertengine.IsIndicator(req.AlertType):
+		checkIndicator(&errs, req)
+	case alertengine.IsPortfolio(req.AlertType):
+		checkPortfolio(&errs, req)
+	default:
+		switch {
+		case req.Condition == "":
+			errs.add("condition", "is required")
+		case !alertConditions[req.Condition]:
+			errs.add("condition", "must be one of >, <, >=, <=")
+		}
+		if !errs.has("alert_type") && !errs.has("condition") {
+			checkTarget(&errs, req)
+		}
+	}
+	checkBaseline(&errs, req)
+	checkPolicy(&errs, req, now)
+
+	switch {
+	case req.OptionType != "" && alertengine.IsPortfolio(req.AlertType):
+		errs.add("option_type", "does not apply to %s alerts, positions are named by symbol", req.AlertType)
+	case req.OptionType != "":
+		checkOption(&errs, req, now)
+	default:
+		if req.UnderlyingSymbol != "" {
+			errs.add("underlying_symbol", "only applies to options")
+		}
+		if req.StrikePrice != 0 {
+			errs.add("strike_price", "only applies to options")
+		}
+		if req.Expiry != "" {
+			errs.add("expiry", "only applies to options")
+		}
+	}
+	return errs
+}
+
+// checkTarget checks the target value against the alert type and condition
+func checkTarget(errs *fieldErrors, req models.AlertRequest) {
+	rising := req.Condition == ">" || req.Condition == ">="
+	falling := req.Condition == "<" || req.Condition == "<="
+
+	switch req.AlertType {
+	case models.AlertPriceAbove:
+		if !rising {
+			errs.add("condition", "must be > or >= for PRICE_ABOVE alerts")
+		}
+	case models.AlertPriceBelow:
+		if !falling {
+			errs.add("condition", "must be < or <= for PRICE_BELOW alerts")
+		}
+	case models.AlertPercentageChange:
+		switch {
+		case req.TargetValue == 0:
+			errs.add("target_value", "must not be 0 for PERCENTAGE_CHANGE alerts")
+		case req.TargetValue <= -100:
+			errs.add("target_value", "must be above -100, a price cannot fall by 100%% or more")
+		case req.Direction == models.DirectionEither && req.TargetValue < 0:
+			errs.add("target_value", "must be positive for EITHER direction alerts, which compare the size of the move")
+		case req.Direction == models.DirectionEither && !rising:
+			errs.add("condition", "must be > or >= for EITHER direction alerts")
+		case req.Direction != models.DirectionEither && req.TargetValue > 0 && !rising:
+			errs.add("condition", "must be > or >= for a rise (positive target_value)")
+		case req.Direction != models.DirectionEither && req.TargetValue < 0 && !falling:
+			errs.add("condition", "must be < or <= for a fall (negative target_value)")
+		}
+		return
+	}
+	if req.TargetValue <= 0 {
+		errs.add("target_value", "must be a positive price")
+	}
+}
+
+// checkExpression checks the condition of an expression alert, which replaces condition and
+// target_value
+func checkExpression(errs *fieldErrors, req models.AlertRequest) {
+	if req.Condition != "" {
+		errs.add("condition", "does not apply to EXPRESSION alerts, write it in the expression")
+	}
+	if req.TargetValue != 0 {
+		errs.add("target_value", "does not apply to EXPRESSION alerts, write it in the expression")
+	}
+	if req.Expression == "" {
+		errs.add("expression", "is required for EXPRESSION alerts")
+		return
+	}
+	expr, err := alertexpr.Parse(req.Expression)
+	switch {
+	case err != nil:
+		errs.add("expression", "%v", err)
+	case !expr.UsesOwnSymbol() && len(expr.Symbols("")) == 0:
+		errs.add("expression", "must use at least one attribute, such as LTP")
+	}
+}
+
+// checkTrail checks the side, trail and activation price of a trailing stop, which replace
+// condition and target_value
+func checkTrail(errs *fieldErrors, req models.AlertRequest) {
+	if req.Condition != "" {
+		errs.add("condition", "does not apply to TRAILING_STOP alerts, the side decides the direction")
+	}
+	if req.TargetValue != 0 {
+		errs.add("target_value", "does not apply to TRAILING_STOP alerts, the stop follows the price")
+	}
+	switch {
+	case req.TrailSide == "":
+		errs.add("trail_side", "is required for TRAILING_STOP alerts")
+	case !trailSides[req.TrailSide]:
+		errs.add("trail_side", "must be LONG or SHORT")
+	}
+	switch {
+	case !trailTypes[req.TrailType]:
+		errs.add("trail_type", "must be AMOUNT or PERCENT")
+	case req.TrailValue <= 0:
+		errs.add("trail_value", "must be positive")
+	case req.TrailType == models.TrailPercent && req.TrailValue >= 100:
+		errs.add("trail_value", "must be below 100 percent")
+	}
+	if req.ActivationPrice < 0 {
+		errs.add("activation_price", "must be a positive price, or 0 to trail from the first quote")
+	}
+}
+
+// checkIndicator checks the candle interval, parameters and condition of an indicator alert, whose
+// left-out parameters normaliseAlert has filled in
+func checkIndicator(errs *fieldErrors, req models.AlertRequest) {
+	switch {
+	case req.Interval == "":
+		errs.add("interval", "is required for indicator alerts")
+	case candles.Intervals[req.Interval] == 0:
+		errs.add("interval", "must be one of 1m, 5m, 15m, 1h, 1d")
+	case req.AlertType == models.AlertVWAPCross && req.Interval == "1d":
+		errs.add("interval", "must be intraday for VWAP_CROSS alerts, VWAP restarts every day")
+	}
+
+	crossover := req.AlertType == models.AlertSMACross || req.AlertType == models.AlertEMACross
+	switch {
+	case req.AlertType == models.AlertVWAPCross:
+		if req.Period != 0 {
+			errs.add("period", "does not apply to VWAP_CROSS alerts")
+		}
+	case req.Period < 1 || req.Period > maxIndicatorPeriod:
+		errs.add("period", "must be between 1 and %d", maxIndicatorPeriod)
+	}
+	switch {
+	case crossover && (req.SlowPeriod <= req.Period || req.SlowPeriod > maxIndicatorPeriod):
+		errs.add("slow_period", "must be greater than period and at most %d", maxIndicatorPeriod)
+	case !crossover && req.SlowPeriod != 0:
+		errs.add("slow_period", "only applies to SMA_CROSS and EMA_CROSS alerts")
+	}
+	switch banded := req.AlertType == models.AlertBollinger || req.AlertType == models.AlertSupertrend; {
+	case banded && (req.Multiplier <= 0 || req.Multiplier > maxMultiplier):
+		errs.add("multiplier", "must be above 0 and at most %d", maxMultiplier)
+	case !banded && req.Multiplier != 0:
+		errs.add("multiplier", "only applies to BOLLINGER and SUPERTREND alerts")
+	}
+
+	if req.AlertType == models.AlertRSI {
+		switch {
+		case req.Condition != ">" && req.Condition != ">=" && req.Condition != "<" && req.Condition != "<=":
+			errs.add("condition", "must be one of >, <, >=, <= for RSI alerts")
+		case req.TargetValue <= 0 || req.TargetValue >= 100:
+			errs.add("target_value", "must be an RSI level between 0 and 100")
+		}
+		return
+	}
+	if req.Condition != ">" && req.Condition != "<" {
+		errs.add("condition", "must be > (cross, break or turn upwards) or < (downwards) for %s alerts", req.AlertType)
+	}
+	if req.TargetValue != 0 {
+		errs.add("target_value", "does not apply to %s alerts", req.AlertType)
+	}
+}
+
+// checkPortfolio checks the limit of a portfolio alert: DAY_PNL compares the day's P&L using its
+// condition, POSITION_LOSS and DRAWDOWN fire when the loss reaches target_value
+func checkPortfolio(errs *fieldErrors, req models.AlertRequest) {
+	if req.AlertType == models.AlertDayPnL {
+		if req.Condition != ">" && req.Condition != ">=" && req.Condition != "<" && req.Condition != "<=" {
+			errs.add("condition", "must be one of >, <, >=, <= for DAY_PNL alerts")
+		}
+		return
+	}
+	if req.Condition != "" {
+		errs.add("condition", "does not apply to %s alerts, which fire when the loss reaches target_value", req.AlertType)
+	}
+	switch {
+	case req.AlertType == models.AlertPositionLoss && req.TargetValue <= 0:
+		errs.add("target_value", "must be a positive loss in percent of the position's cost")
+	case req.AlertType == models.AlertDrawdown && req.TargetValue <= 0:
+		errs.add("target_value", "must be a positive amount below the day's peak P&L")
+	}
+}
+
+// checkExpressionSymbols checks the symbols an expression names, other than the alert's own,
+// against the instruments master. It returns false if a lookup failed.
+func checkExpressionSymbols(errs *fieldErrors, req models.AlertRequest) bool {
+	if req.AlertType != models.AlertExpression {
+		return true
+	}
+	expr, err := alertexpr.Parse(req.Expression)
+	if err != nil {
+		return true
+	}
+	for _, symbol := range expr.Symbols(req.Symbol) {
+		if symbol == req.Symbol {
+			continue
+		}
+		switch _, err := instruments.Resolve(symbol, false); err {
+		case nil, instruments.ErrNotLoaded:
+		case instruments.ErrUnknownSymbol:
+			errs.add("expression", "%s is not in the instruments master", symbol)
+		default:
+			log.Printf("Failed to look up instrument %s: %v", symbol, err)
+			return false
+		}
+	}
+	return true
+}
+
+// checkAlertChannels checks that the selected notification channels are the user's own; it
+// returns false if they could not be looked up
+func checkAlertChannels(errs *fieldErrors, userID int, req models.AlertRequest) bool {
+	seen := make(map[int]bool, len(req.Channels))
+	for _, id := range req.Channels {
+		if seen[id] {
+			errs.add("channels", "channel %d is selected twice", id)
+			continue
+		}
+		seen[id] = true
+		switch _, err := db.GetNotificationChannel(userID, id); err {
+		case nil:
+		case sql.ErrNoRows:
+			errs.add("channels", "channel %d does not exist", id)
+		default:
+			log.Printf("Failed to look up notification channel %d: %v", id, err)
+			return false
+		}
+	}
+	return true
+}
+
+// checkBaseline checks the baseline fields, which only apply to percentage change alerts
+func checkBaseline(errs *fieldErrors, req models.AlertRequest) {
+	if req.AlertType != models.AlertPercentageChange {
+		if req.BaselineType != "" || req.BaselineValue != 0 {
+			errs.add("baseline_type", "only applies to PERCENTAGE_CHANGE alerts")
+		}
+		if req.Direction != "" {
+			errs.add("direction", "only applies to PERCENTAGE_CHANGE alerts")
+		}
+		return
+	}
+
+	if req.BaselineType != "" && !baselineTypes[req.BaselineType] {
+		errs.add("baseline_type", "must be PREVIOUS_CLOSE, CREATION_PRICE, DAY_OPEN or FIXED")
+	}
+	if req.Direction != "" && !directions[req.Direction] {
+		errs.add("direction", "must be SIGNED or EITHER")
+	}
+	switch {
+	case req.BaselineType == models.BaselineFixed && req.BaselineValue <= 0:
+		errs.add("baseline_value", "must be a positive price for FIXED baselines")
+	case req.BaselineType != models.BaselineFixed && req.BaselineValue != 0:
+		errs.add("baseline_value", "only applies to FIXED baselines")
+	}
+}
+
+// checkPolicy checks the trigger policy, cooldown and expiry
+func checkPolicy(errs *fieldErrors, req models.AlertRequest, now time.Time) {
+	switch {
+	case !triggerPolicies[req.TriggerPolicy]:
+		errs.add("trigger_policy", "must be ONCE, RECURRING or DAILY")
+	case req.TriggerPolicy == models.PolicyRecurring && req.CooldownSeconds <= 0:
+		errs.add("cooldown_seconds", "must be a positive number of seconds for RECURRING alerts")
+	case req.TriggerPolicy != models.PolicyRecurring && req.CooldownSeconds != 0:
+		errs.add("cooldown_seconds", "only applies to RECURRING alerts")
+	}
+	if req.ValidUntil != nil && !req.ValidUntil.After(now) {
+		errs.add("valid_until", "must be in the future")
+	}
+}
+
+// checkOption checks the option fields; expiries are dates on the IST calendar
+func checkOption(errs *fieldErrors, req models.AlertRequest, now time.Time) {
+	if _, ok := optionTypes[req.OptionType]; !ok {
+		errs.add("option_type", "must be CALL or PUT")
+	}
+	if req.UnderlyingSymbol == "" {
+		errs.add("underlying_symbol", "is required for options")
+	}
+	if req.StrikePrice <= 0 {
+		errs.add("strike_price", "must be a positive price for options")
+	}
+
+	if req.Expiry == "" {
+		errs.add("expiry", "is required for options")
+		return
+	}
+	expiry, err := time.ParseInLocation(market.DateFormat, req.Expiry, market.IST)
+	if err != nil {
+		errs.add("expiry", "must be a date in YYYY-MM-DD format")
+		return
+	}
+	y, m, d := now.In(market.IST).Date()
+	if expiry.Before(time.Date(y, m, d, 0, 0, 0, 0, market.IST)) {
+		errs.add("expiry", "%s is in the past", req.Expiry)
+	}
+}
+
+// checkInstrument validates the symbol against the instruments master and checks that option
+// details match the listed contract. Without a master every symbol is accepted. It returns false
+// if the lookup failed.
+func checkInstrument(errs *fieldErrors, req models.AlertRequest, now time.Time) (models.Instrument, bool) {
+	inst, err := instruments.Resolve(req.Symbol, req.OptionType != "")
+	switch err {
+	case nil:
+	case instruments.ErrNotLoaded:
+		return inst, true
+	case instruments.ErrUnknownSymbol:
+		errs.add("symbol", "%s is not in the instruments master", req.Symbol)
+		return inst, true
+	default:
+		log.Printf("Failed to look up instrument %s: %v", req.Symbol, err)
+		return inst, false
+	}
+
+	isOption := inst.InstrumentType == "CE" || inst.InstrumentType == "PE"
+	switch {
+	case isOption && req.OptionType == "":
+		errs.add("option_type", "is required, %s is an option", inst.TradingSymbol)
+	case !isOption && req.OptionType != "":
+		errs.add("option_type", "%s is not an option", inst.TradingSymbol)
+	case isOption:
+		if want, ok := optionTypes[req.OptionType]; ok && want != inst.InstrumentType {
+			listed := "CALL"
+			if inst.InstrumentType == "PE" {
+				listed = "PUT"
+			}
+			errs.add("option_type", "%s is a %s option", inst.TradingSymbol, listed)
+		}
+		if req.StrikePrice > 0 && req.StrikePrice != inst.Strike {
+			errs.add("strike_price", "%s has strike %g", inst.TradingSymbol, inst.Strike)
+		}
+		if req.Expiry != "" && inst.Expiry != "" && req.Expiry != inst.Expiry {
+			errs.add("expiry", "%s expires on %s", inst.TradingSymbol, inst.Expiry)
+		}
+	}
+	if inst.Expiry != "" && inst.Expiry < now.In(market.IST).Format(market.DateFormat) && !errs.has("expiry") {
+		errs.add("expiry", "%s expired on %s", inst.TradingSymbol, inst.Expiry)
+	}
+	return inst, true
+}
+
+// checkAlertQuota checks the user's alert count, in total and per symbol, not counting the alert
+// being edited
+func checkAlertQuota(userID, alertID int, symbol string) (*models.FieldError, error) {
+	total, err := db.CountUserAlerts(userID, "", alertID)
+	if err != nil {
+		return nil, err
+	}
+	if limit := alertQuota("ALERT_MAX_PER_USER", defaultMaxAlertsPerUser); total >= limit {
+		return &models.FieldError{Message: fmt.Sprintf("you can have at most %d alerts", limit)}, nil
+	}
+
+	// Alerts on the whole portfolio or any position have no symbol
+	if symbol == "" {
+		return nil, nil
+	}
+	perSymbol, err := db.CountUserAlerts(userID, symbol, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if limit := alertQuota("ALERT_MAX_PER_SYMBOL", defaultMaxAlertsPerSymbol); perSymbol >= limit {
+		return &models.FieldError{Field: "symbol", Message: fmt.Sprintf("you can have at most %d alerts on %s", limit, symbol)}, nil
+	}
+	return nil, nil
+}
+
+// alertQuota reads a positive quota from the environment
+func alertQuota(name string, defaultValue int) int {
+	if value := os.Getenv(name); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("⚠️  Invalid %s %q, using %d", name, value, defaultValue)
+	}
+	return defaultValue
+}
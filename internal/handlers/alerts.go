@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/vinaykotian/stock-panel/internal/alertengine"
+	"github.com/vinaykotian/stock-panel/internal/alertexpr"
+	"github.com/vinaykotian/stock-panel/internal/alertsync"
 	"github.com/vinaykotian/stock-panel/internal/db"
 	"github.com/vinaykotian/stock-panel/internal/kite"
+	"github.com/vinaykotian/stock-panel/internal/marketdata"
 	"github.com/vinaykotian/stock-panel/internal/models"
 )
 
@@ -38,20 +43,28 @@ func CreateAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if alertReq.Symbol == "" || alertReq.AlertType == "" || alertReq.Condition == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(models.AlertResponse{
-			Success: false,
-			Message: "Symbol, alert type, and condition are required",
-		})
+	// Validate the alert and fill in exchange, instrument token and lot size
+	inst, ok := validateAlert(w, userID, 0, &alertReq)
+	if !ok {
+		return
+	}
+	if inst.TradingSymbol != "" {
+		alertReq.Symbol = inst.TradingSymbol
+	}
+
+	baseline, ok := resolveBaseline(w, alertReq, inst.Exchange, nil)
+	if !ok {
 		return
 	}
 
 	// Insert alert into database
 	result, err := db.DB.Exec(
-		"INSERT INTO alerts (symbol, underlying_symbol, option_type, strike_price, expiry, alert_type, target_value, condition, message, is_active, created_at, updated_at, user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		alertReq.Symbol, alertReq.UnderlyingSymbol, alertReq.OptionType, alertReq.StrikePrice, alertReq.Expiry, alertReq.AlertType, alertReq.TargetValue, alertReq.Condition, alertReq.Message, true, time.Now(), time.Now(), userID,
+		"INSERT INTO alerts (symbol, underlying_symbol, option_type, strike_price, expiry, alert_type, target_value, condition, message, is_active, created_at, updated_at, user_id, exchange, instrument_token, lot_size, baseline_type, baseline_value, baseline_date, direction, expression, interval, period, slow_period, multiplier, trail_side, trail_type, trail_value, activation_price, trigger_policy, cooldown_seconds, valid_until, channels) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		alertReq.Symbol, alertReq.UnderlyingSymbol, alertReq.OptionType, alertReq.StrikePrice, alertReq.Expiry, alertReq.AlertType, alertReq.TargetValue, alertReq.Condition, alertReq.Message, true, time.Now(), time.Now(), userID, inst.Exchange, inst.InstrumentToken, inst.LotSize,
+		baseline.BaselineType, baseline.BaselineValue, baseline.BaselineDate, baseline.Direction, alertReq.Expression,
+		alertReq.Interval, alertReq.Period, alertReq.SlowPeriod, alertReq.Multiplier,
+		alertReq.TrailSide, alertReq.TrailType, alertReq.TrailValue, alertReq.ActivationPrice,
+		alertReq.TriggerPolicy, alertReq.CooldownSeconds, unixOrNil(alertReq.ValidUntil), db.ChannelsOrNil(alertReq.Channels),
 	)
 	if err != nil {
 		log.Printf("Failed to insert alert: %v", err)
@@ -66,61 +79,70 @@ func CreateAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create response
-	alert := &models.Alert{
-		ID:               int(alertID),
-		Symbol:           alertReq.Symbol,
-		UnderlyingSymbol: alertReq.UnderlyingSymbol,
-		OptionType:       alertReq.OptionType,
-		StrikePrice:      alertReq.StrikePrice,
-		Expiry:           alertReq.Expiry,
-		AlertType:        alertReq.AlertType,
-		TargetValue:      alertReq.TargetValue,
-		Condition:        alertReq.Condition,
-		Message:          alertReq.Message,
-		IsActive:         true,
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
-		UserID:           userID,
-	}
-
-	// Send alert to Kite 3 API if configured
-	kiteAPIKey := os.Getenv("KITE_API_KEY")
-	kiteAPISecret := os.Getenv("KITE_API_SECRET")
-	kiteBaseURL := os.Getenv("KITE_BASE_URL")
-
-	if kiteAPIKey != "" && kiteAPISecret != "" && kiteBaseURL != "" {
-		kiteService := kite.NewKiteService(kiteAPIKey, kiteAPISecret, kiteBaseURL)
-
-		kiteAlert := kite.AlertPayload{
-			Symbol:           alert.Symbol,
-			UnderlyingSymbol: alert.UnderlyingSymbol,
-			OptionType:       alert.OptionType,
-			StrikePrice:      alert.StrikePrice,
-			Expiry:           alert.Expiry,
-			AlertType:        alert.AlertType,
-			TargetValue:      alert.TargetValue,
-			Condition:        alert.Condition,
-			Message:          alert.Message,
-			Timestamp:        alert.CreatedAt.Format(time.RFC3339),
-			UserID:           alert.UserID,
-		}
+	// Queue sending the alert to Kite 3 API; the outbox retries failures
+	if err := alertsync.QueuePush(userID, int(alertID)); err != nil {
+		log.Printf("Warning: Failed to queue Kite sync for alert %d: %v", alertID, err)
+	}
+	alertengine.Refresh(int(alertID))
+	watchAlertSymbols(userID, alertReq)
 
-		if err := kiteService.SendAlert(kiteAlert); err != nil {
-			log.Printf("Warning: Failed to send alert to Kite API: %v", err)
-			// Continue with the response even if Kite API fails
-		}
+	alert, err := db.GetAlert(int(alertID))
+	if err != nil {
+		log.Printf("Failed to load alert: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+	alertengine.SetState(&alert, time.Now())
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(models.AlertResponse{
 		Success: true,
 		Message: "Alert created successfully",
-		Alert:   alert,
+		Alert:   &alert,
 	})
 }
 
+// resolveBaseline takes the reference price of a percentage change alert, writing an error response
+// if the baseline is invalid. An edited alert keeps its stored baseline unless the symbol or
+// baseline type changed.
+func resolveBaseline(w http.ResponseWriter, alertReq models.AlertRequest, exchange string, stored *models.Alert) (models.Alert, bool) {
+	baseline := models.Alert{
+		Symbol:        alertReq.Symbol,
+		Exchange:      exchange,
+		AlertType:     alertReq.AlertType,
+		BaselineType:  alertReq.BaselineType,
+		BaselineValue: alertReq.BaselineValue,
+		Direction:     alertReq.Direction,
+	}
+	err := alertengine.ResolveBaseline(&baseline, time.Now())
+	if err == nil && stored != nil && alertengine.KeepsBaseline(*stored, baseline) {
+		baseline.BaselineValue, baseline.BaselineDate = stored.BaselineValue, stored.BaselineDate
+	}
+	if errors.Is(err, alertengine.ErrInvalidBaseline) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AlertResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return baseline, false
+	}
+	if err != nil {
+		log.Printf("Failed to resolve alert baseline: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return baseline, false
+	}
+	return baseline, true
+}
+
+// unixOrNil stores an optional time as unix seconds
+func unixOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
 // GetAlerts handles getting all alerts for a user
 func GetAlerts(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -131,33 +153,15 @@ func GetAlerts(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by AuthMiddleware)
 	userID := r.Context().Value("userID").(int)
 
-	rows, err := db.DB.Query("SELECT id, symbol, underlying_symbol, option_type, strike_price, expiry, alert_type, target_value, condition, message, is_active, created_at, updated_at, user_id FROM alerts WHERE user_id = ? ORDER BY created_at DESC", userID)
+	alerts, err := db.GetUserAlerts(userID)
 	if err != nil {
 		log.Printf("Failed to query alerts: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var alerts []models.Alert
-	for rows.Next() {
-		var alert models.Alert
-		var createdAt, updatedAt string
-		if err := rows.Scan(&alert.ID, &alert.Symbol, &alert.UnderlyingSymbol, &alert.OptionType, &alert.StrikePrice, &alert.Expiry, &alert.AlertType, &alert.TargetValue, &alert.Condition, &alert.Message, &alert.IsActive, &createdAt, &updatedAt, &alert.UserID); err != nil {
-			log.Printf("Failed to scan alert: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		// Parse timestamps
-		if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
-			alert.CreatedAt = t
-		}
-		if t, err := time.Parse(time.RFC3339Nano, updatedAt); err == nil {
-			alert.UpdatedAt = t
-		}
-
-		alerts = append(alerts, alert)
+	now := time.Now()
+	for i := range alerts {
+		alertengine.SetState(&alerts[i], now)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -212,10 +216,45 @@ func UpdateAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate the alert and fill in exchange, instrument token and lot size
+	inst, ok := validateAlert(w, userID, alertID, &alertReq)
+	if !ok {
+		return
+	}
+	if inst.TradingSymbol != "" {
+		alertReq.Symbol = inst.TradingSymbol
+	}
+
+	var stored *models.Alert
+	if a, err := db.GetAlert(alertID); err == nil && a.UserID == userID {
+		stored = &a
+	}
+	baseline, ok := resolveBaseline(w, alertReq, inst.Exchange, stored)
+	if !ok {
+		return
+	}
+
+	// An edited trailing stop keeps its trail unless its definition changed
+	trail := models.Alert{
+		Symbol:          alertReq.Symbol,
+		AlertType:       alertReq.AlertType,
+		TrailSide:       alertReq.TrailSide,
+		TrailType:       alertReq.TrailType,
+		TrailValue:      alertReq.TrailValue,
+		ActivationPrice: alertReq.ActivationPrice,
+	}
+	if stored != nil && alertengine.KeepsTrail(*stored, trail) {
+		trail.TrailExtreme, trail.TrailLevel = stored.TrailExtreme, stored.TrailLevel
+	}
+
 	// Update alert in database
 	result, err := db.DB.Exec(
-		"UPDATE alerts SET symbol = ?, underlying_symbol = ?, option_type = ?, strike_price = ?, expiry = ?, alert_type = ?, target_value = ?, condition = ?, message = ?, updated_at = ? WHERE id = ? AND user_id = ?",
-		alertReq.Symbol, alertReq.UnderlyingSymbol, alertReq.OptionType, alertReq.StrikePrice, alertReq.Expiry, alertReq.AlertType, alertReq.TargetValue, alertReq.Condition, alertReq.Message, time.Now(), alertID, userID,
+		"UPDATE alerts SET symbol = ?, underlying_symbol = ?, option_type = ?, strike_price = ?, expiry = ?, alert_type = ?, target_value = ?, condition = ?, message = ?, exchange = ?, instrument_token = ?, lot_size = ?, baseline_type = ?, baseline_value = ?, baseline_date = ?, direction = ?, expression = ?, interval = ?, period = ?, slow_period = ?, multiplier = ?, trail_side = ?, trail_type = ?, trail_value = ?, activation_price = ?, trail_extreme = ?, trail_level = ?, trigger_policy = ?, cooldown_seconds = ?, valid_until = ?, channels = ?, updated_at = ? WHERE id = ? AND user_id = ?",
+		alertReq.Symbol, alertReq.UnderlyingSymbol, alertReq.OptionType, alertReq.StrikePrice, alertReq.Expiry, alertReq.AlertType, alertReq.TargetValue, alertReq.Condition, alertReq.Message, inst.Exchange, inst.InstrumentToken, inst.LotSize,
+		baseline.BaselineType, baseline.BaselineValue, baseline.BaselineDate, baseline.Direction, alertReq.Expression,
+		alertReq.Interval, alertReq.Period, alertReq.SlowPeriod, alertReq.Multiplier,
+		alertReq.TrailSide, alertReq.TrailType, alertReq.TrailValue, alertReq.ActivationPrice, trail.TrailExtreme, trail.TrailLevel,
+		alertReq.TriggerPolicy, alertReq.CooldownSeconds, unixOrNil(alertReq.ValidUntil), db.ChannelsOrNil(alertReq.Channels), time.Now(), alertID, userID,
 	)
 	if err != nil {
 		log.Printf("Failed to update alert: %v", err)
@@ -239,6 +278,12 @@ func UpdateAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := alertsync.QueuePush(userID, alertID); err != nil {
+		log.Printf("Warning: Failed to queue Kite sync for alert %d: %v", alertID, err)
+	}
+	alertengine.Refresh(alertID)
+	watchAlertSymbols(userID, alertReq)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.AlertResponse{
 		Success: true,
@@ -277,6 +322,10 @@ func DeleteAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remember the Kite copy before the row is gone
+	var kiteUUID sql.NullString
+	db.DB.QueryRow("SELECT kite_uuid FROM alerts WHERE id = ? AND user_id = ?", alertID, userID).Scan(&kiteUUID)
+
 	// Delete alert from database
 	result, err := db.DB.Exec("DELETE FROM alerts WHERE id = ? AND user_id = ?", alertID, userID)
 	if err != nil {
@@ -301,6 +350,11 @@ func DeleteAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	alertengine.Remove(alertID)
+	if err := alertsync.QueueRemove(userID, alertID, kiteUUID.String); err != nil {
+		log.Printf("Warning: Failed to queue Kite delete for alert %d: %v", alertID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.AlertResponse{
 		Success: true,
@@ -366,6 +420,12 @@ func ToggleAlert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Deactivated alerts are removed from Kite, reactivated ones created again
+	if err := alertsync.QueuePush(userID, alertID); err != nil {
+		log.Printf("Warning: Failed to queue Kite sync for alert %d: %v", alertID, err)
+	}
+	alertengine.Refresh(alertID)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.AlertResponse{
 		Success: true,
@@ -380,22 +440,23 @@ func TestKiteAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	kiteAPIKey := os.Getenv("KITE_API_KEY")
-	kiteAPISecret := os.Getenv("KITE_API_SECRET")
-	kiteBaseURL := os.Getenv("KITE_BASE_URL")
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
 
-	if kiteAPIKey == "" || kiteAPISecret == "" || kiteBaseURL == "" {
+	kiteService, err := kite.ForUser(userID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "Kite API credentials not configured. Please set KITE_API_KEY, KITE_API_SECRET, and KITE_BASE_URL environment variables.",
+			"success":        false,
+			"message":        err.Error(),
+			"login_required": err == kite.ErrLoginRequired,
 		})
 		return
 	}
 
-	kiteService := kite.NewKiteService(kiteAPIKey, kiteAPISecret, kiteBaseURL)
-
 	if err := kiteService.TestConnection(); err != nil {
+		kite.CheckTokenError(userID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -410,3 +471,50 @@ func TestKiteAPI(w http.ResponseWriter, r *http.Request) {
 		"message": "Successfully connected to Kite API",
 	})
 }
+
+// ReconcileAlerts compares the user's alerts with Kite and repairs any drift
+func ReconcileAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	report, err := alertsync.Reconcile(userID)
+	if err == kite.ErrLoginRequired || err == kite.ErrNotConfigured {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reconcile alerts: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "Failed to reconcile alerts with Kite: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// watchAlertSymbols watches an alert's symbol and the other symbols its expression reads
+func watchAlertSymbols(userID int, req models.AlertRequest) {
+	// Portfolio alerts read the positions' symbols, which are watched with their trades
+	if req.Symbol == "" {
+		return
+	}
+	watchSymbol(userID, req.Symbol)
+	if req.AlertType != models.AlertExpression {
+		return
+	}
+	if expr, err := alertexpr.Parse(req.Expression); err == nil {
+		for _, symbol := range expr.Symbols(req.Symbol) {
+			if symbol != req.Symbol {
+				marketdata.Watch(symbol)
+			}
+		}
+	}
+}
@@ -29,6 +29,25 @@ var (
 
 	// Email service instance
 	emailService = email.NewEmailService()
+
+	// API path prefixes that require a bearer token and get the user in context
+	protectedAPIPrefixes = []string{
+		"/stocks",
+		"/pnl",
+		"/alerts",
+		"/portfolio",
+		"/benchmarks",
+		"/ledger",
+		"/returns",
+		"/quotes",
+		"/candles",
+		"/kite/",
+		"/outbox",
+		"/instruments",
+		"/market/",
+		"/events",
+		"/notification-channels",
+	}
 )
 
 // resetTokenData stores reset token information
@@ -98,9 +117,14 @@ func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		log.Printf("📋 [HEADERS] Content-Type: %s, Accept: %s, Content-Length: %s",
 			r.Header.Get("Content-Type"), r.Header.Get("Accept"), r.Header.Get("Content-Length"))
 
-		// Log query parameters if any
-		if len(r.URL.Query()) > 0 {
-			log.Printf("🔍 [QUERY] %s", r.URL.RawQuery)
+		// Log query parameters if any, masking tokens
+		if query := r.URL.Query(); len(query) > 0 {
+			rawQuery := r.URL.RawQuery
+			if query.Has("token") {
+				query.Set("token", "REDACTED")
+				rawQuery = query.Encode()
+			}
+			log.Printf("🔍 [QUERY] %s", rawQuery)
 		}
 
 		// Log request body for POST/PUT requests (if it's JSON)
@@ -148,8 +172,12 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// For API requests, check Authorization header
-		if strings.HasPrefix(r.URL.Path, "/stocks") || strings.HasPrefix(r.URL.Path, "/pnl") || strings.HasPrefix(r.URL.Path, "/alerts") {
+		if isProtectedAPIPath(r.URL.Path) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" && r.URL.Path == "/events" && r.URL.Query().Get("token") != "" {
+				// EventSource cannot send headers
+				authHeader = "Bearer " + r.URL.Query().Get("token")
+			}
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 				w.WriteHeader(http.StatusUnauthorized)
 				w.Write([]byte(`{"error": "Unauthorized"}`))
@@ -190,6 +218,16 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// isProtectedAPIPath reports whether the path belongs to an authenticated API
+func isProtectedAPIPath(path string) bool {
+	for _, prefix := range protectedAPIPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // HandleLogin processes login requests
 func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	log.Printf("HandleLogin called with method: %s", r.Method)
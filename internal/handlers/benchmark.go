@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+	"github.com/vinaykotian/stock-panel/internal/prices"
+)
+
+// defaultBenchmarkCapital is the equity base used when no capital is given
+const defaultBenchmarkCapital = 100000
+
+// LoadBenchmark loads a daily close series for a benchmark index.
+// Accepts a JSON BenchmarkRequest, or a text/csv body of "date,close" rows with ?symbol=.
+func LoadBenchmark(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req models.BenchmarkRequest
+	if strings.Contains(r.Header.Get("Content-Type"), "text/csv") {
+		req.Symbol = r.URL.Query().Get("symbol")
+		req.Prices, err = parseDailyPriceCSV(string(body))
+	} else {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid benchmark data: "+err.Error())
+		return
+	}
+	if req.Symbol == "" || len(req.Prices) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Symbol and at least one price are required")
+		return
+	}
+	for _, p := range req.Prices {
+		if p.Close <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid benchmark data: close on "+p.Date+" must be positive")
+			return
+		}
+	}
+
+	if err := prices.SaveDailyCloses(req.Symbol, req.Prices); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.Printf("📈 Loaded %d daily prices for benchmark %s", len(req.Prices), req.Symbol)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Loaded " + strconv.Itoa(len(req.Prices)) + " prices for " + req.Symbol,
+	})
+}
+
+// GetBenchmarks lists the loaded benchmark series
+func GetBenchmarks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	series, err := prices.Symbols()
+	if err != nil {
+		log.Printf("Failed to list benchmarks: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"benchmarks": series,
+	})
+}
+
+// GetBenchmarkComparison returns the user's equity curve next to a benchmark's normalized return
+// (?symbol=NIFTY 50&from=YYYY-MM-DD&to=YYYY-MM-DD&capital=100000)
+func GetBenchmarkComparison(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	to := query.Get("to")
+	if to == "" {
+		to = time.Now().In(market.IST).Format(portfolio.DateFormat)
+	}
+	from := query.Get("from")
+	if from == "" {
+		from = "0000-01-01"
+	}
+
+	capital := float64(defaultBenchmarkCapital)
+	if capitalStr := query.Get("capital"); capitalStr != "" {
+		c, err := strconv.ParseFloat(capitalStr, 64)
+		if err != nil || c <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "capital must be a positive number")
+			return
+		}
+		capital = c
+	}
+
+	response, err := portfolio.CompareToBenchmark(userID, symbol, from, to, capital)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseDailyPriceCSV parses "date,close" rows; a header row is skipped
+func parseDailyPriceCSV(data string) ([]models.DailyPrice, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var closes []models.DailyPrice
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		closePrice, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, err
+		}
+		closes = append(closes, models.DailyPrice{Date: strings.TrimSpace(record[0]), Close: closePrice})
+	}
+	return closes, nil
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/marketdata"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+var (
+	errInvalidTime  = errors.New("times must be RFC 3339 timestamps or YYYY-MM-DD dates")
+	errInvalidRange = errors.New("from must be before to")
+)
+
+// GetCandles returns OHLCV candles (?symbol=&interval=5m&from=&to=).
+// from and to accept RFC 3339 timestamps or YYYY-MM-DD dates (IST); to defaults to now and
+// from to one day earlier (one year for daily candles).
+func GetCandles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	if interval == "" {
+		interval = "1m"
+	}
+	if _, ok := candles.Intervals[interval]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h, 1d")
+		return
+	}
+
+	lookback := 24 * time.Hour
+	if interval == "1d" {
+		lookback = 365 * 24 * time.Hour
+	}
+	from, to, err := parseTimeRange(query.Get("from"), query.Get("to"), lookback)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := candles.Query(symbol, interval, from, to)
+	if err != nil {
+		log.Printf("Failed to query candles: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CandlesResponse{
+		Success:  true,
+		Symbol:   symbol,
+		Interval: interval,
+		Candles:  result,
+	})
+}
+
+// RebuildCandles recomputes candles for a symbol from stored raw quotes (?symbol=&from=&to=)
+func RebuildCandles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	from, to, err := parseTimeRange(query.Get("from"), query.Get("to"), 24*time.Hour)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, err := candles.Rebuild(symbol, candles.CalendarFor(symbol, query.Get("exchange")), from, to)
+	if err != nil {
+		log.Printf("Failed to rebuild candles: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Rebuilt " + strconv.Itoa(count) + " candles for " + symbol,
+	})
+}
+
+// maxCandleUpload bounds the size of an uploaded candle CSV
+const maxCandleUpload = 50 << 20
+
+// ImportCandles loads historical candles from a CSV upload (?symbol=&interval=&exchange=).
+// The CSV is either the request body (text/csv) or the "file" field of a multipart form.
+// exchange picks the trading calendar for the gap report; it defaults to the instrument's exchange.
+func ImportCandles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	if _, ok := candles.Intervals[interval]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h, 1d")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCandleUpload)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "multipart upload needs a file field")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := candles.Import(symbol, interval, candles.CalendarFor(symbol, query.Get("exchange")), body)
+	if err != nil {
+		log.Printf("Failed to import candles for %s: %v", symbol, err)
+		writeJSONError(w, http.StatusBadRequest, "Failed to import candles: "+err.Error())
+		return
+	}
+	log.Printf("🕯️ Imported %d %s candles for %s (%d new, %d replaced, %d missing)",
+		result.Inserted+result.Updated, interval, symbol, result.Inserted, result.Updated, result.MissingCandles)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// BackfillCandles fetches candles from the market-data provider and stores them
+// (?symbol=&interval=&from=&to=, default the last 30 days)
+func BackfillCandles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider := marketdata.Current()
+	if provider == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "No market data provider is configured; set MARKETDATA_PROVIDER")
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	if _, ok := candles.Intervals[interval]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h, 1d")
+		return
+	}
+	from, to, err := parseTimeRange(query.Get("from"), query.Get("to"), 30*24*time.Hour)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fetched, err := provider.Historical(marketdata.Lookup(symbol), interval, from, to)
+	if err != nil {
+		log.Printf("Failed to fetch %s candles for %s from %s: %v", interval, symbol, provider.Name(), err)
+		writeJSONError(w, http.StatusBadGateway, "Failed to fetch candles: "+err.Error())
+		return
+	}
+	inserted, updated, err := candles.Save(symbol, interval, fetched)
+	if err != nil {
+		log.Printf("Failed to store candles: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🕯️ Backfilled %d %s candles for %s from %s", len(fetched), interval, symbol, provider.Name())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"provider": provider.Name(),
+		"symbol":   symbol,
+		"interval": interval,
+		"inserted": inserted,
+		"updated":  updated,
+	})
+}
+
+// GetCandleGaps reports missing candles on trading sessions (?symbol=&interval=&from=&to=&exchange=)
+func GetCandleGaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	interval := query.Get("interval")
+	if symbol == "" {
+		writeJSONError(w, http.StatusBadRequest, "symbol is required")
+		return
+	}
+	if _, ok := candles.Intervals[interval]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "interval must be one of 1m, 5m, 15m, 1h, 1d")
+		return
+	}
+	from, to, err := parseTimeRange(query.Get("from"), query.Get("to"), 30*24*time.Hour)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	calendar := candles.CalendarFor(symbol, query.Get("exchange"))
+	gaps, missing, err := candles.Gaps(symbol, interval, calendar, from, to)
+	if err != nil {
+		log.Printf("Failed to find candle gaps: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"symbol":          symbol,
+		"interval":        interval,
+		"exchange":        calendar,
+		"missing_candles": missing,
+		"gaps":            gaps,
+	})
+}
+
+// parseTimeRange parses optional from/to parameters, defaulting to the lookback before now
+func parseTimeRange(fromStr, toStr string, lookback time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if toStr != "" {
+		t, err := parseTimeParam(toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+	from := to.Add(-lookback)
+	if fromStr != "" {
+		t, err := parseTimeParam(fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errInvalidRange
+	}
+	return from, to, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date in IST
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, market.IST)
+	if err != nil {
+		return time.Time{}, errInvalidTime
+	}
+	return t, nil
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/events"
+	"github.com/vinaykotian/stock-panel/internal/marketdata"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// eventsHeartbeat keeps idle streams alive through proxies
+const eventsHeartbeat = 25 * time.Second
+
+// StreamEvents streams live updates as Server-Sent Events: "quote" events for the user's open
+// positions, alert symbols and any ?symbols=A,B, plus "alert" and "trade" events of the user.
+// EventSource cannot set headers, so the bearer token may be passed as ?token=.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	userID := r.Context().Value("userID").(int)
+	symbols, err := watchedSymbols(userID)
+	if err != nil {
+		log.Printf("Failed to load watched symbols: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if extra := r.URL.Query().Get("symbols"); extra != "" {
+		for _, symbol := range strings.Split(extra, ",") {
+			symbols = append(symbols, strings.TrimSpace(symbol))
+		}
+	}
+
+	sub := events.Subscribe(userID, symbols)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the browser how soon to reconnect, then send the current prices
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, symbol := range sub.Symbols() {
+		if q, ok, err := quotes.Latest(symbol); err == nil && ok {
+			writeEvent(w, events.TypeQuote, q)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-sub.Events():
+			if e.Type == events.TypeTrade {
+				sub.Watch(e.Symbol)
+			}
+			if err := writeEvent(w, e.Type, e.Data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one SSE event with a JSON payload
+func writeEvent(w http.ResponseWriter, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload)
+	return err
+}
+
+// watchedSymbols returns the symbols of the user's open positions and alerts
+func watchedSymbols(userID int) ([]string, error) {
+	positions, err := portfolio.Positions(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for _, p := range positions {
+		if p.NetQuantity != 0 {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	alerts, err := db.GetUserAlerts(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range alerts {
+		symbols = append(symbols, a.Symbol)
+	}
+	return symbols, nil
+}
+
+// watchSymbol adds a symbol to the user's event streams and the market-data subscriptions
+func watchSymbol(userID int, symbol string) {
+	events.WatchForUser(userID, symbol)
+	marketdata.Watch(symbol)
+}
+
+// publishTrade notifies the user's event streams of a recorded trade
+func publishTrade(s models.Stock) {
+	events.Publish(events.Event{Type: events.TypeTrade, UserID: s.UserID, Symbol: s.Symbol, Data: s})
+}
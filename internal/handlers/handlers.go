@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/vinaykotian/stock-panel/internal/alertengine"
 	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/marketdata"
 	"github.com/vinaykotian/stock-panel/internal/models"
 )
 
@@ -30,16 +32,31 @@ func CollectStock(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	// Validate the symbol and fill in exchange, instrument token and lot size
+	inst, ok := lookupInstrument(w, s.Symbol, s.OptionType != "")
+	if !ok {
+		return
+	}
+	if inst.TradingSymbol != "" {
+		s.Symbol = inst.TradingSymbol
+		s.Exchange = inst.Exchange
+		s.InstrumentToken = inst.InstrumentToken
+		s.LotSize = inst.LotSize
+	}
 	s.Timestamp = time.Now()
+	s.UserID = r.Context().Value("userID").(int)
 	_, err = db.DB.Exec(
-		"INSERT INTO stocks (symbol, underlying_symbol, option_type, strike_price, expiry, price, side, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		s.Symbol, s.UnderlyingSymbol, s.OptionType, s.StrikePrice, s.Expiry, s.Price, s.Side, s.Timestamp,
+		"INSERT INTO stocks (symbol, underlying_symbol, option_type, strike_price, expiry, price, side, timestamp, user_id, exchange, instrument_token, lot_size) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		s.Symbol, s.UnderlyingSymbol, s.OptionType, s.StrikePrice, s.Expiry, s.Price, s.Side, s.Timestamp, s.UserID, s.Exchange, s.InstrumentToken, s.LotSize,
 	)
 	if err != nil {
 		log.Printf("Failed to insert stock: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	publishTrade(s)
+	marketdata.Watch(s.Symbol)
+	alertengine.RefreshPortfolio(s.UserID)
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -48,7 +65,7 @@ func GetStocks(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	rows, err := db.DB.Query("SELECT symbol, underlying_symbol, option_type, strike_price, expiry, price, side, timestamp FROM stocks")
+	rows, err := db.DB.Query("SELECT symbol, underlying_symbol, option_type, strike_price, expiry, price, side, timestamp, COALESCE(exchange, ''), COALESCE(instrument_token, 0), COALESCE(lot_size, 0) FROM stocks")
 	if err != nil {
 		log.Printf("Failed to query stocks: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -59,7 +76,7 @@ func GetStocks(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var s models.Stock
 		var ts string
-		if err := rows.Scan(&s.Symbol, &s.UnderlyingSymbol, &s.OptionType, &s.StrikePrice, &s.Expiry, &s.Price, &s.Side, &ts); err != nil {
+		if err := rows.Scan(&s.Symbol, &s.UnderlyingSymbol, &s.OptionType, &s.StrikePrice, &s.Expiry, &s.Price, &s.Side, &ts, &s.Exchange, &s.InstrumentToken, &s.LotSize); err != nil {
 			log.Printf("Failed to scan stock: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -134,3 +151,13 @@ func GetDailyPnL(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// writeJSONError writes a {"success": false, "message": ...} response with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": message,
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vinaykotian/stock-panel/internal/instruments"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// defaultSearchLimit and maxSearchLimit bound instrument search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchInstruments returns instruments matching ?q= for autocomplete (optional ?exchange= and ?limit=)
+func SearchInstruments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := defaultSearchLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	results, err := instruments.Search(q, r.URL.Query().Get("exchange"), limit)
+	if err != nil {
+		log.Printf("Failed to search instruments: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.InstrumentsResponse{
+		Success:     true,
+		Instruments: results,
+	})
+}
+
+// ImportInstruments refreshes the instruments master from a text/csv body,
+// or from the server's INSTRUMENTS_FILE when there is no body
+func ImportInstruments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var count int
+	var err error
+	source := "request body"
+	if strings.Contains(r.Header.Get("Content-Type"), "text/csv") {
+		count, err = instruments.Import(r.Body)
+	} else {
+		source = instruments.File()
+		count, err = instruments.ImportFile(source)
+	}
+	if err != nil {
+		log.Printf("Failed to import instruments from %s: %v", source, err)
+		writeJSONError(w, http.StatusBadRequest, "Failed to import instruments: "+err.Error())
+		return
+	}
+	log.Printf("📇 Imported %d instruments from %s", count, source)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Imported " + strconv.Itoa(count) + " instruments",
+		"count":   count,
+	})
+}
+
+// lookupInstrument validates a symbol against the instruments master and writes an error response
+// when it is unknown. Validation is skipped (zero instrument, ok) until the master is loaded.
+func lookupInstrument(w http.ResponseWriter, symbol string, derivative bool) (models.Instrument, bool) {
+	inst, err := instruments.Resolve(symbol, derivative)
+	switch err {
+	case nil, instruments.ErrNotLoaded:
+		return inst, true
+	case instruments.ErrUnknownSymbol:
+		writeJSONError(w, http.StatusBadRequest, "Unknown symbol "+symbol+": not in the instruments master")
+	default:
+		log.Printf("Failed to look up instrument %s: %v", symbol, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return inst, false
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/kite"
+)
+
+// kiteLoginState links a pending Kite login redirect back to the user who started it
+type kiteLoginState struct {
+	UserID    int
+	ExpiresAt time.Time
+}
+
+var (
+	kiteLoginStatesMu sync.Mutex
+	kiteLoginStates   = make(map[string]kiteLoginState)
+)
+
+// kiteLoginStateTTL bounds how long a user has to complete the Kite login
+const kiteLoginStateTTL = 10 * time.Minute
+
+// GetKiteLoginURL returns the Kite login URL the browser should navigate to
+func GetKiteLoginURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	service, err := kite.NewServiceFromEnv()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		log.Printf("Failed to generate Kite login state: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	kiteLoginStatesMu.Lock()
+	for s, data := range kiteLoginStates {
+		if time.Now().After(data.ExpiresAt) {
+			delete(kiteLoginStates, s)
+		}
+	}
+	kiteLoginStates[state] = kiteLoginState{UserID: userID, ExpiresAt: time.Now().Add(kiteLoginStateTTL)}
+	kiteLoginStatesMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"login_url": kite.LoginURL(os.Getenv("KITE_LOGIN_URL"), service.APIKey, url.Values{"state": {state}}),
+	})
+}
+
+// HandleKiteCallback completes the Kite login: Kite redirects the browser here with a request_token,
+// which is exchanged for the user's daily access token
+func HandleKiteCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	kiteLoginStatesMu.Lock()
+	loginState, exists := kiteLoginStates[state]
+	delete(kiteLoginStates, state)
+	kiteLoginStatesMu.Unlock()
+
+	if !exists || time.Now().After(loginState.ExpiresAt) {
+		log.Printf("⚠️  Kite callback with unknown or expired state")
+		http.Redirect(w, r, "/web/alerts/?kite=expired", http.StatusFound)
+		return
+	}
+	if query.Get("status") != "success" || query.Get("request_token") == "" {
+		log.Printf("⚠️  Kite login was not completed for user %d: status=%s", loginState.UserID, query.Get("status"))
+		http.Redirect(w, r, "/web/alerts/?kite=failed", http.StatusFound)
+		return
+	}
+
+	service, err := kite.NewServiceFromEnv()
+	if err != nil {
+		log.Printf("❌ Kite callback: %v", err)
+		http.Redirect(w, r, "/web/alerts/?kite=failed", http.StatusFound)
+		return
+	}
+	session, err := service.GenerateSession(query.Get("request_token"))
+	if err != nil {
+		log.Printf("❌ Failed to generate Kite session for user %d: %v", loginState.UserID, err)
+		http.Redirect(w, r, "/web/alerts/?kite=failed", http.StatusFound)
+		return
+	}
+
+	loginTime := time.Now()
+	err = db.SaveKiteSession(db.KiteSession{
+		UserID:      loginState.UserID,
+		KiteUserID:  session.UserID,
+		AccessToken: session.AccessToken,
+		PublicToken: session.PublicToken,
+		LoginTime:   loginTime,
+		ExpiresAt:   kite.SessionExpiry(loginTime),
+	})
+	if err != nil {
+		log.Printf("❌ Failed to store Kite session for user %d: %v", loginState.UserID, err)
+		http.Redirect(w, r, "/web/alerts/?kite=failed", http.StatusFound)
+		return
+	}
+
+	log.Printf("✅ Kite session created for user %d (Kite user %s)", loginState.UserID, session.UserID)
+	kite.NotifyLogin(loginState.UserID)
+	http.Redirect(w, r, "/web/alerts/?kite=connected", http.StatusFound)
+}
+
+// GetKiteSessionStatus reports whether the user has a valid Kite session and when it expires
+func GetKiteSessionStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	response := map[string]interface{}{
+		"success":    true,
+		"configured": os.Getenv("KITE_API_KEY") != "" && os.Getenv("KITE_API_SECRET") != "",
+		"connected":  false,
+	}
+
+	session, err := db.GetKiteSession(userID)
+	switch {
+	case err == sql.ErrNoRows:
+		response["message"] = "Not logged in to Kite"
+	case err != nil:
+		log.Printf("Failed to load Kite session: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case session.Expired():
+		response["kite_user_id"] = session.KiteUserID
+		response["expires_at"] = session.ExpiresAt
+		response["message"] = "Kite session expired, please log in again"
+	default:
+		response["connected"] = true
+		response["kite_user_id"] = session.KiteUserID
+		response["login_time"] = session.LoginTime
+		response["expires_at"] = session.ExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// KiteLogout invalidates the user's access token on Kite and forgets it
+func KiteLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	if service, err := kite.ForUser(userID); err == nil {
+		if err := service.InvalidateSession(); err != nil {
+			log.Printf("Warning: Failed to invalidate Kite session: %v", err)
+		}
+	}
+	if err := db.DeleteKiteSession(userID); err != nil {
+		log.Printf("Failed to delete Kite session: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Logged out of Kite",
+	})
+}
+
+// StartKiteSessionExpiryCheck starts a goroutine that runs just after 6 AM IST, when Kite invalidates
+// every access token, and emails users whose session just expired a prompt to log in again
+func StartKiteSessionExpiryCheck() {
+	go func() {
+		for {
+			next := kite.SessionExpiry(time.Now()).Add(1 * time.Minute)
+			time.Sleep(time.Until(next))
+
+			userIDs, err := db.KiteSessionsExpiredSince(next.Add(-24 * time.Hour))
+			if err != nil {
+				log.Printf("❌ Failed to check Kite sessions: %v", err)
+				continue
+			}
+			for _, userID := range userIDs {
+				promptKiteRelogin(userID)
+			}
+		}
+	}()
+}
+
+// promptKiteRelogin tells a user their Kite session expired
+func promptKiteRelogin(userID int) {
+	log.Printf("🔑 Kite session expired for user %d, re-login required", userID)
+	if !emailService.IsEmailConfigured() {
+		return
+	}
+	var to string
+	if err := db.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&to); err != nil {
+		log.Printf("Failed to look up email for user %d: %v", userID, err)
+		return
+	}
+	body := `
+		<html>
+		<body>
+			<h2>Kite Session Expired</h2>
+			<p>Your daily Kite Connect session expired at 6:00 AM IST.</p>
+			<p>Alerts will not sync to Kite until you log in again from the <a href="http://localhost:8080/web/alerts/">alerts page</a>.</p>
+			<br>
+			<p>Best regards,<br>Stock Panel Team</p>
+		</body>
+		</html>
+	`
+	if err := emailService.SendEmail(to, "Kite Login Required - Stock Panel", body); err != nil {
+		log.Printf("Failed to send Kite re-login email to %s: %v", to, err)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+)
+
+// AddLedgerEntry handles recording a deposit, withdrawal, dividend or charge
+func AddLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req models.LedgerEntryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("Failed to unmarshal ledger request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !portfolio.ValidLedgerType(req.EntryType) {
+		writeJSONError(w, http.StatusBadRequest, "entry_type must be one of DEPOSIT, WITHDRAWAL, DIVIDEND, CHARGE")
+		return
+	}
+	if req.Amount <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	if req.Date == "" {
+		req.Date = time.Now().In(market.IST).Format(portfolio.DateFormat)
+	} else if _, err := time.Parse(portfolio.DateFormat, req.Date); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+		return
+	}
+
+	id, err := portfolio.AddLedgerEntry(userID, req)
+	if err != nil {
+		log.Printf("Failed to add ledger entry: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Ledger entry added successfully",
+		"id":      id,
+	})
+}
+
+// GetLedger returns the user's ledger entries with running balance (?to=YYYY-MM-DD)
+func GetLedger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = "9999-12-31"
+	}
+
+	entries, err := portfolio.LedgerEntries(userID, to)
+	if err != nil {
+		log.Printf("Failed to load ledger: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := models.LedgerResponse{Success: true, Entries: entries}
+	if len(entries) > 0 {
+		response.Balance = entries[len(entries)-1].Balance
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteLedgerEntry handles removing a ledger entry
+func DeleteLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	entryID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ledger entry ID")
+		return
+	}
+
+	deleted, err := portfolio.DeleteLedgerEntry(userID, entryID)
+	if err != nil {
+		log.Printf("Failed to delete ledger entry: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Ledger entry not found or you don't have permission to delete it")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Ledger entry deleted successfully",
+	})
+}
+
+// GetReturns returns XIRR and time-weighted returns over a period (?from=YYYY-MM-DD&to=YYYY-MM-DD).
+// The period defaults to the current calendar year to date.
+func GetReturns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	now := time.Now().In(market.IST)
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, market.IST).Format(portfolio.DateFormat)
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = now.Format(portfolio.DateFormat)
+	}
+
+	response, err := portfolio.Returns(userID, from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// GetMarketStatus reports trading day, session and next open for ?exchange= (default: every calendar).
+// ?at= (RFC 3339) asks about another time.
+func GetMarketStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	at := time.Now()
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		t, err := time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "at must be an RFC 3339 time")
+			return
+		}
+		at = t
+	}
+
+	calendars := market.Calendars
+	if exchange := r.URL.Query().Get("exchange"); exchange != "" {
+		calendars = []string{market.Calendar(exchange)}
+	}
+
+	response := models.MarketStatusResponse{Success: true}
+	for _, calendar := range calendars {
+		response.Markets = append(response.Markets, market.Status(calendar, at))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMarketHolidays lists the holidays of ?exchange= (default NSE) in ?year= (default this year)
+func GetMarketHolidays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	calendar := market.Calendar(r.URL.Query().Get("exchange"))
+	year := time.Now().In(market.IST).Year()
+	if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+		y, err := strconv.Atoi(yearStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "year must be a number")
+			return
+		}
+		year = y
+	}
+
+	holidays, err := market.Holidays(calendar, year)
+	if err != nil {
+		log.Printf("Failed to query market holidays: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MarketHolidaysResponse{
+		Success:  true,
+		Exchange: calendar,
+		Year:     year,
+		Holidays: holidays,
+	})
+}
+
+// LoadMarketHolidays replaces an exchange calendar's holidays for a year
+func LoadMarketHolidays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req models.MarketHolidaysRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Year == 0 {
+		writeJSONError(w, http.StatusBadRequest, "year is required")
+		return
+	}
+
+	calendar := market.Calendar(req.Exchange)
+	if err := market.SaveHolidays(calendar, req.Year, req.Holidays); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("📅 Loaded %d %s holidays for %d", len(req.Holidays), calendar, req.Year)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Loaded " + strconv.Itoa(len(req.Holidays)) + " " + calendar + " holidays for " + strconv.Itoa(req.Year),
+	})
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/notifier"
+)
+
+const (
+	// maxChannelNameLength caps channel names
+	maxChannelNameLength = 100
+
+	// minWebhookSecretLength keeps webhook signatures hard to forge
+	minWebhookSecretLength = 16
+)
+
+// CreateNotificationChannel handles adding an alert notification channel
+func CreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	req, ok := readChannelRequest(w, r)
+	if !ok {
+		return
+	}
+	ch := models.NotificationChannel{UserID: userID, IsActive: true}
+	if errs := applyChannel(&ch, req); len(errs) > 0 {
+		writeChannelErrors(w, errs)
+		return
+	}
+
+	id, err := db.CreateNotificationChannel(ch)
+	if err != nil {
+		log.Printf("Failed to insert notification channel: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ch.ID, ch.CreatedAt = id, time.Now()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.NotificationChannelResponse{
+		Success: true,
+		Message: "Notification channel created successfully",
+		Channel: &ch,
+	})
+}
+
+// GetNotificationChannels returns the user's notification channels
+func GetNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	channels, err := db.GetNotificationChannels(userID)
+	if err != nil {
+		log.Printf("Failed to query notification channels: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationChannelsResponse{
+		Success:  true,
+		Channels: channels,
+	})
+}
+
+// UpdateNotificationChannel handles editing a notification channel (?id=)
+func UpdateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	ch, ok := lookupChannel(w, r, userID)
+	if !ok {
+		return
+	}
+	req, ok := readChannelRequest(w, r)
+	if !ok {
+		return
+	}
+	if errs := applyChannel(&ch, req); len(errs) > 0 {
+		writeChannelErrors(w, errs)
+		return
+	}
+
+	if _, err := db.UpdateNotificationChannel(ch); err != nil {
+		log.Printf("Failed to update notification channel: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationChannelResponse{
+		Success: true,
+		Message: "Notification channel updated successfully",
+		Channel: &ch,
+	})
+}
+
+// DeleteNotificationChannel handles removing a notification channel (?id=)
+func DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	channelID, ok := channelID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := db.DeleteNotificationChannel(userID, channelID)
+	if err != nil {
+		log.Printf("Failed to delete notification channel: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		writeJSONError(w, http.StatusNotFound, "Notification channel not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationChannelResponse{
+		Success: true,
+		Message: "Notification channel deleted successfully",
+	})
+}
+
+// TestNotificationChannel sends a test notification over a channel (?id=) and returns its delivery
+func TestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	ch, ok := lookupChannel(w, r, userID)
+	if !ok {
+		return
+	}
+
+	text := "This is a test notification from Stock Panel."
+	now := time.Now()
+	m := notifier.Message{
+		Subject: "Stock Panel test notification",
+		Text:    "🔔 " + text,
+		HTML:    "<html><body><h2>Test notification</h2><p>" + text + "</p></body></html>",
+		Payload: models.AlertWebhookPayload{Type: "test", Trigger: models.AlertTrigger{Message: text, TriggeredAt: now}},
+	}
+	delivery := models.AlertDelivery{Channel: strings.ToLower(ch.Type), ChannelID: ch.ID, Status: models.DeliveryDelivered, At: now}
+	err := notifier.Send(context.Background(), ch, m)
+	switch {
+	case errors.Is(err, notifier.ErrNotConfigured):
+		delivery.Status, delivery.Detail = models.DeliverySkipped, delivery.Channel+" is not configured"
+	case err != nil:
+		delivery.Status, delivery.Detail = models.DeliveryFailed, err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NotificationChannelResponse{
+		Success:  err == nil,
+		Message:  "Test notification " + delivery.Status,
+		Channel:  &ch,
+		Delivery: &delivery,
+	})
+}
+
+// channelID reads the ?id= of a channel request, answering a 400 when it is missing or invalid
+func channelID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "Channel ID is required")
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid channel ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// lookupChannel loads the user's channel named by ?id=, answering a 404 when there is none
+func lookupChannel(w http.ResponseWriter, r *http.Request, userID int) (models.NotificationChannel, bool) {
+	id, ok := channelID(w, r)
+	if !ok {
+		return models.NotificationChannel{}, false
+	}
+	ch, err := db.GetNotificationChannel(userID, id)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Notification channel not found")
+		return ch, false
+	}
+	if err != nil {
+		log.Printf("Failed to query notification channel: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return ch, false
+	}
+	return ch, true
+}
+
+func readChannelRequest(w http.ResponseWriter, r *http.Request) (models.NotificationChannelRequest, bool) {
+	var req models.NotificationChannelRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return req, false
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("Failed to unmarshal notification channel request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// applyChannel checks a channel request and applies it to a new or stored channel. Fields of other
+// channel types are cleared; an empty secret or bot token keeps the stored one.
+func applyChannel(ch *models.NotificationChannel, req models.NotificationChannelRequest) fieldErrors {
+	var errs fieldErrors
+	req.Type = strings.ToUpper(strings.TrimSpace(req.Type))
+	req.Name = strings.TrimSpace(req.Name)
+	req.Address = strings.TrimSpace(req.Address)
+	req.URL = strings.TrimSpace(req.URL)
+	req.ChatID = strings.TrimSpace(req.ChatID)
+
+	if !notifier.IsChannelType(req.Type) {
+		errs.add("type", "must be one of EMAIL, WEBHOOK, TELEGRAM, SLACK")
+		return errs
+	}
+	if req.Type != ch.Type {
+		// A channel changing type keeps none of its old credentials
+		ch.Secret, ch.BotToken = "", ""
+	}
+	if req.Name == "" {
+		req.Name = strings.ToLower(req.Type)
+	}
+	if len(req.Name) > maxChannelNameLength {
+		errs.add("name", "must be at most %d characters", maxChannelNameLength)
+	}
+
+	secret, botToken := ch.Secret, ch.BotToken
+	if req.Secret != "" {
+		secret = req.Secret
+	}
+	if req.BotToken != "" {
+		botToken = req.BotToken
+	}
+	if req.Type != models.ChannelEmail && req.Address != "" {
+		errs.add("address", "only applies to EMAIL channels")
+	}
+	if req.Type != models.ChannelWebhook && req.Type != models.ChannelSlack && req.URL != "" {
+		errs.add("url", "only applies to WEBHOOK and SLACK channels")
+	}
+	if req.Type != models.ChannelWebhook && req.Secret != "" {
+		errs.add("secret", "only applies to WEBHOOK channels")
+	}
+	if req.Type != models.ChannelTelegram && req.BotToken != "" {
+		errs.add("bot_token", "only applies to TELEGRAM channels")
+	}
+	if req.Type != models.ChannelTelegram && req.ChatID != "" {
+		errs.add("chat_id", "only applies to TELEGRAM channels")
+	}
+
+	switch req.Type {
+	case models.ChannelEmail:
+		if req.Address != "" {
+			if addr, err := mail.ParseAddress(req.Address); err != nil || addr.Address != req.Address {
+				errs.add("address", "must be an email address")
+			}
+		}
+	case models.ChannelWebhook:
+		if err := notifier.CheckURL(req.URL); err != nil {
+			errs.add("url", "%s", err)
+		}
+		if len(secret) < minWebhookSecretLength {
+			errs.add("secret", "must be at least %d characters", minWebhookSecretLength)
+		}
+	case models.ChannelTelegram:
+		if botToken == "" {
+			errs.add("bot_token", "is required for TELEGRAM channels")
+		} else if strings.ContainsAny(botToken, "/?# ") {
+			errs.add("bot_token", "is not a bot token")
+		}
+		if req.ChatID == "" {
+			errs.add("chat_id", "is required for TELEGRAM channels")
+		}
+	case models.ChannelSlack:
+		if base := notifier.SlackBaseURL(); !strings.HasPrefix(req.URL, base+"/") {
+			errs.add("url", "must be a Slack incoming webhook URL under %s", base)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	ch.Type, ch.Name, ch.Address, ch.URL, ch.ChatID = req.Type, req.Name, req.Address, req.URL, req.ChatID
+	ch.Secret, ch.BotToken = "", ""
+	switch req.Type {
+	case models.ChannelWebhook:
+		ch.Secret = secret
+	case models.ChannelTelegram:
+		ch.BotToken = botToken
+	}
+	if req.IsActive != nil {
+		ch.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+func writeChannelErrors(w http.ResponseWriter, errs fieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(models.NotificationChannelResponse{
+		Success: false,
+		Message: "Invalid notification channel",
+		Errors:  errs,
+	})
+}
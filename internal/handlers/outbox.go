@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/outbox"
+)
+
+// GetOutbox lists the user's queued broker calls (?status=pending|processing|dead, default pending)
+func GetOutbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.OutboxPending
+	case models.OutboxPending, models.OutboxProcessing, models.OutboxDead:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "status must be pending, processing or dead")
+		return
+	}
+	listOutbox(w, userID, status)
+}
+
+// GetDeadLetters lists the user's broker calls that failed permanently
+func GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	listOutbox(w, userID, models.OutboxDead)
+}
+
+func listOutbox(w http.ResponseWriter, userID int, status string) {
+	items, err := outbox.Items(userID, status)
+	if err != nil {
+		log.Printf("Failed to query outbox: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.OutboxResponse{
+		Success: true,
+		Items:   items,
+	})
+}
+
+// RetryDeadLetter requeues a dead-lettered broker call (?id=), or all of them without an ID
+func RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		count, err := outbox.RetryAll(userID)
+		if err != nil {
+			log.Printf("Failed to retry dead letters: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": strconv.Itoa(count) + " dead letters requeued",
+			"count":   count,
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid outbox item ID")
+		return
+	}
+	if err := outbox.Retry(userID, id); err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Dead letter not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to retry dead letter: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Dead letter requeued",
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/portfolio"
+)
+
+// GetPortfolio returns the user's positions and P&L, optionally as of a past date (?as_of=YYYY-MM-DD)
+func GetPortfolio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	asOfDate := time.Now().In(market.IST)
+	if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+		t, err := time.ParseInLocation(portfolio.DateFormat, asOfStr, market.IST)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "as_of must be a date in YYYY-MM-DD format")
+			return
+		}
+		asOfDate = t
+	}
+
+	response := models.PortfolioResponse{
+		Success: true,
+		AsOf:    asOfDate.Format(portfolio.DateFormat),
+	}
+
+	positions, source, err := portfolio.AsOf(userID, asOfDate)
+	if err != nil {
+		log.Printf("Failed to load portfolio: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response.Source = source
+	response.Positions = positions
+	response.RealizedPnL, response.UnrealizedPnL = portfolio.Totals(positions)
+	response.TotalPnL = response.RealizedPnL + response.UnrealizedPnL
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// TakePortfolioSnapshot snapshots the user's portfolio for a date (?date=YYYY-MM-DD, default today)
+func TakePortfolioSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get user ID from context (set by AuthMiddleware)
+	userID := r.Context().Value("userID").(int)
+
+	date := time.Now().In(market.IST)
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		t, err := time.ParseInLocation(portfolio.DateFormat, dateStr, market.IST)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "date must be in YYYY-MM-DD format")
+			return
+		}
+		date = t
+	}
+
+	if err := portfolio.SnapshotUser(userID, date); err != nil {
+		log.Printf("Failed to take portfolio snapshot: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Portfolio snapshot taken for " + date.Format(portfolio.DateFormat),
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// IngestQuotes handles storing a single quote object or a JSON array of quotes
+func IngestQuotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var batch []models.Quote
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &batch)
+	} else {
+		var q models.Quote
+		err = json.Unmarshal(body, &q)
+		batch = []models.Quote{q}
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid quote payload: "+err.Error())
+		return
+	}
+	if len(batch) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "At least one quote is required")
+		return
+	}
+
+	if err := quotes.Save(batch); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"stored":  len(batch),
+	})
+}
+
+// GetLatestQuotes returns the latest quote for each requested symbol (?symbols=NIFTY 50,RELIANCE)
+func GetLatestQuotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var symbols []string
+	for _, s := range strings.Split(r.URL.Query().Get("symbols"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "symbols is required")
+		return
+	}
+
+	response := models.QuotesResponse{
+		Success: true,
+		Quotes:  make(map[string]models.Quote),
+	}
+	for _, symbol := range symbols {
+		q, ok, err := quotes.Latest(symbol)
+		if err != nil {
+			log.Printf("Failed to get latest quote for %s: %v", symbol, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			response.Missing = append(response.Missing, symbol)
+			continue
+		}
+		response.Quotes[symbol] = q
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
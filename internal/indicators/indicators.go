@@ -0,0 +1,200 @@
+// Package indicators computes technical indicators over candles. Inputs are oldest first and each
+// result has one value per input, NaN until the indicator has enough history.
+package indicators
+
+import (
+	"math"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// Closes returns the close of each candle
+func Closes(candles []models.Candle) []float64 {
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+	}
+	return closes
+}
+
+// SMA is the simple moving average over period values
+func SMA(values []float64, period int) []float64 {
+	out := nan(len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with smoothing 2 / (period + 1), seeded with the SMA of
+// the first period values
+func EMA(values []float64, period int) []float64 {
+	out := nan(len(values))
+	if len(values) < period {
+		return out
+	}
+	k := 2 / float64(period+1)
+	out[period-1] = SMA(values[:period], period)[period-1]
+	for i := period; i < len(values); i++ {
+		out[i] = values[i]*k + out[i-1]*(1-k)
+	}
+	return out
+}
+
+// RSI is Wilder's relative strength index, 0 to 100
+func RSI(values []float64, period int) []float64 {
+	out := nan(len(values))
+	if len(values) <= period {
+		return out
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		g, l := change(values[i-1], values[i])
+		gain += g
+		loss += l
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+	for i := period + 1; i < len(values); i++ {
+		g, l := change(values[i-1], values[i])
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func change(prev, cur float64) (gain, loss float64) {
+	if cur > prev {
+		return cur - prev, 0
+	}
+	return 0, prev - cur
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// Bollinger returns the bands period values wide: the SMA, and k population standard deviations
+// above and below it
+func Bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = nan(len(values)), nan(len(values))
+	for i := period - 1; i < len(values); i++ {
+		var variance float64
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+	}
+	return middle, upper, lower
+}
+
+// ATR is Wilder's average true range
+func ATR(candles []models.Candle, period int) []float64 {
+	out := nan(len(candles))
+	if len(candles) < period {
+		return out
+	}
+	tr := make([]float64, len(candles))
+	for i, c := range candles {
+		tr[i] = c.High - c.Low
+		if i > 0 {
+			prev := candles[i-1].Close
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(c.High-prev), math.Abs(c.Low-prev)))
+		}
+	}
+	out[period-1] = SMA(tr[:period], period)[period-1]
+	for i := period; i < len(candles); i++ {
+		out[i] = (out[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+	return out
+}
+
+// Supertrend returns the Supertrend line, multiplier ATRs from the candle midpoint, and whether
+// each candle is in an uptrend (the line below the close)
+func Supertrend(candles []models.Candle, period int, multiplier float64) (line []float64, up []bool) {
+	atr := ATR(candles, period)
+	line, up = nan(len(candles)), make([]bool, len(candles))
+	var upperBand, lowerBand float64
+	started := false
+	for i, c := range candles {
+		if math.IsNaN(atr[i]) {
+			continue
+		}
+		mid := (c.High + c.Low) / 2
+		upper, lower := mid+multiplier*atr[i], mid-multiplier*atr[i]
+		if !started {
+			// First value: the trend follows the close against the midpoint
+			upperBand, lowerBand = upper, lower
+			up[i] = c.Close >= mid
+			started = true
+		} else {
+			prevClose := candles[i-1].Close
+			// Bands only tighten while the previous close stays inside them
+			if upper < upperBand || prevClose > upperBand {
+				upperBand = upper
+			}
+			if lower > lowerBand || prevClose < lowerBand {
+				lowerBand = lower
+			}
+			switch {
+			case up[i-1] && c.Close < lowerBand:
+				up[i] = false
+			case !up[i-1] && c.Close > upperBand:
+				up[i] = true
+			default:
+				up[i] = up[i-1]
+			}
+		}
+		line[i] = upperBand
+		if up[i] {
+			line[i] = lowerBand
+		}
+	}
+	return line, up
+}
+
+// VWAP is the volume-weighted average of the typical price, (high + low + close) / 3, from the
+// first candle of each IST trading day; it stays NaN until the day has traded volume
+func VWAP(candles []models.Candle) []float64 {
+	out := nan(len(candles))
+	var day string
+	var weighted, volume float64
+	for i, c := range candles {
+		if d := c.Start.In(market.IST).Format(market.DateFormat); d != day {
+			day, weighted, volume = d, 0, 0
+		}
+		weighted += (c.High + c.Low + c.Close) / 3 * float64(c.Volume)
+		volume += float64(c.Volume)
+		if volume > 0 {
+			out[i] = weighted / volume
+		}
+	}
+	return out
+}
+
+func nan(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package instruments
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+var (
+	ErrNotLoaded     = errors.New("instruments master is not loaded")
+	ErrUnknownSymbol = errors.New("unknown symbol")
+)
+
+// DefaultFile is read when INSTRUMENTS_FILE is not set
+const DefaultFile = "instruments.csv"
+
+// requiredColumns must be present in the CSV header
+var requiredColumns = []string{"instrument_token", "tradingsymbol", "exchange"}
+
+// derivativeExchanges and cashExchanges are tried in order when resolving a symbol listed on several exchanges
+var (
+	derivativeExchanges = []string{"NFO", "BFO", "MCX", "CDS", "BCD"}
+	cashExchanges       = []string{"NSE", "BSE"}
+)
+
+// File returns the instruments dump path from INSTRUMENTS_FILE
+func File() string {
+	if path := os.Getenv("INSTRUMENTS_FILE"); path != "" {
+		return path
+	}
+	return DefaultFile
+}
+
+// Parse reads a Kite instruments CSV dump (columns are matched by header name)
+func Parse(r io.Reader) ([]models.Instrument, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var instruments []models.Instrument
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		inst := models.Instrument{
+			TradingSymbol:  field(record, "tradingsymbol"),
+			Name:           field(record, "name"),
+			Expiry:         field(record, "expiry"),
+			InstrumentType: field(record, "instrument_type"),
+			Segment:        field(record, "segment"),
+			Exchange:       field(record, "exchange"),
+		}
+		if inst.InstrumentToken, err = strconv.ParseInt(field(record, "instrument_token"), 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid instrument_token", line)
+		}
+		if inst.TradingSymbol == "" || inst.Exchange == "" {
+			return nil, fmt.Errorf("line %d: tradingsymbol and exchange are required", line)
+		}
+		if inst.ExchangeToken, err = parseInt(field(record, "exchange_token")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid exchange_token", line)
+		}
+		if inst.Strike, err = parseFloat(field(record, "strike")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid strike", line)
+		}
+		if inst.TickSize, err = parseFloat(field(record, "tick_size")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid tick_size", line)
+		}
+		lotSize, err := parseInt(field(record, "lot_size"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid lot_size", line)
+		}
+		inst.LotSize = int(lotSize)
+		instruments = append(instruments, inst)
+	}
+	return instruments, nil
+}
+
+func parseInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Import replaces the instruments master with the contents of a CSV dump and returns the row count
+func Import(r io.Reader) (int, error) {
+	instruments, err := Parse(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(instruments) == 0 {
+		return 0, errors.New("CSV has no instruments")
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM instruments"); err != nil {
+		return 0, fmt.Errorf("failed to clear instruments: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO instruments (instrument_token, exchange_token, tradingsymbol, name, expiry, strike, tick_size, lot_size, instrument_type, segment, exchange)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (instrument_token) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, inst := range instruments {
+		if _, err := stmt.Exec(inst.InstrumentToken, inst.ExchangeToken, inst.TradingSymbol, inst.Name, inst.Expiry, inst.Strike,
+			inst.TickSize, inst.LotSize, inst.InstrumentType, inst.Segment, inst.Exchange); err != nil {
+			return 0, fmt.Errorf("failed to insert %s: %v", inst.TradingSymbol, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(instruments), nil
+}
+
+// ImportFile refreshes the instruments master from a CSV file on disk
+func ImportFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return Import(f)
+}
+
+const instrumentColumns = `instrument_token, COALESCE(exchange_token, 0), tradingsymbol, COALESCE(name, ''), COALESCE(expiry, ''),
+	COALESCE(strike, 0), COALESCE(tick_size, 0), COALESCE(lot_size, 0), COALESCE(instrument_type, ''), COALESCE(segment, ''), exchange`
+
+func scanInstrument(row interface{ Scan(...interface{}) error }) (models.Instrument, error) {
+	var inst models.Instrument
+	err := row.Scan(&inst.InstrumentToken, &inst.ExchangeToken, &inst.TradingSymbol, &inst.Name, &inst.Expiry,
+		&inst.Strike, &inst.TickSize, &inst.LotSize, &inst.InstrumentType, &inst.Segment, &inst.Exchange)
+	return inst, err
+}
+
+// Search finds instruments for autocomplete: exact symbol matches first, then symbol prefixes, then names
+func Search(q, exchange string, limit int) ([]models.Instrument, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, nil
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q)
+
+	query := "SELECT " + instrumentColumns + ` FROM instruments
+		WHERE (tradingsymbol LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\')`
+	args := []interface{}{escaped + "%", "%" + escaped + "%"}
+	if exchange != "" {
+		query += " AND exchange = ?"
+		args = append(args, strings.ToUpper(exchange))
+	}
+	query += ` ORDER BY CASE WHEN tradingsymbol = ? THEN 0 WHEN tradingsymbol LIKE ? ESCAPE '\' THEN 1 ELSE 2 END,
+		length(tradingsymbol), tradingsymbol, exchange LIMIT ?`
+	args = append(args, q, escaped+"%", limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instruments []models.Instrument
+	for rows.Next() {
+		inst, err := scanInstrument(rows)
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, inst)
+	}
+	return instruments, rows.Err()
+}
+
+// Resolve looks up a trading symbol. A symbol listed on several exchanges resolves to the first of
+// NFO, BFO, MCX, CDS, BCD for derivatives, or NSE, BSE otherwise. It returns ErrNotLoaded when the
+// master is empty, so callers can skip validation, and ErrUnknownSymbol when there is no match.
+func Resolve(symbol string, derivative bool) (models.Instrument, error) {
+	rows, err := db.DB.Query("SELECT "+instrumentColumns+" FROM instruments WHERE tradingsymbol = ?", strings.TrimSpace(symbol))
+	if err != nil {
+		return models.Instrument{}, err
+	}
+	defer rows.Close()
+
+	var matches []models.Instrument
+	for rows.Next() {
+		inst, err := scanInstrument(rows)
+		if err != nil {
+			return models.Instrument{}, err
+		}
+		matches = append(matches, inst)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Instrument{}, err
+	}
+
+	if len(matches) == 0 {
+		var one int
+		err := db.DB.QueryRow("SELECT 1 FROM instruments LIMIT 1").Scan(&one)
+		if err == sql.ErrNoRows {
+			return models.Instrument{}, ErrNotLoaded
+		}
+		if err != nil {
+			return models.Instrument{}, err
+		}
+		return models.Instrument{}, ErrUnknownSymbol
+	}
+
+	preferred := cashExchanges
+	if derivative {
+		preferred = derivativeExchanges
+	}
+	for _, exchange := range preferred {
+		for _, inst := range matches {
+			if inst.Exchange == exchange {
+				return inst, nil
+			}
+		}
+	}
+	return matches[0], nil
+}
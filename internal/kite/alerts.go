@@ -0,0 +1,222 @@
+package kite
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// Kite alert field values
+const (
+	AlertTypeSimple = "simple"
+	AlertTypeATO    = "ato"
+
+	RHSTypeConstant   = "constant"
+	RHSTypeInstrument = "instrument"
+
+	AttributeLastTradedPrice = "LastTradedPrice"
+)
+
+// maxAlertNameLength is the longest alert name Kite accepts, in characters
+const maxAlertNameLength = 64
+
+// AlertParams are the form fields accepted by Kite's POST /alerts and PUT /alerts/{uuid}
+type AlertParams struct {
+	Name             string
+	Type             string // "simple" or "ato"
+	LHSExchange      string
+	LHSTradingSymbol string
+	LHSAttribute     string
+	Operator         string // "<=", ">=", "<" or ">"
+	RHSType          string // "constant" or "instrument"
+	RHSConstant      float64
+	RHSExchange      string
+	RHSTradingSymbol string
+	RHSAttribute     string
+}
+
+// Alert is an alert as returned by Kite
+type Alert struct {
+	UUID             string  `json:"uuid"`
+	Name             string  `json:"name"`
+	Type             string  `json:"type"`
+	UserID           string  `json:"user_id"`
+	Status           string  `json:"status"` // "enabled", "disabled", "deleted"
+	DisabledReason   string  `json:"disabled_reason"`
+	LHSExchange      string  `json:"lhs_exchange"`
+	LHSTradingSymbol string  `json:"lhs_tradingsymbol"`
+	LHSAttribute     string  `json:"lhs_attribute"`
+	Operator         string  `json:"operator"`
+	RHSType          string  `json:"rhs_type"`
+	RHSConstant      float64 `json:"rhs_constant"`
+	RHSExchange      string  `json:"rhs_exchange"`
+	RHSTradingSymbol string  `json:"rhs_tradingsymbol"`
+	RHSAttribute     string  `json:"rhs_attribute"`
+	AlertCount       int     `json:"alert_count"`
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
+}
+
+// form encodes the params for Kite
+func (p AlertParams) form() url.Values {
+	form := url.Values{}
+	form.Set("name", p.Name)
+	form.Set("type", p.Type)
+	form.Set("lhs_exchange", p.LHSExchange)
+	form.Set("lhs_tradingsymbol", p.LHSTradingSymbol)
+	form.Set("lhs_attribute", p.LHSAttribute)
+	form.Set("operator", p.Operator)
+	form.Set("rhs_type", p.RHSType)
+	if p.RHSType == RHSTypeInstrument {
+		form.Set("rhs_exchange", p.RHSExchange)
+		form.Set("rhs_tradingsymbol", p.RHSTradingSymbol)
+		form.Set("rhs_attribute", p.RHSAttribute)
+	} else {
+		form.Set("rhs_constant", strconv.FormatFloat(p.RHSConstant, 'f', -1, 64))
+	}
+	return form
+}
+
+// AlertParamsFromModel maps a stored alert onto Kite's simple alert schema. The exchange comes from
+// the instruments master; without it options (alerts with an option type) go to NFO, everything else to NSE.
+func AlertParamsFromModel(a models.Alert) (AlertParams, error) {
+	operator, err := alertOperator(a)
+	if err != nil {
+		return AlertParams{}, err
+	}
+
+	exchange := a.Exchange
+	if exchange == "" {
+		exchange = "NSE"
+		if a.OptionType != "" {
+			exchange = "NFO"
+		}
+	}
+
+	name := a.Message
+	if name == "" {
+		name = fmt.Sprintf("%s %s %g", a.Symbol, operator, a.TargetValue)
+	}
+	if runes := []rune(name); len(runes) > maxAlertNameLength {
+		name = string(runes[:maxAlertNameLength])
+	}
+
+	return AlertParams{
+		Name:             name,
+		Type:             AlertTypeSimple,
+		LHSExchange:      exchange,
+		LHSTradingSymbol: a.Symbol,
+		LHSAttribute:     AttributeLastTradedPrice,
+		Operator:         operator,
+		RHSType:          RHSTypeConstant,
+		RHSConstant:      a.TargetValue,
+	}, nil
+}
+
+// Supported reports whether Kite alerts can represent an alert's type
+func Supported(a models.Alert) bool {
+	return a.AlertType == models.AlertPriceAbove || a.AlertType == models.AlertPriceBelow
+}
+
+// alertOperator picks the Kite operator for an alert: an explicit condition wins, otherwise the
+// alert type decides. Kite alerts compare the last price against constants, so only price alerts
+// can be synced.
+func alertOperator(a models.Alert) (string, error) {
+	if !Supported(a) {
+		return "", fmt.Errorf("alert type %s is not supported by Kite alerts", a.AlertType)
+	}
+	switch a.Condition {
+	case "<=", ">=", "<", ">":
+		return a.Condition, nil
+	}
+	switch a.AlertType {
+	case models.AlertPriceAbove:
+		return ">=", nil
+	case models.AlertPriceBelow:
+		return "<=", nil
+	}
+	return "", fmt.Errorf("alert type %s with condition %q is not supported by Kite alerts", a.AlertType, a.Condition)
+}
+
+// CreateAlert creates an alert on Kite and returns it with its UUID
+func (k *KiteService) CreateAlert(params AlertParams) (*Alert, error) {
+	var alert Alert
+	if err := k.doRequest(http.MethodPost, "/alerts", params.form(), &alert); err != nil {
+		return nil, err
+	}
+	log.Printf("✅ Alert created on Kite: %s (%s)", alert.LHSTradingSymbol, alert.UUID)
+	return &alert, nil
+}
+
+// SendBulkAlerts creates multiple alerts on Kite, continuing past failures;
+// it returns the created alerts and an error joining every failure
+func (k *KiteService) SendBulkAlerts(alerts []AlertParams) ([]Alert, error) {
+	var created []Alert
+	var errs []error
+	for _, alert := range alerts {
+		remote, err := k.CreateAlert(alert)
+		if err != nil {
+			log.Printf("❌ Failed to send alert for %s: %v", alert.LHSTradingSymbol, err)
+			errs = append(errs, fmt.Errorf("%s: %w", alert.LHSTradingSymbol, err))
+			continue
+		}
+		created = append(created, *remote)
+	}
+	if len(errs) > 0 {
+		return created, fmt.Errorf("%d of %d alerts failed: %w", len(errs), len(alerts), errors.Join(errs...))
+	}
+	return created, nil
+}
+
+// ListAlerts returns the user's alerts on Kite; filters are passed as query params (e.g. status=enabled)
+func (k *KiteService) ListAlerts(filters url.Values) ([]Alert, error) {
+	var alerts []Alert
+	if err := k.doRequest(http.MethodGet, "/alerts", filters, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// GetAlert returns a single alert by UUID
+func (k *KiteService) GetAlert(uuid string) (*Alert, error) {
+	var alert Alert
+	if err := k.doRequest(http.MethodGet, "/alerts/"+url.PathEscape(uuid), nil, &alert); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// ModifyAlert replaces the definition of an existing alert
+func (k *KiteService) ModifyAlert(uuid string, params AlertParams) (*Alert, error) {
+	var alert Alert
+	if err := k.doRequest(http.MethodPut, "/alerts/"+url.PathEscape(uuid), params.form(), &alert); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// DeleteAlerts deletes one or more alerts by UUID
+func (k *KiteService) DeleteAlerts(uuids ...string) error {
+	if len(uuids) == 0 {
+		return nil
+	}
+	params := url.Values{"uuid": uuids}
+	return k.doRequest(http.MethodDelete, "/alerts", params, nil)
+}
+
+// GetAlertStatus returns every alert on Kite with its current status
+func (k *KiteService) GetAlertStatus() ([]Alert, error) {
+	return k.ListAlerts(nil)
+}
+
+// IsNotFound reports whether err is Kite saying the alert does not exist
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || strings.Contains(strings.ToLower(apiErr.Message), "not found"))
+}
@@ -0,0 +1,258 @@
+package kite
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const testAlertJSON = `{"uuid": "550e8400-e29b-41d4-a716-446655440000", "name": "INFY >= 1500", "type": "simple",
+	"status": "enabled", "lhs_exchange": "NSE", "lhs_tradingsymbol": "INFY", "lhs_attribute": "LastTradedPrice",
+	"operator": ">=", "rhs_type": "constant", "rhs_constant": 1500}`
+
+// kiteRequest is what the fake Kite server saw
+type kiteRequest struct {
+	method, path, query string
+	header              http.Header
+	form                url.Values
+}
+
+// fakeKite answers every request with a success envelope around data and records the request
+func fakeKite(t *testing.T, data string) (*KiteService, *kiteRequest) {
+	t.Helper()
+	seen := &kiteRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		*seen = kiteRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header, form: form}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status": "success", "data": `+data+`}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	k := NewKiteService("key", "secret", srv.URL)
+	k.AccessToken = "token"
+	return k, seen
+}
+
+// checkHeaders asserts the Kite Connect version and authorization headers
+func checkHeaders(t *testing.T, seen *kiteRequest) {
+	t.Helper()
+	if got := seen.header.Get("Authorization"); got != "token key:token" {
+		t.Errorf("Authorization = %q, want %q", got, "token key:token")
+	}
+	if got := seen.header.Get("X-Kite-Version"); got != "3" {
+		t.Errorf("X-Kite-Version = %q, want 3", got)
+	}
+}
+
+func TestAlertParamsFromModel(t *testing.T) {
+	params, err := AlertParamsFromModel(models.Alert{Symbol: "INFY", AlertType: models.AlertPriceAbove, TargetValue: 1500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AlertParams{
+		Name:             "INFY >= 1500",
+		Type:             AlertTypeSimple,
+		LHSExchange:      "NSE",
+		LHSTradingSymbol: "INFY",
+		LHSAttribute:     AttributeLastTradedPrice,
+		Operator:         ">=",
+		RHSType:          RHSTypeConstant,
+		RHSConstant:      1500,
+	}
+	if params != want {
+		t.Errorf("params = %+v, want %+v", params, want)
+	}
+
+	params, err = AlertParamsFromModel(models.Alert{Symbol: "NIFTY26OCT25000CE", OptionType: "CALL", AlertType: models.AlertPriceBelow, Condition: "<", TargetValue: 80.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.LHSExchange != "NFO" || params.Operator != "<" {
+		t.Errorf("option alert maps to %s %s, want NFO <", params.LHSExchange, params.Operator)
+	}
+
+	params, err = AlertParamsFromModel(models.Alert{Symbol: "INFY", Exchange: "BSE", AlertType: models.AlertPriceBelow, TargetValue: 1400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.LHSExchange != "BSE" || params.Operator != "<=" {
+		t.Errorf("BSE alert maps to %s %s, want BSE <=", params.LHSExchange, params.Operator)
+	}
+
+	if _, err := AlertParamsFromModel(models.Alert{Symbol: "INFY", AlertType: models.AlertPercentageChange, TargetValue: 5}); err == nil {
+		t.Error("expected percentage change alerts to be rejected")
+	}
+}
+
+func TestAlertParamsFromModelTruncatesNameByCharacter(t *testing.T) {
+	message := strings.Repeat("₹", maxAlertNameLength+10)
+	params, err := AlertParamsFromModel(models.Alert{Symbol: "INFY", AlertType: models.AlertPriceAbove, TargetValue: 1500, Message: message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(params.Name) || utf8.RuneCountInString(params.Name) != maxAlertNameLength {
+		t.Errorf("name %q is not %d whole characters", params.Name, maxAlertNameLength)
+	}
+}
+
+func TestCreateAlert(t *testing.T) {
+	k, seen := fakeKite(t, testAlertJSON)
+	alert, err := k.CreateAlert(AlertParams{
+		Name:             "INFY >= 1500",
+		Type:             AlertTypeSimple,
+		LHSExchange:      "NSE",
+		LHSTradingSymbol: "INFY",
+		LHSAttribute:     AttributeLastTradedPrice,
+		Operator:         ">=",
+		RHSType:          RHSTypeConstant,
+		RHSConstant:      1500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.UUID != "550e8400-e29b-41d4-a716-446655440000" || alert.RHSConstant != 1500 {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+
+	if seen.method != http.MethodPost || seen.path != "/alerts" {
+		t.Errorf("request = %s %s, want POST /alerts", seen.method, seen.path)
+	}
+	checkHeaders(t, seen)
+	if got := seen.header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	want := url.Values{
+		"name":              {"INFY >= 1500"},
+		"type":              {"simple"},
+		"lhs_exchange":      {"NSE"},
+		"lhs_tradingsymbol": {"INFY"},
+		"lhs_attribute":     {"LastTradedPrice"},
+		"operator":          {">="},
+		"rhs_type":          {"constant"},
+		"rhs_constant":      {"1500"},
+	}
+	if seen.form.Encode() != want.Encode() {
+		t.Errorf("form = %s, want %s", seen.form.Encode(), want.Encode())
+	}
+}
+
+func TestCreateAlertInstrumentRHS(t *testing.T) {
+	k, seen := fakeKite(t, testAlertJSON)
+	_, err := k.CreateAlert(AlertParams{
+		Name:             "INFY above TCS",
+		Type:             AlertTypeSimple,
+		LHSExchange:      "NSE",
+		LHSTradingSymbol: "INFY",
+		LHSAttribute:     AttributeLastTradedPrice,
+		Operator:         ">",
+		RHSType:          RHSTypeInstrument,
+		RHSExchange:      "NSE",
+		RHSTradingSymbol: "TCS",
+		RHSAttribute:     AttributeLastTradedPrice,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen.form.Has("rhs_constant") {
+		t.Error("instrument alerts should not send rhs_constant")
+	}
+	if seen.form.Get("rhs_exchange") != "NSE" || seen.form.Get("rhs_tradingsymbol") != "TCS" || seen.form.Get("rhs_attribute") != "LastTradedPrice" {
+		t.Errorf("unexpected rhs fields: %s", seen.form.Encode())
+	}
+}
+
+func TestListAlerts(t *testing.T) {
+	k, seen := fakeKite(t, "["+testAlertJSON+"]")
+	alerts, err := k.ListAlerts(url.Values{"status": {"enabled"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].LHSTradingSymbol != "INFY" || alerts[0].Status != "enabled" {
+		t.Errorf("unexpected alerts: %+v", alerts)
+	}
+	if seen.method != http.MethodGet || seen.path != "/alerts" || seen.query != "status=enabled" {
+		t.Errorf("request = %s %s?%s, want GET /alerts?status=enabled", seen.method, seen.path, seen.query)
+	}
+	checkHeaders(t, seen)
+}
+
+func TestGetAlert(t *testing.T) {
+	k, seen := fakeKite(t, testAlertJSON)
+	alert, err := k.GetAlert("550e8400-e29b-41d4-a716-446655440000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.Operator != ">=" {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+	if seen.method != http.MethodGet || seen.path != "/alerts/550e8400-e29b-41d4-a716-446655440000" {
+		t.Errorf("request = %s %s", seen.method, seen.path)
+	}
+	checkHeaders(t, seen)
+}
+
+func TestModifyAlert(t *testing.T) {
+	k, seen := fakeKite(t, testAlertJSON)
+	_, err := k.ModifyAlert("550e8400-e29b-41d4-a716-446655440000", AlertParams{
+		Name:             "INFY <= 1400",
+		Type:             AlertTypeSimple,
+		LHSExchange:      "NSE",
+		LHSTradingSymbol: "INFY",
+		LHSAttribute:     AttributeLastTradedPrice,
+		Operator:         "<=",
+		RHSType:          RHSTypeConstant,
+		RHSConstant:      1400.25,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen.method != http.MethodPut || seen.path != "/alerts/550e8400-e29b-41d4-a716-446655440000" {
+		t.Errorf("request = %s %s", seen.method, seen.path)
+	}
+	checkHeaders(t, seen)
+	if seen.form.Get("operator") != "<=" || seen.form.Get("rhs_constant") != "1400.25" || seen.form.Get("name") != "INFY <= 1400" {
+		t.Errorf("unexpected form: %s", seen.form.Encode())
+	}
+}
+
+func TestDeleteAlerts(t *testing.T) {
+	k, seen := fakeKite(t, "null")
+	if err := k.DeleteAlerts("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if seen.method != http.MethodDelete || seen.path != "/alerts" || seen.query != "uuid=a&uuid=b" {
+		t.Errorf("request = %s %s?%s, want DELETE /alerts?uuid=a&uuid=b", seen.method, seen.path, seen.query)
+	}
+	checkHeaders(t, seen)
+
+	seen.method = ""
+	if err := k.DeleteAlerts(); err != nil || seen.method != "" {
+		t.Errorf("deleting no alerts should not call Kite (err %v, method %q)", err, seen.method)
+	}
+}
+
+func TestAlertErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"status": "error", "error_type": "InputException", "message": "Alert not found"}`)
+	}))
+	defer srv.Close()
+
+	k := NewKiteService("key", "secret", srv.URL)
+	k.AccessToken = "token"
+	_, err := k.GetAlert("missing")
+	if !IsNotFound(err) {
+		t.Errorf("err = %v, want a not found error", err)
+	}
+	if IsRetryable(err) {
+		t.Error("a missing alert should not be retried")
+	}
+}
@@ -0,0 +1,49 @@
+package kite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// TickToQuote converts a tick into a quote for the given symbol
+func TickToQuote(t Tick, symbol string) models.Quote {
+	q := models.Quote{
+		Symbol:    symbol,
+		Exchange:  t.Exchange,
+		LTP:       t.LastPrice,
+		Bid:       t.Depth.Buy[0].Price,
+		Ask:       t.Depth.Sell[0].Price,
+		Volume:    int64(t.VolumeTraded),
+		OI:        int64(t.OI),
+		Timestamp: t.Timestamp,
+	}
+	if q.Timestamp.IsZero() {
+		q.Timestamp = time.Now()
+	}
+	return q
+}
+
+// ParseInstrumentList parses "token:symbol" pairs separated by commas, e.g. "256265:NIFTY 50,738561:RELIANCE"
+func ParseInstrumentList(value string) (map[uint32]string, error) {
+	instruments := make(map[uint32]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		tokenStr, symbol, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(symbol) == "" {
+			return nil, fmt.Errorf("invalid instrument %q, expected token:symbol", item)
+		}
+		token, err := strconv.ParseUint(strings.TrimSpace(tokenStr), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid instrument token in %q: %v", item, err)
+		}
+		instruments[uint32(token)] = strings.TrimSpace(symbol)
+	}
+	return instruments, nil
+}
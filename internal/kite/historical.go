@@ -0,0 +1,98 @@
+package kite
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// historicalTimeFormat is the from/to format of the historical data API
+const historicalTimeFormat = "2006-01-02 15:04:05"
+
+// HistoricalIntervals maps candle intervals to Kite's historical data interval names
+var HistoricalIntervals = map[string]string{
+	"1m":  "minute",
+	"5m":  "5minute",
+	"15m": "15minute",
+	"1h":  "60minute",
+	"1d":  "day",
+}
+
+// HistoricalMaxDays is the longest range Kite serves in one historical data request per interval
+var HistoricalMaxDays = map[string]int{
+	"1m":  60,
+	"5m":  100,
+	"15m": 200,
+	"1h":  400,
+	"1d":  2000,
+}
+
+// HistoricalData fetches candles for an instrument between from and to (inclusive), oldest first.
+// interval is one of the HistoricalIntervals keys; oi adds open interest for derivatives.
+func (k *KiteService) HistoricalData(token int64, interval string, from, to time.Time, oi bool) ([]models.Candle, error) {
+	kiteInterval, ok := HistoricalIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported historical interval %q", interval)
+	}
+	params := url.Values{}
+	params.Set("from", from.In(market.IST).Format(historicalTimeFormat))
+	params.Set("to", to.In(market.IST).Format(historicalTimeFormat))
+	if oi {
+		params.Set("oi", "1")
+	}
+
+	var data struct {
+		Candles [][]interface{} `json:"candles"`
+	}
+	path := "/instruments/historical/" + strconv.FormatInt(token, 10) + "/" + kiteInterval
+	if err := k.doRequest(http.MethodGet, path, params, &data); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Candle, 0, len(data.Candles))
+	for _, row := range data.Candles {
+		c, err := parseHistoricalCandle(row)
+		if err != nil {
+			return nil, err
+		}
+		c.Interval = interval
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// parseHistoricalCandle reads a [timestamp, open, high, low, close, volume(, oi)] row
+func parseHistoricalCandle(row []interface{}) (models.Candle, error) {
+	var c models.Candle
+	if len(row) < 6 {
+		return c, fmt.Errorf("invalid historical candle %v", row)
+	}
+	ts, ok := row[0].(string)
+	if !ok {
+		return c, fmt.Errorf("invalid historical candle timestamp %v", row[0])
+	}
+	start, err := time.Parse("2006-01-02T15:04:05-0700", ts)
+	if err != nil {
+		return c, fmt.Errorf("invalid historical candle timestamp %q: %v", ts, err)
+	}
+	c.Start = start.In(market.IST)
+
+	values := make([]float64, len(row)-1)
+	for i, v := range row[1:] {
+		f, ok := v.(float64)
+		if !ok {
+			return c, fmt.Errorf("invalid historical candle value %v", v)
+		}
+		values[i] = f
+	}
+	c.Open, c.High, c.Low, c.Close, c.Volume = values[0], values[1], values[2], values[3], int64(values[4])
+	if len(values) > 5 {
+		c.OI = int64(values[5])
+	}
+	return c, nil
+}
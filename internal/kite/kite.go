@@ -1,46 +1,68 @@
 package kite
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// DefaultBaseURL is the Kite Connect REST API root
+const DefaultBaseURL = "https://api.kite.trade"
+
+// kiteVersion is sent as X-Kite-Version on every request
+const kiteVersion = "3"
+
 // KiteService handles communication with Kite 3 API
 type KiteService struct {
-	APIKey     string
-	APISecret  string
-	BaseURL    string
-	HTTPClient *http.Client
+	APIKey      string
+	APISecret   string
+	AccessToken string // per-user token from the login flow, valid until 6 AM IST the next day
+	BaseURL     string
+	HTTPClient  *http.Client
+}
+
+// APIError is an error response returned by Kite
+type APIError struct {
+	StatusCode int
+	ErrorType  string // e.g. "TokenException", "InputException"
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorType != "" {
+		return fmt.Sprintf("Kite API error (%d %s): %s", e.StatusCode, e.ErrorType, e.Message)
+	}
+	return fmt.Sprintf("Kite API returned status %d: %s", e.StatusCode, e.Message)
 }
 
-// AlertPayload represents the structure for sending alerts to Kite
-type AlertPayload struct {
-	Symbol           string  `json:"symbol"`
-	UnderlyingSymbol string  `json:"underlying_symbol,omitempty"`
-	OptionType       string  `json:"option_type,omitempty"`
-	StrikePrice      float64 `json:"strike_price,omitempty"`
-	Expiry           string  `json:"expiry,omitempty"`
-	AlertType        string  `json:"alert_type"`
-	TargetValue      float64 `json:"target_value"`
-	Condition        string  `json:"condition"`
-	Message          string  `json:"message"`
-	Timestamp        string  `json:"timestamp"`
-	UserID           int     `json:"user_id"`
+// IsTokenError reports whether err means the access token is missing, invalid or expired
+func IsTokenError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorType == "TokenException" || apiErr.StatusCode == http.StatusForbidden)
 }
 
-// KiteResponse represents the response from Kite API
-type KiteResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
+// IsRetryable reports whether a failed call may succeed later: network errors, rate limiting
+// (429) and server errors are retryable, while rejected input and bad tokens are not
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError ||
+		apiErr.ErrorType == "NetworkException"
 }
 
-// NewKiteService creates a new Kite service instance
+// NewKiteService creates a new Kite service instance; an empty baseURL uses DefaultBaseURL
 func NewKiteService(apiKey, apiSecret, baseURL string) *KiteService {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
 	return &KiteService{
 		APIKey:    apiKey,
 		APISecret: apiSecret,
@@ -51,135 +73,84 @@ func NewKiteService(apiKey, apiSecret, baseURL string) *KiteService {
 	}
 }
 
-// SendAlert sends an alert to Kite 3 API
-func (k *KiteService) SendAlert(alert AlertPayload) error {
-	// Create the request payload
-	payload := map[string]interface{}{
-		"symbol":            alert.Symbol,
-		"underlying_symbol": alert.UnderlyingSymbol,
-		"option_type":       alert.OptionType,
-		"strike_price":      alert.StrikePrice,
-		"expiry":            alert.Expiry,
-		"alert_type":        alert.AlertType,
-		"target_value":      alert.TargetValue,
-		"condition":         alert.Condition,
-		"message":           alert.Message,
-		"timestamp":         alert.Timestamp,
-		"user_id":           alert.UserID,
-	}
-
-	// Convert payload to JSON
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal alert payload: %v", err)
-	}
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", k.BaseURL+"/alerts", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+// TestConnection verifies the access token by fetching the user profile
+func (k *KiteService) TestConnection() error {
+	var profile struct {
+		UserID string `json:"user_id"`
 	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", k.APIKey)
-	req.Header.Set("X-API-Secret", k.APISecret)
-
-	// Send request
-	resp, err := k.HTTPClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request to Kite API: %v", err)
+	if err := k.doRequest(http.MethodGet, "/user/profile", nil, &profile); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Kite API returned status %d", resp.StatusCode)
-	}
-
-	// Parse response
-	var kiteResp KiteResponse
-	if err := json.NewDecoder(resp.Body).Decode(&kiteResp); err != nil {
-		return fmt.Errorf("failed to decode Kite API response: %v", err)
-	}
+	log.Printf("✅ Kite API connection test successful for %s", profile.UserID)
+	return nil
+}
 
-	// Check if the response indicates success
-	if kiteResp.Status != "success" {
-		return fmt.Errorf("Kite API error: %s", kiteResp.Message)
+// authorize sets the Kite Connect version and authorization headers
+func (k *KiteService) authorize(req *http.Request) {
+	req.Header.Set("X-Kite-Version", kiteVersion)
+	if k.AccessToken != "" {
+		req.Header.Set("Authorization", "token "+k.APIKey+":"+k.AccessToken)
 	}
-
-	log.Printf("✅ Alert sent to Kite API successfully: %s", alert.Symbol)
-	return nil
 }
 
-// SendBulkAlerts sends multiple alerts to Kite 3 API
-func (k *KiteService) SendBulkAlerts(alerts []AlertPayload) error {
-	for _, alert := range alerts {
-		if err := k.SendAlert(alert); err != nil {
-			log.Printf("❌ Failed to send alert for %s: %v", alert.Symbol, err)
-			// Continue with other alerts even if one fails
-			continue
+// doRequest sends an authorized request with form-encoded params (query string for GET and DELETE)
+// and decodes the "data" field of a successful response into out
+func (k *KiteService) doRequest(method, path string, params url.Values, out interface{}) error {
+	endpoint := k.BaseURL + path
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if len(params) > 0 {
+			endpoint += "?" + params.Encode()
 		}
+	} else if params != nil {
+		body = strings.NewReader(params.Encode())
 	}
-	return nil
-}
 
-// TestConnection tests the connection to Kite 3 API
-func (k *KiteService) TestConnection() error {
-	req, err := http.NewRequest("GET", k.BaseURL+"/health", nil)
+	req, err := http.NewRequest(method, endpoint, body)
 	if err != nil {
-		return fmt.Errorf("failed to create health check request: %v", err)
+		return fmt.Errorf("failed to create request: %v", err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	k.authorize(req)
 
-	req.Header.Set("X-API-Key", k.APIKey)
-	req.Header.Set("X-API-Secret", k.APISecret)
-
+	limiter.Wait()
 	resp, err := k.HTTPClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Kite API: %v", err)
+		return fmt.Errorf("failed to send request to Kite API: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Kite API health check failed with status %d", resp.StatusCode)
-	}
-
-	log.Printf("✅ Kite API connection test successful")
-	return nil
+	return decodeResponse(resp, out)
 }
 
-// GetAlertStatus retrieves the status of alerts from Kite 3 API
-func (k *KiteService) GetAlertStatus(userID int) ([]map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/alerts/status?user_id=%d", k.BaseURL, userID), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create status request: %v", err)
+// decodeResponse checks the Kite response envelope and decodes "data" into out (if non-nil)
+func decodeResponse(resp *http.Response, out interface{}) error {
+	var envelope struct {
+		Status    string          `json:"status"`
+		Message   string          `json:"message"`
+		ErrorType string          `json:"error_type"`
+		Data      json.RawMessage `json:"data"`
 	}
-
-	req.Header.Set("X-API-Key", k.APIKey)
-	req.Header.Set("X-API-Secret", k.APISecret)
-
-	resp, err := k.HTTPClient.Do(req)
+	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get alert status from Kite API: %v", err)
+		return fmt.Errorf("failed to read Kite API response: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Kite API status request failed with status %d", resp.StatusCode)
-	}
-
-	var response struct {
-		Status string                   `json:"status"`
-		Data   []map[string]interface{} `json:"data"`
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		}
+		return fmt.Errorf("failed to decode Kite API response: %v", err)
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode status response: %v", err)
+	if resp.StatusCode != http.StatusOK || envelope.Status != "success" {
+		return &APIError{StatusCode: resp.StatusCode, ErrorType: envelope.ErrorType, Message: envelope.Message}
 	}
-
-	if response.Status != "success" {
-		return nil, fmt.Errorf("Kite API returned error status")
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode Kite API data: %v", err)
+		}
 	}
-
-	return response.Data, nil
+	return nil
 }
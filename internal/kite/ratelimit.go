@@ -0,0 +1,32 @@
+package kite
+
+import (
+	"sync"
+	"time"
+)
+
+// requestsPerSecond is Kite Connect's per-API-key limit for regular endpoints
+const requestsPerSecond = 10
+
+// rateLimiter spaces requests evenly so a burst never exceeds the limit
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+var limiter = &rateLimiter{interval: time.Second / requestsPerSecond}
+
+// Wait blocks until the next request slot
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
@@ -0,0 +1,87 @@
+package kite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+)
+
+// DefaultLoginURL is the Kite Connect login page users are redirected to
+const DefaultLoginURL = "https://kite.zerodha.com/connect/login"
+
+// Session is the result of exchanging a request token for an access token
+type Session struct {
+	UserID      string `json:"user_id"`
+	UserName    string `json:"user_name"`
+	Email       string `json:"email"`
+	AccessToken string `json:"access_token"`
+	PublicToken string `json:"public_token"`
+	LoginTime   string `json:"login_time"`
+}
+
+// LoginURL returns the Kite login page URL for the API key. redirectParams are passed back
+// unchanged to the registered redirect URL together with the request_token.
+func LoginURL(loginBaseURL, apiKey string, redirectParams url.Values) string {
+	if loginBaseURL == "" {
+		loginBaseURL = DefaultLoginURL
+	}
+	query := url.Values{}
+	query.Set("v", kiteVersion)
+	query.Set("api_key", apiKey)
+	if len(redirectParams) > 0 {
+		query.Set("redirect_params", redirectParams.Encode())
+	}
+	return loginBaseURL + "?" + query.Encode()
+}
+
+// Checksum returns the SHA-256 checksum of api_key + request_token + api_secret
+func Checksum(apiKey, requestToken, apiSecret string) string {
+	sum := sha256.Sum256([]byte(apiKey + requestToken + apiSecret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSession exchanges a request token for an access token and stores it on the service
+func (k *KiteService) GenerateSession(requestToken string) (*Session, error) {
+	if requestToken == "" {
+		return nil, fmt.Errorf("request token is required")
+	}
+	params := url.Values{}
+	params.Set("api_key", k.APIKey)
+	params.Set("request_token", requestToken)
+	params.Set("checksum", Checksum(k.APIKey, requestToken, k.APISecret))
+
+	var session Session
+	if err := k.doRequest(http.MethodPost, "/session/token", params, &session); err != nil {
+		return nil, err
+	}
+	if session.AccessToken == "" {
+		return nil, fmt.Errorf("Kite API returned no access token")
+	}
+	k.AccessToken = session.AccessToken
+	return &session, nil
+}
+
+// InvalidateSession logs the access token out on Kite
+func (k *KiteService) InvalidateSession() error {
+	params := url.Values{}
+	params.Set("api_key", k.APIKey)
+	params.Set("access_token", k.AccessToken)
+	return k.doRequest(http.MethodDelete, "/session/token", params, nil)
+}
+
+// SessionExpiry returns when an access token issued at loginTime stops working:
+// Kite invalidates all tokens at 6 AM IST every day
+func SessionExpiry(loginTime time.Time) time.Time {
+	local := loginTime.In(market.IST)
+	y, m, d := local.Date()
+	expiry := time.Date(y, m, d, 6, 0, 0, 0, market.IST)
+	if !expiry.After(local) {
+		expiry = expiry.AddDate(0, 0, 1)
+	}
+	return expiry
+}
@@ -0,0 +1,481 @@
+package kite
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultTickerURL is the Kite Connect WebSocket streaming endpoint
+const DefaultTickerURL = "wss://ws.kite.trade"
+
+// ErrTickerUnauthorized means Kite refused the ticker connection because of the access token.
+// Reconnecting with the same token cannot succeed, so Serve returns it.
+var ErrTickerUnauthorized = errors.New("Kite ticker rejected the access token")
+
+// Ticker modes
+const (
+	ModeLTP   = "ltp"
+	ModeQuote = "quote"
+	ModeFull  = "full"
+)
+
+// Exchange segments encoded in the low byte of an instrument token
+const (
+	segmentNSE     = 1
+	segmentNFO     = 2
+	segmentCDS     = 3
+	segmentBSE     = 4
+	segmentBFO     = 5
+	segmentBCD     = 6
+	segmentMCX     = 7
+	segmentMCXSX   = 8
+	segmentIndices = 9
+)
+
+// Packet lengths of the binary tick layout
+const (
+	packetLTP        = 8
+	packetIndexQuote = 28
+	packetIndexFull  = 32
+	packetQuote      = 44
+	packetFull       = 184
+)
+
+// Reconnect and liveness settings. Kite sends a heartbeat every second.
+const (
+	tickerDialTimeout   = 10 * time.Second
+	tickerReadTimeout   = 10 * time.Second
+	tickerMinReconnect  = 1 * time.Second
+	tickerMaxReconnect  = 60 * time.Second
+	tickerMaxSubscribed = 3000
+)
+
+// OHLC holds the day's open, high, low and previous close
+type OHLC struct {
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Close float64 `json:"close"`
+}
+
+// DepthItem is one level of market depth
+type DepthItem struct {
+	Price    float64 `json:"price"`
+	Quantity uint32  `json:"quantity"`
+	Orders   uint16  `json:"orders"`
+}
+
+// Depth holds five levels of bids and offers
+type Depth struct {
+	Buy  [5]DepthItem `json:"buy"`
+	Sell [5]DepthItem `json:"sell"`
+}
+
+// Tick is a parsed market data packet for one instrument
+type Tick struct {
+	Mode               string    `json:"mode"`
+	InstrumentToken    uint32    `json:"instrument_token"`
+	Exchange           string    `json:"exchange"`
+	IsTradable         bool      `json:"tradable"`
+	IsIndex            bool      `json:"is_index"`
+	LastPrice          float64   `json:"last_price"`
+	LastTradedQuantity uint32    `json:"last_traded_quantity,omitempty"`
+	AverageTradePrice  float64   `json:"average_trade_price,omitempty"`
+	VolumeTraded       uint32    `json:"volume_traded,omitempty"`
+	TotalBuyQuantity   uint32    `json:"total_buy_quantity,omitempty"`
+	TotalSellQuantity  uint32    `json:"total_sell_quantity,omitempty"`
+	OHLC               OHLC      `json:"ohlc"`
+	NetChange          float64   `json:"change,omitempty"`
+	LastTradeTime      time.Time `json:"last_trade_time,omitempty"`
+	OI                 uint32    `json:"oi,omitempty"`
+	OIDayHigh          uint32    `json:"oi_day_high,omitempty"`
+	OIDayLow           uint32    `json:"oi_day_low,omitempty"`
+	Timestamp          time.Time `json:"exchange_timestamp,omitempty"`
+	Depth              Depth     `json:"depth"`
+}
+
+// Ticker streams live market data from the Kite Connect WebSocket API.
+// It reconnects with exponential backoff and restores subscriptions and modes after each reconnect.
+type Ticker struct {
+	APIKey      string
+	AccessToken string
+	BaseURL     string
+
+	// Callbacks; OnTick receives every parsed binary message
+	OnTick      func([]Tick)
+	OnConnect   func()
+	OnError     func(error)
+	OnText      func(msgType string, data json.RawMessage)
+	OnReconnect func(attempt int, delay time.Duration)
+
+	mu    sync.Mutex
+	conn  *wsConn
+	modes map[uint32]string // subscribed tokens and their mode
+}
+
+// SetAccessToken replaces the access token used by the next connection
+func (t *Ticker) SetAccessToken(accessToken string) {
+	t.mu.Lock()
+	t.AccessToken = accessToken
+	t.mu.Unlock()
+}
+
+// NewTicker creates a ticker; an empty baseURL uses DefaultTickerURL
+func NewTicker(apiKey, accessToken, baseURL string) *Ticker {
+	if baseURL == "" {
+		baseURL = DefaultTickerURL
+	}
+	return &Ticker{
+		APIKey:      apiKey,
+		AccessToken: accessToken,
+		BaseURL:     baseURL,
+		modes:       make(map[uint32]string),
+	}
+}
+
+// Subscribe adds instruments to the stream in the given mode
+func (t *Ticker) Subscribe(mode string, tokens ...uint32) error {
+	if mode != ModeLTP && mode != ModeQuote && mode != ModeFull {
+		return fmt.Errorf("invalid ticker mode %q", mode)
+	}
+	t.mu.Lock()
+	added := 0
+	for _, token := range tokens {
+		if _, ok := t.modes[token]; !ok {
+			added++
+		}
+	}
+	if len(t.modes)+added > tickerMaxSubscribed {
+		t.mu.Unlock()
+		return fmt.Errorf("cannot subscribe to more than %d instruments", tickerMaxSubscribed)
+	}
+	for _, token := range tokens {
+		t.modes[token] = mode
+	}
+	conn := t.conn
+	t.mu.Unlock()
+
+	if conn == nil {
+		return nil // sent on connect
+	}
+	if err := t.send(conn, "subscribe", tokens); err != nil {
+		return err
+	}
+	return t.send(conn, "mode", []interface{}{mode, tokens})
+}
+
+// Unsubscribe removes instruments from the stream
+func (t *Ticker) Unsubscribe(tokens ...uint32) error {
+	t.mu.Lock()
+	for _, token := range tokens {
+		delete(t.modes, token)
+	}
+	conn := t.conn
+	t.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return t.send(conn, "unsubscribe", tokens)
+}
+
+// Subscriptions returns the subscribed tokens and their modes
+func (t *Ticker) Subscriptions() map[uint32]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	copied := make(map[uint32]string, len(t.modes))
+	for token, mode := range t.modes {
+		copied[token] = mode
+	}
+	return copied
+}
+
+// send writes a {"a": action, "v": value} control message
+func (t *Ticker) send(conn *wsConn, action string, value interface{}) error {
+	msg, err := json.Marshal(map[string]interface{}{"a": action, "v": value})
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(wsText, msg)
+}
+
+// Serve connects and streams until ctx is cancelled, reconnecting with backoff on failure.
+// It returns nil once ctx is cancelled, or ErrTickerUnauthorized when Kite rejects the access token.
+func (t *Ticker) Serve(ctx context.Context) error {
+	attempt := 0
+	for {
+		connected, err := t.runOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == ErrTickerUnauthorized {
+			return err
+		}
+		if err != nil && t.OnError != nil {
+			t.OnError(err)
+		}
+		if connected {
+			attempt = 0
+		}
+		attempt++
+
+		delay := backoff(attempt)
+		if t.OnReconnect != nil {
+			t.OnReconnect(attempt, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns an exponential delay with jitter, capped at tickerMaxReconnect
+func backoff(attempt int) time.Duration {
+	delay := tickerMinReconnect << min(attempt-1, 10)
+	if delay > tickerMaxReconnect {
+		delay = tickerMaxReconnect
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// runOnce holds a single connection until it fails; connected reports whether the handshake succeeded
+func (t *Ticker) runOnce(ctx context.Context) (connected bool, err error) {
+	t.mu.Lock()
+	query := url.Values{}
+	query.Set("api_key", t.APIKey)
+	query.Set("access_token", t.AccessToken)
+	t.mu.Unlock()
+	conn, err := dialWebSocket(t.BaseURL+"?"+query.Encode(), tickerDialTimeout)
+	var handshakeErr *handshakeError
+	if errors.As(err, &handshakeErr) && (handshakeErr.StatusCode == http.StatusForbidden || handshakeErr.StatusCode == http.StatusUnauthorized) {
+		return false, ErrTickerUnauthorized
+	}
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.conn = conn
+	byMode := make(map[string][]uint32)
+	for token, mode := range t.modes {
+		byMode[mode] = append(byMode[mode], token)
+	}
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.conn = nil
+		t.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Restore subscriptions from before the reconnect
+	for mode, tokens := range byMode {
+		if err := t.send(conn, "subscribe", tokens); err != nil {
+			return true, err
+		}
+		if err := t.send(conn, "mode", []interface{}{mode, tokens}); err != nil {
+			return true, err
+		}
+	}
+	if t.OnConnect != nil {
+		t.OnConnect()
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.conn.Close() })
+	defer stop()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(tickerReadTimeout))
+		opcode, message, err := conn.ReadMessage()
+		if err != nil {
+			return true, fmt.Errorf("ticker connection lost: %v", err)
+		}
+
+		switch opcode {
+		case wsBinary:
+			// Single-byte messages are heartbeats
+			if len(message) < 2 {
+				continue
+			}
+			ticks, err := ParseTicks(message)
+			if err != nil {
+				if t.OnError != nil {
+					t.OnError(err)
+				}
+				continue
+			}
+			if len(ticks) > 0 && t.OnTick != nil {
+				t.OnTick(ticks)
+			}
+		case wsText:
+			var msg struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(message, &msg); err != nil {
+				continue
+			}
+			if msg.Type == "error" {
+				log.Printf("❌ Kite ticker error: %s", string(msg.Data))
+			}
+			if t.OnText != nil {
+				t.OnText(msg.Type, msg.Data)
+			}
+		}
+	}
+}
+
+// ParseTicks parses a binary ticker message: a packet count followed by length-prefixed packets
+func ParseTicks(message []byte) ([]Tick, error) {
+	if len(message) < 2 {
+		return nil, fmt.Errorf("tick message too short")
+	}
+	count := int(binary.BigEndian.Uint16(message[0:2]))
+	ticks := make([]Tick, 0, count)
+	offset := 2
+	for i := 0; i < count; i++ {
+		if offset+2 > len(message) {
+			return nil, fmt.Errorf("tick message truncated at packet %d", i)
+		}
+		length := int(binary.BigEndian.Uint16(message[offset : offset+2]))
+		offset += 2
+		if offset+length > len(message) {
+			return nil, fmt.Errorf("tick packet %d truncated", i)
+		}
+		tick, err := ParsePacket(message[offset : offset+length])
+		if err != nil {
+			return nil, err
+		}
+		ticks = append(ticks, tick)
+		offset += length
+	}
+	return ticks, nil
+}
+
+// ParsePacket parses a single instrument packet in ltp, quote or full layout
+func ParsePacket(p []byte) (Tick, error) {
+	if len(p) < packetLTP {
+		return Tick{}, fmt.Errorf("tick packet too short: %d bytes", len(p))
+	}
+	token := u32(p, 0)
+	segment := token & 0xFF
+	divisor := priceDivisor(segment)
+	price := func(offset int) float64 { return float64(u32(p, offset)) / divisor }
+
+	tick := Tick{
+		InstrumentToken: token,
+		Exchange:        segmentName(segment),
+		IsIndex:         segment == segmentIndices,
+		IsTradable:      segment != segmentIndices,
+		LastPrice:       price(4),
+	}
+
+	switch {
+	case len(p) == packetLTP:
+		tick.Mode = ModeLTP
+
+	case tick.IsIndex && (len(p) == packetIndexQuote || len(p) == packetIndexFull):
+		tick.Mode = ModeQuote
+		tick.OHLC = OHLC{High: price(8), Low: price(12), Open: price(16), Close: price(20)}
+		tick.NetChange = float64(int32(u32(p, 24))) / divisor
+		if len(p) == packetIndexFull {
+			tick.Mode = ModeFull
+			tick.Timestamp = unixTime(u32(p, 28))
+		}
+
+	case len(p) == packetQuote || len(p) == packetFull:
+		tick.Mode = ModeQuote
+		tick.LastTradedQuantity = u32(p, 8)
+		tick.AverageTradePrice = price(12)
+		tick.VolumeTraded = u32(p, 16)
+		tick.TotalBuyQuantity = u32(p, 20)
+		tick.TotalSellQuantity = u32(p, 24)
+		tick.OHLC = OHLC{Open: price(28), High: price(32), Low: price(36), Close: price(40)}
+		if tick.OHLC.Close != 0 {
+			tick.NetChange = (tick.LastPrice - tick.OHLC.Close) * 100 / tick.OHLC.Close
+		}
+		if len(p) == packetFull {
+			tick.Mode = ModeFull
+			tick.LastTradeTime = unixTime(u32(p, 44))
+			tick.OI = u32(p, 48)
+			tick.OIDayHigh = u32(p, 52)
+			tick.OIDayLow = u32(p, 56)
+			tick.Timestamp = unixTime(u32(p, 60))
+			for i := 0; i < 10; i++ {
+				offset := 64 + i*12
+				item := DepthItem{
+					Quantity: u32(p, offset),
+					Price:    price(offset + 4),
+					Orders:   binary.BigEndian.Uint16(p[offset+8 : offset+10]),
+				}
+				if i < 5 {
+					tick.Depth.Buy[i] = item
+				} else {
+					tick.Depth.Sell[i-5] = item
+				}
+			}
+		}
+
+	default:
+		return Tick{}, fmt.Errorf("unknown tick packet length %d for token %d", len(p), token)
+	}
+	return tick, nil
+}
+
+func u32(p []byte, offset int) uint32 {
+	return binary.BigEndian.Uint32(p[offset : offset+4])
+}
+
+func unixTime(seconds uint32) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(seconds), 0)
+}
+
+// priceDivisor converts integer prices to rupees; currency segments use more decimals
+func priceDivisor(segment uint32) float64 {
+	switch segment {
+	case segmentCDS:
+		return 10000000.0
+	case segmentBCD:
+		return 10000.0
+	}
+	return 100.0
+}
+
+func segmentName(segment uint32) string {
+	switch segment {
+	case segmentNSE:
+		return "NSE"
+	case segmentNFO:
+		return "NFO"
+	case segmentCDS:
+		return "CDS"
+	case segmentBSE:
+		return "BSE"
+	case segmentBFO:
+		return "BFO"
+	case segmentBCD:
+		return "BCD"
+	case segmentMCX:
+		return "MCX"
+	case segmentMCXSX:
+		return "MCXSX"
+	case segmentIndices:
+		return "INDICES"
+	}
+	return ""
+}
@@ -0,0 +1,356 @@
+package kite
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	tokenNSE   = 408065 // INFY, NSE segment
+	tokenIndex = 256265 // NIFTY 50, indices segment
+)
+
+// packet builds a big-endian tick packet from 32-bit fields
+func packet(fields ...uint32) []byte {
+	p := make([]byte, 0, len(fields)*4)
+	for _, f := range fields {
+		p = binary.BigEndian.AppendUint32(p, f)
+	}
+	return p
+}
+
+// message frames packets the way the ticker sends them: a count, then length-prefixed packets
+func message(packets ...[]byte) []byte {
+	m := binary.BigEndian.AppendUint16(nil, uint16(len(packets)))
+	for _, p := range packets {
+		m = binary.BigEndian.AppendUint16(m, uint16(len(p)))
+		m = append(m, p...)
+	}
+	return m
+}
+
+func quotePacket(token uint32) []byte {
+	// ltp, last qty, avg price, volume, buy qty, sell qty, open, high, low, close
+	return packet(token, 150050, 10, 149900, 123456, 500, 700, 148000, 151000, 147500, 140000)
+}
+
+func TestParsePacketLTP(t *testing.T) {
+	tick, err := ParsePacket(packet(tokenNSE, 150050))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Mode != ModeLTP || tick.InstrumentToken != tokenNSE || tick.Exchange != "NSE" || tick.LastPrice != 1500.50 {
+		t.Errorf("unexpected ltp tick: %+v", tick)
+	}
+	if !tick.IsTradable || tick.IsIndex {
+		t.Errorf("NSE instrument should be tradable and not an index: %+v", tick)
+	}
+}
+
+func TestParsePacketQuote(t *testing.T) {
+	tick, err := ParsePacket(quotePacket(tokenNSE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Mode != ModeQuote {
+		t.Errorf("mode = %q, want %q", tick.Mode, ModeQuote)
+	}
+	if tick.LastTradedQuantity != 10 || tick.AverageTradePrice != 1499 || tick.VolumeTraded != 123456 ||
+		tick.TotalBuyQuantity != 500 || tick.TotalSellQuantity != 700 {
+		t.Errorf("unexpected quote fields: %+v", tick)
+	}
+	want := OHLC{Open: 1480, High: 1510, Low: 1475, Close: 1400}
+	if tick.OHLC != want {
+		t.Errorf("ohlc = %+v, want %+v", tick.OHLC, want)
+	}
+	if got := tick.NetChange; got < 7.17 || got > 7.18 {
+		t.Errorf("change = %v, want the percent change from the previous close", got)
+	}
+}
+
+func TestParsePacketFull(t *testing.T) {
+	p := quotePacket(tokenNSE)
+	traded := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	p = append(p, packet(uint32(traded.Unix()), 9000, 9500, 8500, uint32(traded.Unix()+1))...)
+	for i := 0; i < 10; i++ {
+		p = binary.BigEndian.AppendUint32(p, uint32(100+i))    // quantity
+		p = binary.BigEndian.AppendUint32(p, uint32(150000+i)) // price
+		p = binary.BigEndian.AppendUint16(p, uint16(1+i))      // orders
+		p = append(p, 0, 0)                                    // padding
+	}
+	if len(p) != packetFull {
+		t.Fatalf("test packet is %d bytes, want %d", len(p), packetFull)
+	}
+
+	tick, err := ParsePacket(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Mode != ModeFull || tick.VolumeTraded != 123456 {
+		t.Errorf("unexpected full tick: %+v", tick)
+	}
+	if !tick.LastTradeTime.Equal(traded) || !tick.Timestamp.Equal(traded.Add(time.Second)) {
+		t.Errorf("times = %v / %v, want %v / %v", tick.LastTradeTime, tick.Timestamp, traded, traded.Add(time.Second))
+	}
+	if tick.OI != 9000 || tick.OIDayHigh != 9500 || tick.OIDayLow != 8500 {
+		t.Errorf("unexpected open interest: %+v", tick)
+	}
+	if got := tick.Depth.Buy[0]; got != (DepthItem{Price: 1500, Quantity: 100, Orders: 1}) {
+		t.Errorf("best bid = %+v", got)
+	}
+	if got := tick.Depth.Sell[4]; got != (DepthItem{Price: 1500.09, Quantity: 109, Orders: 10}) {
+		t.Errorf("last offer = %+v", got)
+	}
+}
+
+func TestParsePacketIndex(t *testing.T) {
+	// ltp, high, low, open, close, change
+	quote := packet(tokenIndex, 2510000, 2520000, 2490000, 2500000, 2480000, 30000)
+	tick, err := ParsePacket(quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Mode != ModeQuote || !tick.IsIndex || tick.IsTradable || tick.Exchange != "INDICES" {
+		t.Errorf("unexpected index tick: %+v", tick)
+	}
+	want := OHLC{Open: 25000, High: 25200, Low: 24900, Close: 24800}
+	if tick.LastPrice != 25100 || tick.OHLC != want || tick.NetChange != 300 {
+		t.Errorf("unexpected index prices: %+v", tick)
+	}
+
+	stamp := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	tick, err = ParsePacket(append(quote, packet(uint32(stamp.Unix()))...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.Mode != ModeFull || !tick.Timestamp.Equal(stamp) {
+		t.Errorf("unexpected full index tick: %+v", tick)
+	}
+}
+
+func TestParsePacketRejectsUnknownLength(t *testing.T) {
+	if _, err := ParsePacket(packet(tokenNSE)); err == nil {
+		t.Error("expected an error for a packet shorter than the ltp layout")
+	}
+	if _, err := ParsePacket(packet(tokenNSE, 1, 2)); err == nil {
+		t.Error("expected an error for an unknown packet length")
+	}
+}
+
+func TestParseTicks(t *testing.T) {
+	ticks, err := ParseTicks(message(packet(tokenNSE, 150050), quotePacket(tokenNSE)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ticks) != 2 || ticks[0].Mode != ModeLTP || ticks[1].Mode != ModeQuote {
+		t.Fatalf("unexpected ticks: %+v", ticks)
+	}
+
+	truncated := message(quotePacket(tokenNSE))
+	if _, err := ParseTicks(truncated[:len(truncated)-1]); err == nil {
+		t.Error("expected an error for a truncated packet")
+	}
+	if _, err := ParseTicks([]byte{0, 2, 0, 8}); err == nil {
+		t.Error("expected an error for a message missing packets")
+	}
+}
+
+// fakeTicker is a WebSocket stand-in for the Kite ticker. Each connection records the control
+// messages it receives; the test drives it through the conns channel.
+type fakeTicker struct {
+	t     *testing.T
+	conns chan *fakeConn
+}
+
+type fakeConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	query  string
+}
+
+func newFakeTicker(t *testing.T) (*fakeTicker, *httptest.Server) {
+	f := &fakeTicker{t: t, conns: make(chan *fakeConn, 4)}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeTicker) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "not a websocket request", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		f.t.Errorf("hijack failed: %v", err)
+		return
+	}
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	rw.Flush()
+	f.conns <- &fakeConn{conn: conn, reader: rw.Reader, query: r.URL.RawQuery}
+}
+
+// next waits for the ticker to connect
+func (f *fakeTicker) next() *fakeConn {
+	select {
+	case c := <-f.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		f.t.Fatal("ticker did not connect")
+		return nil
+	}
+}
+
+// control reads the next control message from the client
+func (c *fakeConn) control(t *testing.T) (string, json.RawMessage) {
+	t.Helper()
+	ws := &wsConn{conn: c.conn, reader: c.reader}
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, op, payload, err := ws.readFrame()
+	if err != nil {
+		t.Fatalf("failed to read control message: %v", err)
+	}
+	if op != wsText {
+		t.Fatalf("opcode = %d, want a text frame", op)
+	}
+	var msg struct {
+		A string          `json:"a"`
+		V json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("invalid control message %s: %v", payload, err)
+	}
+	return msg.A, msg.V
+}
+
+// send writes an unmasked server frame
+func (c *fakeConn) send(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	if n := len(payload); n < 126 {
+		frame = append(frame, byte(n))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+// expectSubscription checks the subscribe and mode messages sent for one token
+func (c *fakeConn) expectSubscription(t *testing.T, mode string, token uint32) {
+	t.Helper()
+	action, value := c.control(t)
+	if want := fmt.Sprintf("[%d]", token); action != "subscribe" || string(value) != want {
+		t.Errorf("got %s %s, want subscribe %s", action, value, want)
+	}
+	action, value = c.control(t)
+	if want := fmt.Sprintf(`[%q,[%d]]`, mode, token); action != "mode" || string(value) != want {
+		t.Errorf("got %s %s, want mode %s", action, value, want)
+	}
+}
+
+func TestTickerServeReconnectsAndResubscribes(t *testing.T) {
+	fake, srv := newFakeTicker(t)
+	ticker := NewTicker("key", "token", "ws"+strings.TrimPrefix(srv.URL, "http"))
+	if err := ticker.Subscribe(ModeQuote, tokenNSE); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var connects, reconnects int
+	ticks := make(chan []Tick, 4)
+	ticker.OnConnect = func() { mu.Lock(); connects++; mu.Unlock() }
+	ticker.OnReconnect = func(int, time.Duration) { mu.Lock(); reconnects++; mu.Unlock() }
+	ticker.OnTick = func(batch []Tick) { ticks <- batch }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { ticker.Serve(ctx); close(done) }()
+
+	first := fake.next()
+	if !strings.Contains(first.query, "api_key=key") || !strings.Contains(first.query, "access_token=token") {
+		t.Errorf("query = %q, want the api key and access token", first.query)
+	}
+	first.expectSubscription(t, ModeQuote, tokenNSE)
+	first.send(wsBinary, []byte{0}) // heartbeat
+	first.send(wsBinary, message(quotePacket(tokenNSE)))
+	select {
+	case batch := <-ticks:
+		if len(batch) != 1 || batch[0].InstrumentToken != tokenNSE || batch[0].Mode != ModeQuote {
+			t.Errorf("unexpected ticks: %+v", batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ticks delivered")
+	}
+
+	// Dropping the connection makes the ticker reconnect and restore its subscription
+	first.conn.Close()
+	second := fake.next()
+	second.expectSubscription(t, ModeQuote, tokenNSE)
+	second.send(wsBinary, message(packet(tokenNSE, 150100)))
+	select {
+	case batch := <-ticks:
+		if len(batch) != 1 || batch[0].LastPrice != 1501 {
+			t.Errorf("unexpected ticks after reconnect: %+v", batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ticks delivered after reconnect")
+	}
+
+	// Subscribing while connected is sent straight away
+	if err := ticker.Subscribe(ModeFull, tokenNSE); err != nil {
+		t.Fatal(err)
+	}
+	second.expectSubscription(t, ModeFull, tokenNSE)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if connects != 2 || reconnects != 1 {
+		t.Errorf("connects = %d, reconnects = %d, want 2 and 1", connects, reconnects)
+	}
+}
+
+func TestTickerServeStopsWhenTokenRejected(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "invalid access token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	ticker := NewTicker("key", "expired", "ws"+strings.TrimPrefix(srv.URL, "http"))
+	done := make(chan error, 1)
+	go func() { done <- ticker.Serve(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != ErrTickerUnauthorized {
+			t.Errorf("Serve = %v, want %v", err, ErrTickerUnauthorized)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve kept retrying a rejected token")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("connected %d times, want 1", n)
+	}
+}
@@ -0,0 +1,77 @@
+package kite
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+)
+
+var (
+	ErrNotConfigured = errors.New("Kite API credentials not configured. Please set KITE_API_KEY and KITE_API_SECRET environment variables.")
+	ErrLoginRequired = errors.New("Kite session missing or expired. Please log in to Kite again.")
+)
+
+// NewServiceFromEnv creates a Kite client from KITE_API_KEY, KITE_API_SECRET and KITE_BASE_URL
+func NewServiceFromEnv() (*KiteService, error) {
+	apiKey := os.Getenv("KITE_API_KEY")
+	apiSecret := os.Getenv("KITE_API_SECRET")
+	if apiKey == "" || apiSecret == "" {
+		return nil, ErrNotConfigured
+	}
+	return NewKiteService(apiKey, apiSecret, os.Getenv("KITE_BASE_URL")), nil
+}
+
+// ForUser returns a Kite client authorized with the user's current access token
+func ForUser(userID int) (*KiteService, error) {
+	service, err := NewServiceFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	session, err := db.GetKiteSession(userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrLoginRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.Expired() {
+		return nil, ErrLoginRequired
+	}
+	service.AccessToken = session.AccessToken
+	return service, nil
+}
+
+var (
+	loginMu        sync.RWMutex
+	loginListeners []func(userID int)
+)
+
+// OnLogin registers a function that is called after a user stores a new Kite session
+func OnLogin(fn func(userID int)) {
+	loginMu.Lock()
+	loginListeners = append(loginListeners, fn)
+	loginMu.Unlock()
+}
+
+// NotifyLogin tells the OnLogin listeners that a user has a new access token
+func NotifyLogin(userID int) {
+	loginMu.RLock()
+	defer loginMu.RUnlock()
+	for _, fn := range loginListeners {
+		fn(userID)
+	}
+}
+
+// CheckTokenError expires the user's stored session when Kite rejects the access token
+func CheckTokenError(userID int, err error) {
+	if err != nil && IsTokenError(err) {
+		log.Printf("⚠️  Kite rejected the access token for user %d, marking session expired", userID)
+		if err := db.ExpireKiteSession(userID); err != nil {
+			log.Printf("Failed to expire Kite session: %v", err)
+		}
+	}
+}
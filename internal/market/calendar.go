@@ -0,0 +1,215 @@
+package market
+
+import (
+	"strings"
+	"time"
+)
+
+// IST is Indian Standard Time, the clock all Indian exchange sessions follow
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// DateFormat is the layout used for holiday dates
+const DateFormat = "2006-01-02"
+
+// Calendars; every exchange maps onto one of these
+const (
+	NSE = "NSE" // NSE and BSE cash and F&O (NSE, NFO, BSE, BFO)
+	MCX = "MCX" // commodities
+	CDS = "CDS" // currency derivatives (CDS, BCD)
+)
+
+// Calendars lists every calendar
+var Calendars = []string{NSE, MCX, CDS}
+
+// Session names
+const (
+	SessionPreOpen = "pre_open"
+	SessionNormal  = "normal"
+	SessionClosing = "closing" // post-close session, trades at the closing price
+	SessionEvening = "evening" // MCX evening session
+)
+
+// Session is a trading session within a day, in minutes after midnight IST
+type Session struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// continuous reports whether the session has continuous trading
+func (s Session) continuous() bool {
+	return s.Name == SessionNormal || s.Name == SessionEvening
+}
+
+func hm(hour, minute int) int {
+	return hour*60 + minute
+}
+
+// weeklyOff are the days every calendar is closed
+var weeklyOff = map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}
+
+// Calendar returns the calendar an exchange (or Kite segment such as "NFO-OPT") trades on; unknown exchanges use NSE
+func Calendar(exchange string) string {
+	exchange = strings.ToUpper(strings.TrimSpace(exchange))
+	if i := strings.Index(exchange, "-"); i >= 0 {
+		exchange = exchange[:i]
+	}
+	switch exchange {
+	case MCX:
+		return MCX
+	case CDS, "BCD":
+		return CDS
+	}
+	return NSE
+}
+
+// Sessions returns the sessions of a calendar on the given day, in order. MCX's evening
+// session runs until 23:55 while the US is on daylight saving time and until 23:30 otherwise.
+func Sessions(calendar string, day time.Time) []Session {
+	switch Calendar(calendar) {
+	case MCX:
+		eveningEnd := hm(23, 30)
+		if usDaylightSaving(day) {
+			eveningEnd = hm(23, 55)
+		}
+		return []Session{
+			{Name: SessionNormal, Start: hm(9, 0), End: hm(17, 0)},
+			{Name: SessionEvening, Start: hm(17, 0), End: eveningEnd},
+		}
+	case CDS:
+		return []Session{
+			{Name: SessionNormal, Start: hm(9, 0), End: hm(17, 0)},
+		}
+	}
+	return []Session{
+		{Name: SessionPreOpen, Start: hm(9, 0), End: hm(9, 15)},
+		{Name: SessionNormal, Start: hm(9, 15), End: hm(15, 30)},
+		{Name: SessionClosing, Start: hm(15, 40), End: hm(16, 0)},
+	}
+}
+
+// usDaylightSaving reports whether US daylight saving time is in effect on the day
+// (second Sunday of March to first Sunday of November)
+func usDaylightSaving(day time.Time) bool {
+	y := day.In(IST).Year()
+	start := nthSunday(y, time.March, 2)
+	end := nthSunday(y, time.November, 1)
+	d := dateOf(day)
+	return !d.Before(start) && d.Before(end)
+}
+
+func nthSunday(year int, month time.Month, n int) time.Time {
+	t := time.Date(year, month, 1, 0, 0, 0, 0, IST)
+	for t.Weekday() != time.Sunday {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.AddDate(0, 0, 7*(n-1))
+}
+
+// dateOf returns midnight IST of t's day
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.In(IST).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, IST)
+}
+
+func at(day time.Time, minutes int) time.Time {
+	return dateOf(day).Add(time.Duration(minutes) * time.Minute)
+}
+
+// IsTradingDay reports whether the calendar trades on t's day (IST): not a weekly off-day or holiday
+func IsTradingDay(calendar string, t time.Time) bool {
+	if weeklyOff[t.In(IST).Weekday()] {
+		return false
+	}
+	_, holiday := HolidayOn(calendar, t)
+	return !holiday
+}
+
+// CurrentSession returns the session in progress at t, if any
+func CurrentSession(calendar string, t time.Time) (Session, bool) {
+	if !IsTradingDay(calendar, t) {
+		return Session{}, false
+	}
+	minutes := t.In(IST).Hour()*60 + t.In(IST).Minute()
+	for _, s := range Sessions(calendar, t) {
+		if minutes >= s.Start && minutes < s.End {
+			return s, true
+		}
+	}
+	return Session{}, false
+}
+
+// IsOpen reports whether continuous trading is in progress at t (normal, or MCX evening, session)
+func IsOpen(calendar string, t time.Time) bool {
+	s, ok := CurrentSession(calendar, t)
+	return ok && s.continuous()
+}
+
+// Open returns the start of continuous trading on t's day
+func Open(calendar string, t time.Time) time.Time {
+	for _, s := range Sessions(calendar, t) {
+		if s.continuous() {
+			return at(t, s.Start)
+		}
+	}
+	return dateOf(t)
+}
+
+// Close returns the end of continuous trading on t's day
+func Close(calendar string, t time.Time) time.Time {
+	var end int
+	for _, s := range Sessions(calendar, t) {
+		if s.continuous() && s.End > end {
+			end = s.End
+		}
+	}
+	return at(t, end)
+}
+
+// NextTradingDay returns midnight IST of the first trading day after t's day
+func NextTradingDay(calendar string, t time.Time) time.Time {
+	d := dateOf(t).AddDate(0, 0, 1)
+	for !IsTradingDay(calendar, d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// PreviousTradingDay returns midnight IST of the last trading day before t's day
+func PreviousTradingDay(calendar string, t time.Time) time.Time {
+	d := dateOf(t).AddDate(0, 0, -1)
+	for !IsTradingDay(calendar, d) {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// LastTradingDay returns midnight IST of t's day if it is a trading day, otherwise of the trading day before
+func LastTradingDay(calendar string, t time.Time) time.Time {
+	if IsTradingDay(calendar, t) {
+		return dateOf(t)
+	}
+	return PreviousTradingDay(calendar, t)
+}
+
+// NextOpen returns when continuous trading next starts after t
+func NextOpen(calendar string, t time.Time) time.Time {
+	day := dateOf(t)
+	for i := 0; i < 366; i++ {
+		if IsTradingDay(calendar, day) {
+			prevEnd := -1
+			for _, s := range Sessions(calendar, day) {
+				if !s.continuous() {
+					continue
+				}
+				// A session that starts as the previous one ends (MCX evening) is not a new open
+				if start := at(day, s.Start); s.Start != prevEnd && start.After(t) {
+					return start
+				}
+				prevEnd = s.End
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
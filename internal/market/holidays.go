@@ -0,0 +1,140 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// holidays caches the market_holidays table: calendar -> date -> description
+var (
+	holidaysMu     sync.RWMutex
+	holidays       map[string]map[string]string
+	holidaysLoaded bool
+)
+
+// loadHolidays reads every holiday into the cache
+func loadHolidays() error {
+	rows, err := db.DB.Query("SELECT calendar, holiday_date, COALESCE(description, '') FROM market_holidays")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	loaded := make(map[string]map[string]string)
+	for rows.Next() {
+		var calendar, date, description string
+		if err := rows.Scan(&calendar, &date, &description); err != nil {
+			return err
+		}
+		if loaded[calendar] == nil {
+			loaded[calendar] = make(map[string]string)
+		}
+		loaded[calendar][date] = description
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	holidaysMu.Lock()
+	holidays = loaded
+	holidaysLoaded = true
+	holidaysMu.Unlock()
+	return nil
+}
+
+// HolidayOn reports whether t's day (IST) is a holiday on the calendar, with its description
+func HolidayOn(calendar string, t time.Time) (string, bool) {
+	holidaysMu.RLock()
+	loaded := holidaysLoaded
+	holidaysMu.RUnlock()
+	if !loaded {
+		if err := loadHolidays(); err != nil {
+			log.Printf("❌ Failed to load market holidays: %v", err)
+			return "", false
+		}
+	}
+
+	holidaysMu.RLock()
+	defer holidaysMu.RUnlock()
+	description, ok := holidays[Calendar(calendar)][t.In(IST).Format(DateFormat)]
+	return description, ok
+}
+
+// Holidays returns a calendar's holidays in a year, oldest first
+func Holidays(calendar string, year int) ([]models.MarketHoliday, error) {
+	rows, err := db.DB.Query("SELECT holiday_date, COALESCE(description, '') FROM market_holidays WHERE calendar = ? AND holiday_date >= ? AND holiday_date <= ? ORDER BY holiday_date",
+		Calendar(calendar), fmt.Sprintf("%04d-01-01", year), fmt.Sprintf("%04d-12-31", year))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.MarketHoliday
+	for rows.Next() {
+		var h models.MarketHoliday
+		if err := rows.Scan(&h.Date, &h.Description); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// SaveHolidays replaces a calendar's holidays for one year
+func SaveHolidays(calendar string, year int, list []models.MarketHoliday) error {
+	calendar = Calendar(calendar)
+	for _, h := range list {
+		d, err := time.ParseInLocation(DateFormat, h.Date, IST)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", h.Date)
+		}
+		if d.Year() != year {
+			return fmt.Errorf("holiday %s is not in %d", h.Date, year)
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM market_holidays WHERE calendar = ? AND holiday_date >= ? AND holiday_date <= ?",
+		calendar, fmt.Sprintf("%04d-01-01", year), fmt.Sprintf("%04d-12-31", year)); err != nil {
+		return fmt.Errorf("failed to clear holidays: %v", err)
+	}
+	for _, h := range list {
+		if _, err := tx.Exec("INSERT INTO market_holidays (calendar, holiday_date, description) VALUES (?, ?, ?) ON CONFLICT (calendar, holiday_date) DO UPDATE SET description = excluded.description",
+			calendar, h.Date, h.Description); err != nil {
+			return fmt.Errorf("failed to insert holiday %s: %v", h.Date, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return loadHolidays()
+}
+
+// Status describes a calendar at time t
+func Status(calendar string, t time.Time) models.MarketStatus {
+	calendar = Calendar(calendar)
+	status := models.MarketStatus{
+		Exchange:   calendar,
+		Time:       t.In(IST),
+		TradingDay: IsTradingDay(calendar, t),
+		NextOpen:   NextOpen(calendar, t),
+	}
+	status.Holiday, _ = HolidayOn(calendar, t)
+	if s, ok := CurrentSession(calendar, t); ok {
+		status.Session = s.Name
+		status.Open = s.continuous()
+		ends := at(t, s.End)
+		status.SessionEnds = &ends
+	}
+	return status
+}
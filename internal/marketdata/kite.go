@@ -0,0 +1,249 @@
+package marketdata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/kite"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// KiteProvider streams ticks from the Kite ticker and fetches candles from the historical data API.
+// It authenticates with a stored Kite login session, which expires daily, and picks up the new
+// access token when the user logs in again.
+type KiteProvider struct {
+	Ticker  *kite.Ticker
+	Service *kite.KiteService // API key and base URL; the access token comes from the session
+	Mode    string            // ticker mode for new subscriptions
+	UserID  int               // whose session to use; 0 follows the latest login of any user
+
+	mu      sync.RWMutex
+	symbols map[uint32]string // instrument token -> symbol used in the quote store
+	login   chan struct{}     // signalled when a user logs in to Kite
+}
+
+// NewKiteProvider creates a provider for a user's Kite session; empty URLs use the Kite defaults
+func NewKiteProvider(apiKey, baseURL, tickerURL, mode string, userID int) *KiteProvider {
+	if mode == "" {
+		mode = kite.ModeQuote
+	}
+	p := &KiteProvider{
+		Ticker:  kite.NewTicker(apiKey, "", tickerURL),
+		Service: kite.NewKiteService(apiKey, "", baseURL),
+		Mode:    mode,
+		UserID:  userID,
+		symbols: make(map[uint32]string),
+		login:   make(chan struct{}, 1),
+	}
+	kite.OnLogin(func(userID int) {
+		if p.UserID == 0 || userID == p.UserID {
+			select {
+			case p.login <- struct{}{}:
+			default:
+			}
+		}
+	})
+	return p
+}
+
+// NewKiteProviderFromEnv reads KITE_API_KEY, with KITE_BASE_URL, KITE_TICKER_URL and
+// KITE_TICKER_MODE overrides. KITE_TICKER_USER_ID picks whose Kite session the feed uses;
+// by default it follows the latest login. KITE_TICKER_INSTRUMENTS ("token:symbol" pairs)
+// subscribes instruments that are not in the instruments master.
+func NewKiteProviderFromEnv() (*KiteProvider, error) {
+	apiKey := os.Getenv("KITE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("the kite provider needs KITE_API_KEY")
+	}
+	userID := 0
+	if value := os.Getenv("KITE_TICKER_USER_ID"); value != "" {
+		var err error
+		if userID, err = strconv.Atoi(value); err != nil || userID <= 0 {
+			return nil, fmt.Errorf("invalid KITE_TICKER_USER_ID %q", value)
+		}
+	}
+	p := NewKiteProvider(apiKey, os.Getenv("KITE_BASE_URL"), os.Getenv("KITE_TICKER_URL"), os.Getenv("KITE_TICKER_MODE"), userID)
+
+	pairs, err := kite.ParseInstrumentList(os.Getenv("KITE_TICKER_INSTRUMENTS"))
+	if err != nil {
+		return nil, err
+	}
+	for token, symbol := range pairs {
+		if err := p.Subscribe(models.Instrument{InstrumentToken: int64(token), TradingSymbol: symbol}); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *KiteProvider) Name() string { return ProviderKite }
+
+// Subscribe adds instruments to the ticker; they need an instrument token
+func (p *KiteProvider) Subscribe(instruments ...models.Instrument) error {
+	tokens := make([]uint32, 0, len(instruments))
+	p.mu.Lock()
+	for _, inst := range instruments {
+		if inst.InstrumentToken == 0 {
+			p.mu.Unlock()
+			return fmt.Errorf("no instrument token for %s; load the instruments master", inst.TradingSymbol)
+		}
+		token := uint32(inst.InstrumentToken)
+		p.symbols[token] = inst.TradingSymbol
+		tokens = append(tokens, token)
+	}
+	p.mu.Unlock()
+	return p.Ticker.Subscribe(p.Mode, tokens...)
+}
+
+// Unsubscribe removes instruments from the ticker
+func (p *KiteProvider) Unsubscribe(instruments ...models.Instrument) error {
+	tokens := make([]uint32, 0, len(instruments))
+	p.mu.Lock()
+	for _, inst := range instruments {
+		token := uint32(inst.InstrumentToken)
+		delete(p.symbols, token)
+		tokens = append(tokens, token)
+	}
+	p.mu.Unlock()
+	return p.Ticker.Unsubscribe(tokens...)
+}
+
+// session returns the stored Kite session the provider authenticates with
+func (p *KiteProvider) session() (*db.KiteSession, error) {
+	var session *db.KiteSession
+	var err error
+	if p.UserID != 0 {
+		session, err = db.GetKiteSession(p.UserID)
+	} else {
+		session, err = db.LatestKiteSession()
+	}
+	if err == sql.ErrNoRows || (err == nil && session.Expired()) {
+		return nil, kite.ErrLoginRequired
+	}
+	return session, err
+}
+
+// service returns a Kite client authorized with the current session's access token
+func (p *KiteProvider) service() (*kite.KiteService, error) {
+	session, err := p.session()
+	if err != nil {
+		return nil, err
+	}
+	service := *p.Service
+	service.AccessToken = session.AccessToken
+	return &service, nil
+}
+
+// Run streams until ctx is cancelled. Without a valid Kite session, or once Kite rejects the
+// access token, it waits for the next Kite login and connects with the new token.
+func (p *KiteProvider) Run(ctx context.Context, onTicks func([]models.Quote)) error {
+	p.Ticker.OnConnect = func() { log.Printf("✅ Kite ticker connected to %s", p.Ticker.BaseURL) }
+	p.Ticker.OnError = func(err error) { log.Printf("⚠️  Kite ticker: %v", err) }
+	p.Ticker.OnReconnect = func(attempt int, delay time.Duration) {
+		log.Printf("🔄 Kite ticker reconnecting in %v (attempt %d)", delay.Round(time.Millisecond), attempt)
+	}
+	p.Ticker.OnTick = func(ticks []kite.Tick) {
+		batch := make([]models.Quote, 0, len(ticks))
+		p.mu.RLock()
+		for _, t := range ticks {
+			symbol, ok := p.symbols[t.InstrumentToken]
+			if !ok || t.LastPrice <= 0 {
+				continue
+			}
+			batch = append(batch, kite.TickToQuote(t, symbol))
+		}
+		p.mu.RUnlock()
+		if len(batch) > 0 {
+			onTicks(batch)
+		}
+	}
+	for {
+		session, err := p.session()
+		if err != nil {
+			retry := sessionRetryDelay
+			if err == kite.ErrLoginRequired {
+				log.Printf("🔒 Kite ticker is waiting for a Kite login")
+				retry = 0
+			} else {
+				log.Printf("❌ Kite ticker failed to load the Kite session: %v", err)
+			}
+			if !p.waitForLogin(ctx, retry) {
+				return nil
+			}
+			continue
+		}
+
+		p.Ticker.SetAccessToken(session.AccessToken)
+		if err := p.Ticker.Serve(ctx); err != kite.ErrTickerUnauthorized {
+			return err
+		}
+		log.Printf("🔒 Kite rejected the ticker access token of user %d; waiting for a new Kite login", session.UserID)
+		// Expire the session only if the user has not logged in again in the meantime
+		if current, err := db.GetKiteSession(session.UserID); err == nil && current.AccessToken == session.AccessToken {
+			if err := db.ExpireKiteSession(session.UserID); err != nil {
+				log.Printf("Failed to expire Kite session: %v", err)
+			}
+		}
+	}
+}
+
+// sessionRetryDelay is how long the ticker waits before reading the session again after a database error
+const sessionRetryDelay = time.Minute
+
+// waitForLogin blocks until a user logs in to Kite or, when retry is not zero, until it has passed.
+// It returns false once ctx is cancelled.
+func (p *KiteProvider) waitForLogin(ctx context.Context, retry time.Duration) bool {
+	var timeout <-chan time.Time
+	if retry > 0 {
+		timeout = time.After(retry)
+	}
+	select {
+	case <-p.login:
+		return true
+	case <-timeout:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Historical fetches candles from the Kite historical data API, splitting long ranges into
+// requests Kite accepts
+func (p *KiteProvider) Historical(inst models.Instrument, interval string, from, to time.Time) ([]models.Candle, error) {
+	if inst.InstrumentToken == 0 {
+		return nil, fmt.Errorf("no instrument token for %s; load the instruments master", inst.TradingSymbol)
+	}
+	maxDays, ok := kite.HistoricalMaxDays[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	derivative := inst.InstrumentType == "FUT" || inst.InstrumentType == "CE" || inst.InstrumentType == "PE"
+	service, err := p.service()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Candle
+	for start := from; start.Before(to); start = start.AddDate(0, 0, maxDays) {
+		end := start.AddDate(0, 0, maxDays)
+		if end.After(to) {
+			end = to
+		}
+		chunk, err := service.HistoricalData(inst.InstrumentToken, interval, start, end.Add(-time.Second), derivative)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunk {
+			c.Symbol = inst.TradingSymbol
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+// Package marketdata abstracts where live ticks and historical candles come from: the Kite
+// ticker and historical API, a CSV replay, or a random-walk simulator for working offline.
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/alertexpr"
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/instruments"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// Provider is a source of live ticks and historical candles
+type Provider interface {
+	// Name identifies the provider in logs and responses
+	Name() string
+
+	// Subscribe and Unsubscribe change which instruments Run delivers ticks for
+	Subscribe(instruments ...models.Instrument) error
+	Unsubscribe(instruments ...models.Instrument) error
+
+	// Run delivers ticks as quotes until ctx is cancelled
+	Run(ctx context.Context, onTicks func([]models.Quote)) error
+
+	// Historical returns candles for an instrument with start in [from, to), oldest first
+	Historical(inst models.Instrument, interval string, from, to time.Time) ([]models.Candle, error)
+}
+
+// Provider names accepted in MARKETDATA_PROVIDER
+const (
+	ProviderKite      = "kite"
+	ProviderReplay    = "replay"
+	ProviderSimulator = "simulator"
+)
+
+var (
+	currentMu sync.RWMutex
+	current   Provider
+)
+
+// Current returns the running provider, or nil when live market data is not configured
+func Current() Provider {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// NewFromEnv builds the provider named by MARKETDATA_PROVIDER. Without it, the Kite provider is
+// used when Kite logins are configured (KITE_API_KEY and KITE_API_SECRET); otherwise it returns nil.
+func NewFromEnv() (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("MARKETDATA_PROVIDER")))
+	if name == "" && os.Getenv("KITE_API_KEY") != "" && os.Getenv("KITE_API_SECRET") != "" {
+		name = ProviderKite
+	}
+	switch name {
+	case "":
+		return nil, nil
+	case ProviderKite:
+		return NewKiteProviderFromEnv()
+	case ProviderReplay:
+		return NewReplayProviderFromEnv()
+	case ProviderSimulator:
+		return NewSimulatorFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown MARKETDATA_PROVIDER %q, expected kite, replay or simulator", name)
+	}
+}
+
+// StartFromEnv starts the configured provider feeding the quote store. It subscribes to
+// MARKETDATA_SYMBOLS, every active alert's symbol and every traded symbol.
+func StartFromEnv(ctx context.Context) Provider {
+	provider, err := NewFromEnv()
+	if err != nil {
+		log.Printf("❌ Market data not started: %v", err)
+		return nil
+	}
+	if provider == nil {
+		return nil
+	}
+
+	symbols, err := startupSymbols()
+	if err != nil {
+		log.Printf("❌ Market data not started: %v", err)
+		return nil
+	}
+	for _, symbol := range symbols {
+		if err := provider.Subscribe(Lookup(symbol)); err != nil {
+			log.Printf("⚠️  %s market data: not subscribed to %s: %v", provider.Name(), symbol, err)
+		}
+	}
+
+	currentMu.Lock()
+	current = provider
+	currentMu.Unlock()
+
+	go func() {
+		if err := provider.Run(ctx, saveQuotes); err != nil && ctx.Err() == nil {
+			log.Printf("❌ %s market data stopped: %v", provider.Name(), err)
+		}
+	}()
+	log.Printf("📡 %s market data started", provider.Name())
+	return provider
+}
+
+// Watch subscribes the running provider to a symbol, e.g. when an alert or trade is recorded
+func Watch(symbol string) {
+	provider := Current()
+	if provider == nil || symbol == "" {
+		return
+	}
+	if err := provider.Subscribe(Lookup(symbol)); err != nil {
+		log.Printf("⚠️  %s market data: not subscribed to %s: %v", provider.Name(), symbol, err)
+	}
+}
+
+// Lookup returns the instruments master row for a symbol, or an instrument carrying only the
+// symbol when the master is not loaded or does not list it
+func Lookup(symbol string) models.Instrument {
+	if inst, err := instruments.Resolve(symbol, false); err == nil {
+		return inst
+	}
+	return models.Instrument{TradingSymbol: symbol}
+}
+
+func saveQuotes(batch []models.Quote) {
+	if err := quotes.Save(batch); err != nil {
+		log.Printf("❌ Failed to store market data quotes: %v", err)
+	}
+}
+
+// startupSymbols lists MARKETDATA_SYMBOLS plus the symbols of active alerts and recorded trades
+func startupSymbols() ([]string, error) {
+	seen := make(map[string]bool)
+	var symbols []string
+	add := func(symbol string) {
+		symbol = strings.TrimSpace(symbol)
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, symbol := range strings.Split(os.Getenv("MARKETDATA_SYMBOLS"), ",") {
+		add(symbol)
+	}
+
+	rows, err := db.DB.Query("SELECT DISTINCT symbol, COALESCE(expression, '') FROM alerts WHERE is_active = 1 UNION SELECT DISTINCT symbol, '' FROM stocks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watched symbols: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, expression string
+		if err := rows.Scan(&symbol, &expression); err != nil {
+			return nil, fmt.Errorf("failed to scan symbol: %v", err)
+		}
+		add(symbol)
+		// Expression alerts also read the symbols they name
+		if expr, err := alertexpr.Parse(expression); expression != "" && err == nil {
+			for _, s := range expr.Symbols(symbol) {
+				add(s)
+			}
+		}
+	}
+	return symbols, rows.Err()
+}
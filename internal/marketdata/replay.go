@@ -0,0 +1,261 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// replayBatchWindow groups ticks this close together in recorded time into one batch
+const replayBatchWindow = 100 * time.Millisecond
+
+// ReplayProvider plays back recorded ticks from a CSV file. Ticks are re-stamped with the time they
+// are played so that the rest of the app sees them as live; Historical serves the recorded times.
+type ReplayProvider struct {
+	Speed float64 // 2 plays twice as fast as recorded; 0 plays the file once without pauses
+	Loop  bool    // start over at the end of the file
+
+	ticks []models.Quote // sorted by recorded time
+
+	mu         sync.RWMutex
+	subscribed map[string]bool
+}
+
+// NewReplayProvider reads ticks from a CSV file with a header of timestamp, symbol and ltp columns,
+// and optional exchange, bid, ask, volume and oi columns
+func NewReplayProvider(path string, speed float64, loop bool) (*ReplayProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ticks, err := parseTicks(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(ticks) == 0 {
+		return nil, fmt.Errorf("%s has no ticks", path)
+	}
+	return &ReplayProvider{Speed: speed, Loop: loop, ticks: ticks, subscribed: make(map[string]bool)}, nil
+}
+
+// NewReplayProviderFromEnv reads MARKETDATA_REPLAY_FILE, MARKETDATA_REPLAY_SPEED (default 1) and
+// MARKETDATA_REPLAY_LOOP (default true). Every symbol in the file is subscribed.
+func NewReplayProviderFromEnv() (*ReplayProvider, error) {
+	path := os.Getenv("MARKETDATA_REPLAY_FILE")
+	if path == "" {
+		return nil, errors.New("the replay provider needs MARKETDATA_REPLAY_FILE")
+	}
+	speed := 1.0
+	if value := os.Getenv("MARKETDATA_REPLAY_SPEED"); value != "" {
+		s, err := strconv.ParseFloat(value, 64)
+		if err != nil || s < 0 {
+			return nil, fmt.Errorf("invalid MARKETDATA_REPLAY_SPEED %q", value)
+		}
+		speed = s
+	}
+	loop := true
+	if value := os.Getenv("MARKETDATA_REPLAY_LOOP"); value != "" {
+		l, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MARKETDATA_REPLAY_LOOP %q", value)
+		}
+		loop = l
+	}
+
+	p, err := NewReplayProvider(path, speed, loop)
+	if err != nil {
+		return nil, err
+	}
+	for _, symbol := range p.Symbols() {
+		p.Subscribe(models.Instrument{TradingSymbol: symbol})
+	}
+	return p, nil
+}
+
+// parseTicks reads the replay CSV, matching columns by header name
+func parseTicks(r io.Reader) ([]models.Quote, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"timestamp", "symbol", "ltp"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	number := func(record []string, name string) (float64, error) {
+		value := field(record, name)
+		if value == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	var ticks []models.Quote
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		ts, err := candles.ParseTime(field(record, "timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		q := models.Quote{Symbol: field(record, "symbol"), Exchange: field(record, "exchange"), Timestamp: ts}
+		if q.Symbol == "" {
+			return nil, fmt.Errorf("line %d: symbol is required", line)
+		}
+		if q.LTP, err = number(record, "ltp"); err != nil || q.LTP <= 0 {
+			return nil, fmt.Errorf("line %d: ltp must be a positive number", line)
+		}
+		var volume, oi float64
+		if q.Bid, err = number(record, "bid"); err != nil {
+			return nil, fmt.Errorf("line %d: invalid bid", line)
+		}
+		if q.Ask, err = number(record, "ask"); err != nil {
+			return nil, fmt.Errorf("line %d: invalid ask", line)
+		}
+		if volume, err = number(record, "volume"); err != nil {
+			return nil, fmt.Errorf("line %d: invalid volume", line)
+		}
+		if oi, err = number(record, "oi"); err != nil {
+			return nil, fmt.Errorf("line %d: invalid oi", line)
+		}
+		q.Volume, q.OI = int64(volume), int64(oi)
+		ticks = append(ticks, q)
+	}
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Timestamp.Before(ticks[j].Timestamp) })
+	return ticks, nil
+}
+
+func (p *ReplayProvider) Name() string { return ProviderReplay }
+
+// Symbols returns the symbols recorded in the file
+func (p *ReplayProvider) Symbols() []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, q := range p.ticks {
+		if !seen[q.Symbol] {
+			seen[q.Symbol] = true
+			symbols = append(symbols, q.Symbol)
+		}
+	}
+	return symbols
+}
+
+func (p *ReplayProvider) Subscribe(instruments ...models.Instrument) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, inst := range instruments {
+		p.subscribed[inst.TradingSymbol] = true
+	}
+	return nil
+}
+
+func (p *ReplayProvider) Unsubscribe(instruments ...models.Instrument) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, inst := range instruments {
+		delete(p.subscribed, inst.TradingSymbol)
+	}
+	return nil
+}
+
+// Run plays the file, pausing between batches for the recorded gap divided by Speed
+func (p *ReplayProvider) Run(ctx context.Context, onTicks func([]models.Quote)) error {
+	for {
+		for i := 0; i < len(p.ticks); {
+			// Ticks close together in recorded time go out as one batch
+			j := i + 1
+			for j < len(p.ticks) && p.ticks[j].Timestamp.Sub(p.ticks[i].Timestamp) < replayBatchWindow {
+				j++
+			}
+
+			now := time.Now()
+			batch := make([]models.Quote, 0, j-i)
+			p.mu.RLock()
+			for _, q := range p.ticks[i:j] {
+				if p.subscribed[q.Symbol] {
+					q.Timestamp = now
+					batch = append(batch, q)
+				}
+			}
+			p.mu.RUnlock()
+			if len(batch) > 0 {
+				onTicks(batch)
+			}
+
+			if j < len(p.ticks) && p.Speed > 0 {
+				gap := time.Duration(float64(p.ticks[j].Timestamp.Sub(p.ticks[j-1].Timestamp)) / p.Speed)
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(gap):
+				}
+			} else if ctx.Err() != nil {
+				return nil
+			}
+			i = j
+		}
+		if !p.Loop || p.Speed == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Historical aggregates the recorded ticks of the instrument into candles
+func (p *ReplayProvider) Historical(inst models.Instrument, interval string, from, to time.Time) ([]models.Candle, error) {
+	if _, ok := candles.Intervals[interval]; !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	calendar := market.Calendar(inst.Exchange)
+	var ticks []models.Quote
+	for _, q := range p.ticks {
+		if q.Symbol == inst.TradingSymbol && !q.Timestamp.Before(candles.BucketStart(from, interval, calendar)) && q.Timestamp.Before(to) {
+			ticks = append(ticks, q)
+		}
+	}
+	result := []models.Candle{}
+	for _, c := range candles.Aggregate(ticks, interval, calendar) {
+		if !c.Start.Before(from) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,261 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/candles"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+const (
+	// tradingYear is the trading time in a year (252 sessions of 6h15m) that drift and volatility refer to
+	tradingYear = 252 * 375 * time.Minute
+
+	// defaultSimPrice starts symbols with no configured price and no stored quote
+	defaultSimPrice = 100.0
+
+	// simTickSize is the price step simulated prices are rounded to, ticksPerRupee its inverse
+	simTickSize   = 0.05
+	ticksPerRupee = 20
+
+	// simSubsteps is how many price steps make up each historical candle
+	simSubsteps = 12
+)
+
+// Simulator generates prices by geometric Brownian motion, for demos and testing without a broker
+type Simulator struct {
+	Drift      float64            // annualized expected return, e.g. 0.1
+	Volatility float64            // annualized volatility, e.g. 0.3
+	Interval   time.Duration      // time between ticks
+	Prices     map[string]float64 // starting prices; others start at the last stored quote or 100
+	Seed       int64              // seeds both live and historical paths
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	states map[string]*simState // subscribed symbols
+}
+
+// simState is the current simulated market of one symbol
+type simState struct {
+	exchange string
+	price    float64
+	volume   int64     // cumulative day volume
+	day      time.Time // trading day the volume belongs to
+}
+
+// NewSimulator creates a simulator; a zero seed uses the current time
+func NewSimulator(drift, volatility float64, interval time.Duration, seed int64) *Simulator {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Simulator{
+		Drift:      drift,
+		Volatility: volatility,
+		Interval:   interval,
+		Prices:     make(map[string]float64),
+		Seed:       seed,
+		rng:        rand.New(rand.NewSource(seed)),
+		states:     make(map[string]*simState),
+	}
+}
+
+// NewSimulatorFromEnv reads MARKETDATA_SIM_DRIFT (default 0), MARKETDATA_SIM_VOLATILITY
+// (default 0.3), MARKETDATA_SIM_INTERVAL (default 1s), MARKETDATA_SIM_SEED and
+// MARKETDATA_SIM_PRICES ("symbol:price" pairs, e.g. "RELIANCE:2900,INFY:1500")
+func NewSimulatorFromEnv() (*Simulator, error) {
+	drift, err := envFloat("MARKETDATA_SIM_DRIFT", 0)
+	if err != nil {
+		return nil, err
+	}
+	volatility, err := envFloat("MARKETDATA_SIM_VOLATILITY", 0.3)
+	if err != nil {
+		return nil, err
+	}
+	if volatility < 0 {
+		return nil, fmt.Errorf("MARKETDATA_SIM_VOLATILITY must not be negative")
+	}
+	interval := time.Second
+	if value := os.Getenv("MARKETDATA_SIM_INTERVAL"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid MARKETDATA_SIM_INTERVAL %q", value)
+		}
+	}
+	var seed int64
+	if value := os.Getenv("MARKETDATA_SIM_SEED"); value != "" {
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid MARKETDATA_SIM_SEED %q", value)
+		}
+	}
+
+	sim := NewSimulator(drift, volatility, interval, seed)
+	for _, item := range strings.Split(os.Getenv("MARKETDATA_SIM_PRICES"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		symbol, priceStr, ok := strings.Cut(item, ":")
+		price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+		if !ok || err != nil || price <= 0 {
+			return nil, fmt.Errorf("invalid MARKETDATA_SIM_PRICES entry %q, expected symbol:price", item)
+		}
+		sim.Prices[strings.TrimSpace(symbol)] = price
+		if err := sim.Subscribe(models.Instrument{TradingSymbol: strings.TrimSpace(symbol)}); err != nil {
+			return nil, err
+		}
+	}
+	return sim, nil
+}
+
+func envFloat(name string, fallback float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return f, nil
+}
+
+func (s *Simulator) Name() string { return ProviderSimulator }
+
+// Subscribe starts simulating instruments from their starting price
+func (s *Simulator) Subscribe(instruments ...models.Instrument) error {
+	// Starting prices may come from the database, so look them up without holding the lock
+	var added []models.Instrument
+	s.mu.Lock()
+	for _, inst := range instruments {
+		if _, ok := s.states[inst.TradingSymbol]; !ok {
+			added = append(added, inst)
+		}
+	}
+	s.mu.Unlock()
+
+	prices := make([]float64, len(added))
+	for i, inst := range added {
+		prices[i] = s.startPrice(inst.TradingSymbol)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, inst := range added {
+		if _, ok := s.states[inst.TradingSymbol]; ok {
+			continue
+		}
+		s.states[inst.TradingSymbol] = &simState{exchange: inst.Exchange, price: prices[i]}
+	}
+	return nil
+}
+
+func (s *Simulator) Unsubscribe(instruments ...models.Instrument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inst := range instruments {
+		delete(s.states, inst.TradingSymbol)
+	}
+	return nil
+}
+
+// startPrice is the configured price, else the last stored quote, else defaultSimPrice
+func (s *Simulator) startPrice(symbol string) float64 {
+	if price, ok := s.Prices[symbol]; ok {
+		return price
+	}
+	if q, ok, err := quotes.Latest(symbol); err == nil && ok {
+		return q.LTP
+	}
+	return defaultSimPrice
+}
+
+// Run moves every subscribed price one step per Interval
+func (s *Simulator) Run(ctx context.Context, onTicks func([]models.Quote)) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			s.mu.Lock()
+			batch := make([]models.Quote, 0, len(s.states))
+			for symbol, state := range s.states {
+				if day := market.LastTradingDay(market.Calendar(state.exchange), now); !day.Equal(state.day) {
+					state.day, state.volume = day, 0
+				}
+				state.price = s.step(s.rng, state.price, s.Interval)
+				state.volume += 1 + s.rng.Int63n(1000)
+				price := roundTick(state.price)
+				batch = append(batch, models.Quote{
+					Symbol:    symbol,
+					Exchange:  state.exchange,
+					LTP:       price,
+					Bid:       roundTick(price - simTickSize),
+					Ask:       roundTick(price + simTickSize),
+					Volume:    state.volume,
+					Timestamp: now,
+				})
+			}
+			s.mu.Unlock()
+			if len(batch) > 0 {
+				onTicks(batch)
+			}
+		}
+	}
+}
+
+// step advances a price by one GBM step of length dt
+func (s *Simulator) step(rng *rand.Rand, price float64, dt time.Duration) float64 {
+	years := float64(dt) / float64(tradingYear)
+	return price * math.Exp((s.Drift-s.Volatility*s.Volatility/2)*years+s.Volatility*math.Sqrt(years)*rng.NormFloat64())
+}
+
+// Historical generates candles on the instrument's trading sessions. The path is a function of the
+// seed, symbol and range, so repeated requests return the same candles.
+func (s *Simulator) Historical(inst models.Instrument, interval string, from, to time.Time) ([]models.Candle, error) {
+	length, ok := candles.Intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	if length >= 24*time.Hour {
+		length = 375 * time.Minute // one session
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(inst.TradingSymbol))
+	rng := rand.New(rand.NewSource(s.Seed ^ int64(h.Sum64()) ^ from.Unix()))
+
+	price := s.startPrice(inst.TradingSymbol)
+
+	result := []models.Candle{}
+	for _, start := range candles.SessionBuckets(interval, market.Calendar(inst.Exchange), from, to) {
+		if !start.Before(to) {
+			continue
+		}
+		c := models.Candle{Symbol: inst.TradingSymbol, Interval: interval, Start: start, Open: roundTick(price), High: price, Low: price}
+		for i := 0; i < simSubsteps; i++ {
+			price = s.step(rng, price, length/simSubsteps)
+			c.High = math.Max(c.High, price)
+			c.Low = math.Min(c.Low, price)
+		}
+		c.High, c.Low, c.Close = roundTick(c.High), roundTick(c.Low), roundTick(price)
+		c.Volume = int64(simSubsteps) * (1 + rng.Int63n(1000))
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func roundTick(price float64) float64 {
+	return math.Max(simTickSize, math.Round(price*ticksPerRupee)/ticksPerRupee)
+}
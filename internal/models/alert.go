@@ -10,16 +10,148 @@ type Alert struct {
 	OptionType       string    `json:"option_type,omitempty"` // "CALL", "PUT", or empty for stocks
 	StrikePrice      float64   `json:"strike_price,omitempty"`
 	Expiry           string    `json:"expiry,omitempty"`
-	AlertType        string    `json:"alert_type"` // "PRICE_ABOVE", "PRICE_BELOW", "PERCENTAGE_CHANGE"
+	AlertType        string    `json:"alert_type"` // "PRICE_ABOVE", "PRICE_BELOW", "PERCENTAGE_CHANGE", "EXPRESSION", "TRAILING_STOP", an indicator or a portfolio type
 	TargetValue      float64   `json:"target_value"`
-	Condition        string    `json:"condition"` // ">", "<", ">=", "<=", "=="
+	Condition        string    `json:"condition"` // ">", "<", ">=", "<="
 	Message          string    `json:"message"`
 	IsActive         bool      `json:"is_active"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 	UserID           int       `json:"user_id"`
+
+	// Filled in from the instruments master when it is loaded
+	Exchange        string `json:"exchange,omitempty"`
+	InstrumentToken int64  `json:"instrument_token,omitempty"`
+	LotSize         int    `json:"lot_size,omitempty"`
+
+	// Kite sync state
+	KiteUUID   string     `json:"kite_uuid,omitempty"`
+	SyncStatus string     `json:"sync_status"` // "pending", "synced", "failed" or "local"
+	SyncError  string     `json:"sync_error,omitempty"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+
+	// Last trigger by the alert engine
+	TriggeredAt    *time.Time `json:"triggered_at,omitempty"`
+	TriggeredPrice float64    `json:"triggered_price,omitempty"`
+
+	// Reference price of PERCENTAGE_CHANGE alerts
+	BaselineType  string  `json:"baseline_type,omitempty"`  // "PREVIOUS_CLOSE", "CREATION_PRICE", "DAY_OPEN" or "FIXED"
+	BaselineValue float64 `json:"baseline_value,omitempty"` // 0 until the reference price is known
+	BaselineDate  string  `json:"baseline_date,omitempty"`  // trading day (YYYY-MM-DD) of a previous close or day open baseline
+	Direction     string  `json:"direction,omitempty"`      // "SIGNED" (default) or "EITHER"
+
+	// Condition of EXPRESSION alerts, e.g. "NIFTY LTP < 21500 AND BANKNIFTY LTP < 46000"
+	Expression string `json:"expression,omitempty"`
+
+	// Indicator alerts, evaluated when a candle of Interval closes
+	Interval   string  `json:"interval,omitempty"`    // candle interval: "1m", "5m", "15m", "1h" or "1d"
+	Period     int     `json:"period,omitempty"`      // indicator period; the fast average of crossovers
+	SlowPeriod int     `json:"slow_period,omitempty"` // slow average of SMA_CROSS and EMA_CROSS
+	Multiplier float64 `json:"multiplier,omitempty"`  // band width of BOLLINGER and SUPERTREND
+
+	// Trailing stops: once the price reaches ActivationPrice the stop trails the running high (LONG)
+	// or low (SHORT) by TrailValue, an amount or a percentage
+	TrailSide       string  `json:"trail_side,omitempty"` // "LONG" or "SHORT"
+	TrailType       string  `json:"trail_type,omitempty"` // "AMOUNT" or "PERCENT"
+	TrailValue      float64 `json:"trail_value,omitempty"`
+	ActivationPrice float64 `json:"activation_price,omitempty"` // 0 trails from the first quote
+	TrailExtreme    float64 `json:"trail_extreme,omitempty"`    // running high or low since activation, 0 until active
+	TrailLevel      float64 `json:"trail_level,omitempty"`      // current stop, 0 until active
+
+	// Intraday peak of the day's P&L, followed by DRAWDOWN alerts
+	PeakPnL  float64 `json:"peak_pnl,omitempty"`
+	PeakDate string  `json:"peak_date,omitempty"` // trading day (YYYY-MM-DD) of the peak
+
+	// Notification channels the alert notifies; empty for all of the user's channels
+	Channels []int `json:"channels,omitempty"`
+
+	// Trigger policy and lifetime
+	TriggerPolicy   string     `json:"trigger_policy"`             // "ONCE" (default), "RECURRING" or "DAILY"
+	CooldownSeconds int        `json:"cooldown_seconds,omitempty"` // RECURRING: minimum time between triggers
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`      // the alert expires after this time
+	TriggerCount    int        `json:"trigger_count"`
+
+	// Computed when the alert is listed
+	State    string     `json:"state,omitempty"`     // "armed", "triggered", "cooling_down", "expired" or "disabled"
+	RearmsAt *time.Time `json:"rearms_at,omitempty"` // when a cooling down or triggered recurring alert can fire again
 }
 
+// Alert sync statuses
+const (
+	SyncPending = "pending" // not yet pushed to Kite, e.g. no Kite session
+	SyncSynced  = "synced"
+	SyncFailed  = "failed"
+	SyncLocal   = "local" // a type Kite alerts cannot represent, evaluated only here
+)
+
+// Alert types
+const (
+	AlertPriceAbove       = "PRICE_ABOVE"
+	AlertPriceBelow       = "PRICE_BELOW"
+	AlertPercentageChange = "PERCENTAGE_CHANGE"
+	AlertExpression       = "EXPRESSION" // fires when Expression holds; see package alertexpr
+	AlertTrailingStop     = "TRAILING_STOP"
+)
+
+// Trailing stop sides and trail types
+const (
+	TrailLong    = "LONG"  // the stop trails below the running high and fires on a fall to it
+	TrailShort   = "SHORT" // the stop trails above the running low and fires on a rise to it
+	TrailAmount  = "AMOUNT"
+	TrailPercent = "PERCENT"
+)
+
+// Indicator alert types, evaluated on candle close. Condition ">" fires on a cross, break or flip
+// upwards and "<" downwards; RSI compares the indicator with TargetValue.
+const (
+	AlertSMACross   = "SMA_CROSS"  // the Period SMA crosses the SlowPeriod SMA
+	AlertEMACross   = "EMA_CROSS"  // the Period EMA crosses the SlowPeriod EMA
+	AlertRSI        = "RSI"        // RSI is above or below TargetValue
+	AlertBollinger  = "BOLLINGER"  // the close breaks out of the bands
+	AlertSupertrend = "SUPERTREND" // the trend flips
+	AlertVWAPCross  = "VWAP_CROSS" // the close crosses the day's VWAP
+)
+
+// Portfolio alert types, evaluated against the user's positions marked at the latest quotes. The
+// day's P&L is realized plus unrealized P&L since the previous trading day's close.
+const (
+	AlertDayPnL       = "DAY_PNL"       // the day's P&L compares with TargetValue using Condition
+	AlertPositionLoss = "POSITION_LOSS" // a position's unrealized loss reaches TargetValue percent of its cost; Symbol is optional
+	AlertDrawdown     = "DRAWDOWN"      // the day's P&L falls TargetValue rupees from its intraday peak
+)
+
+// Baselines of PERCENTAGE_CHANGE alerts. Previous close and day open follow the current trading day.
+const (
+	BaselinePreviousClose = "PREVIOUS_CLOSE"
+	BaselineCreationPrice = "CREATION_PRICE" // default
+	BaselineDayOpen       = "DAY_OPEN"
+	BaselineFixed         = "FIXED" // baseline_value given by the user
+)
+
+// Directions of PERCENTAGE_CHANGE alerts: SIGNED compares the signed move with the target
+// (so -3 with "<=" is a 3% fall), EITHER compares the size of the move in either direction.
+const (
+	DirectionSigned = "SIGNED"
+	DirectionEither = "EITHER"
+)
+
+// Trigger policies: ONCE deactivates the alert when it fires, RECURRING fires again after its
+// cooldown, DAILY fires at most once per trading day
+const (
+	PolicyOnce      = "ONCE"
+	PolicyRecurring = "RECURRING"
+	PolicyDaily     = "DAILY"
+)
+
+// Alert states
+const (
+	StateArmed       = "armed"
+	StateTriggered   = "triggered"
+	StateCoolingDown = "cooling_down"
+	StateExpired     = "expired"
+	StateDisabled    = "disabled" // switched off by the user
+)
+
 // AlertRequest represents the request structure for creating/updating alerts
 type AlertRequest struct {
 	Symbol           string  `json:"symbol"`
@@ -31,13 +163,41 @@ type AlertRequest struct {
 	TargetValue      float64 `json:"target_value"`
 	Condition        string  `json:"condition"`
 	Message          string  `json:"message"`
+	BaselineType     string  `json:"baseline_type,omitempty"`
+	BaselineValue    float64 `json:"baseline_value,omitempty"` // required for FIXED baselines
+	Direction        string  `json:"direction,omitempty"`
+
+	Expression string `json:"expression,omitempty"` // required for EXPRESSION alerts
+
+	Interval   string  `json:"interval,omitempty"` // required for indicator alerts
+	Period     int     `json:"period,omitempty"`
+	SlowPeriod int     `json:"slow_period,omitempty"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+
+	TrailSide       string  `json:"trail_side,omitempty"` // required for TRAILING_STOP alerts
+	TrailType       string  `json:"trail_type,omitempty"` // default PERCENT
+	TrailValue      float64 `json:"trail_value,omitempty"`
+	ActivationPrice float64 `json:"activation_price,omitempty"`
+
+	Channels []int `json:"channels,omitempty"` // IDs of the user's notification channels; empty for all
+
+	TriggerPolicy   string     `json:"trigger_policy,omitempty"`
+	CooldownSeconds int        `json:"cooldown_seconds,omitempty"` // required for RECURRING alerts
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
 }
 
 // AlertResponse represents the response structure for alert operations
 type AlertResponse struct {
-	Success bool   `json:"success"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Alert   *Alert       `json:"alert,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"` // every invalid field of a rejected request
+}
+
+// FieldError is one invalid field of a request; Field is empty for errors about the request as a whole
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
 	Message string `json:"message"`
-	Alert   *Alert `json:"alert,omitempty"`
 }
 
 // AlertsResponse represents the response structure for getting all alerts
@@ -45,3 +205,49 @@ type AlertsResponse struct {
 	Success bool    `json:"success"`
 	Alerts  []Alert `json:"alerts"`
 }
+
+// AlertTrigger is pushed to the user's event streams when an alert fires
+type AlertTrigger struct {
+	AlertID     int       `json:"alert_id"`
+	Symbol      string    `json:"symbol"`
+	Price       float64   `json:"price"`
+	Message     string    `json:"message"`
+	TriggeredAt time.Time `json:"triggered_at"`
+
+	Indicators map[string]float64 `json:"indicators,omitempty"` // values of an indicator, trailing stop or portfolio alert at the trigger
+}
+
+// AlertEvent is one recorded trigger of an alert
+type AlertEvent struct {
+	ID          int64           `json:"id"`
+	AlertID     int             `json:"alert_id"`
+	Symbol      string          `json:"symbol"`
+	Price       float64         `json:"price"`
+	TriggeredAt time.Time       `json:"triggered_at"`
+	Alert       Alert           `json:"alert"` // definition at the time it fired
+	Deliveries  []AlertDelivery `json:"deliveries"`
+
+	Indicators map[string]float64 `json:"indicators,omitempty"` // values of an indicator, trailing stop or portfolio alert at the trigger
+}
+
+// AlertDelivery is the outcome of notifying a trigger over one channel
+type AlertDelivery struct {
+	Channel   string    `json:"channel"`              // "events", "email", "webhook", "telegram" or "slack"
+	ChannelID int       `json:"channel_id,omitempty"` // the user's notification channel, 0 for built-in ones
+	Status    string    `json:"status"`               // "delivered", "failed" or "skipped"
+	Detail    string    `json:"detail,omitempty"`     // error or reason for skipping
+	At        time.Time `json:"at"`
+}
+
+// Alert delivery statuses
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliverySkipped   = "skipped" // channel not configured or nobody listening
+)
+
+// AlertEventsResponse represents the response structure for alert trigger history
+type AlertEventsResponse struct {
+	Success bool         `json:"success"`
+	Events  []AlertEvent `json:"events"`
+}
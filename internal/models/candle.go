@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Candle represents OHLCV data for one instrument over one interval
+type Candle struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"` // "1m", "5m", "15m", "1h", "1d"
+	Start    time.Time `json:"start"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   int64     `json:"volume"`
+	OI       int64     `json:"oi,omitempty"`
+}
+
+// CandlesResponse represents the response structure for candle queries
+type CandlesResponse struct {
+	Success  bool     `json:"success"`
+	Symbol   string   `json:"symbol"`
+	Interval string   `json:"interval"`
+	Candles  []Candle `json:"candles"`
+}
+
+// CandleGap is a run of consecutive missing candles; From and To are the first and last missing bucket starts
+type CandleGap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Missing int       `json:"missing"`
+}
+
+// CandleImportResult reports a historical candle import
+type CandleImportResult struct {
+	Success        bool        `json:"success"`
+	Symbol         string      `json:"symbol"`
+	Interval       string      `json:"interval"`
+	Exchange       string      `json:"exchange"` // trading calendar used for gap detection
+	Rows           int         `json:"rows"`
+	Inserted       int         `json:"inserted"`
+	Updated        int         `json:"updated"`    // replaced candles already stored
+	Duplicates     int         `json:"duplicates"` // repeated timestamps in the file; the last one wins
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	MissingCandles int         `json:"missing_candles"`
+	Gaps           []CandleGap `json:"gaps"` // at most the first 100
+}
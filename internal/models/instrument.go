@@ -0,0 +1,22 @@
+package models
+
+// Instrument is a row of the Kite instruments master
+type Instrument struct {
+	InstrumentToken int64   `json:"instrument_token"`
+	ExchangeToken   int64   `json:"exchange_token"`
+	TradingSymbol   string  `json:"tradingsymbol"`
+	Name            string  `json:"name,omitempty"`
+	Expiry          string  `json:"expiry,omitempty"` // YYYY-MM-DD, empty for equities and indices
+	Strike          float64 `json:"strike,omitempty"`
+	TickSize        float64 `json:"tick_size"`
+	LotSize         int     `json:"lot_size"`
+	InstrumentType  string  `json:"instrument_type"` // "EQ", "FUT", "CE", "PE"
+	Segment         string  `json:"segment"`
+	Exchange        string  `json:"exchange"`
+}
+
+// InstrumentsResponse represents the response structure for instrument searches
+type InstrumentsResponse struct {
+	Success     bool         `json:"success"`
+	Instruments []Instrument `json:"instruments"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Ledger entry types. Deposits and withdrawals are external cash flows;
+// dividends and charges are part of the account's return.
+const (
+	LedgerDeposit    = "DEPOSIT"
+	LedgerWithdrawal = "WITHDRAWAL"
+	LedgerDividend   = "DIVIDEND"
+	LedgerCharge     = "CHARGE"
+)
+
+// LedgerEntry represents one cash movement in a user's account
+type LedgerEntry struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	EntryType string    `json:"entry_type"` // "DEPOSIT", "WITHDRAWAL", "DIVIDEND", "CHARGE"
+	Amount    float64   `json:"amount"`     // always positive; the type gives the direction
+	Date      string    `json:"date"`       // YYYY-MM-DD
+	Note      string    `json:"note,omitempty"`
+	Balance   float64   `json:"balance"` // running cash balance after this entry
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerEntryRequest represents the request structure for adding a ledger entry
+type LedgerEntryRequest struct {
+	EntryType string  `json:"entry_type"`
+	Amount    float64 `json:"amount"`
+	Date      string  `json:"date"`
+	Note      string  `json:"note,omitempty"`
+}
+
+// LedgerResponse represents the ledger entries of a user with the closing balance
+type LedgerResponse struct {
+	Success bool          `json:"success"`
+	Entries []LedgerEntry `json:"entries"`
+	Balance float64       `json:"balance"`
+}
+
+// ReturnsResponse represents capital-weighted and time-weighted returns over a period
+type ReturnsResponse struct {
+	Success     bool     `json:"success"`
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	StartValue  float64  `json:"start_value"`
+	EndValue    float64  `json:"end_value"`
+	NetFlows    float64  `json:"net_flows"` // deposits minus withdrawals within the period
+	Dividends   float64  `json:"dividends"`
+	Charges     float64  `json:"charges"`
+	PnL         float64  `json:"pnl"` // change in realized plus unrealized P&L over the period
+	CashBalance float64  `json:"cash_balance"`
+	XIRR        *float64 `json:"xirr"` // annualized money-weighted return; null when it cannot be solved
+	TWR         float64  `json:"twr"`  // time-weighted return for the period
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// MarketHoliday is a day an exchange calendar does not trade
+type MarketHoliday struct {
+	Date        string `json:"date"` // YYYY-MM-DD
+	Description string `json:"description,omitempty"`
+}
+
+// MarketHolidaysRequest replaces a calendar's holidays for one year
+type MarketHolidaysRequest struct {
+	Exchange string          `json:"exchange"`
+	Year     int             `json:"year"`
+	Holidays []MarketHoliday `json:"holidays"`
+}
+
+// MarketHolidaysResponse represents the response structure for holiday listings
+type MarketHolidaysResponse struct {
+	Success  bool            `json:"success"`
+	Exchange string          `json:"exchange"`
+	Year     int             `json:"year"`
+	Holidays []MarketHoliday `json:"holidays"`
+}
+
+// MarketStatus describes an exchange calendar at a point in time
+type MarketStatus struct {
+	Exchange    string     `json:"exchange"`
+	Time        time.Time  `json:"time"`
+	TradingDay  bool       `json:"trading_day"`
+	Holiday     string     `json:"holiday,omitempty"`
+	Open        bool       `json:"open"`              // continuous trading in progress
+	Session     string     `json:"session,omitempty"` // "pre_open", "normal", "closing" or "evening"
+	SessionEnds *time.Time `json:"session_ends,omitempty"`
+	NextOpen    time.Time  `json:"next_open"`
+}
+
+// MarketStatusResponse represents the response structure for GET /market/status
+type MarketStatusResponse struct {
+	Success bool           `json:"success"`
+	Markets []MarketStatus `json:"markets"`
+}
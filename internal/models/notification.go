@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Notification channel types
+const (
+	ChannelEmail    = "EMAIL"
+	ChannelWebhook  = "WEBHOOK"
+	ChannelTelegram = "TELEGRAM"
+	ChannelSlack    = "SLACK"
+)
+
+// NotificationChannel is a destination a user has set up for alert notifications. The webhook
+// secret and bot token are never returned.
+type NotificationChannel struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Type      string    `json:"type"` // "EMAIL", "WEBHOOK", "TELEGRAM" or "SLACK"
+	Name      string    `json:"name"`
+	Address   string    `json:"address,omitempty"` // EMAIL: recipient, defaults to the account email
+	URL       string    `json:"url,omitempty"`     // WEBHOOK: endpoint; SLACK: incoming webhook URL
+	Secret    string    `json:"-"`                 // WEBHOOK: HMAC-SHA256 signing key
+	BotToken  string    `json:"-"`                 // TELEGRAM: bot API token
+	ChatID    string    `json:"chat_id,omitempty"` // TELEGRAM: chat to message
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationChannelRequest represents the request structure for creating/updating a channel.
+// On update an empty secret or bot token keeps the stored one.
+type NotificationChannelRequest struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Address  string `json:"address,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+	BotToken string `json:"bot_token,omitempty"`
+	ChatID   string `json:"chat_id,omitempty"`
+	IsActive *bool  `json:"is_active,omitempty"` // default true
+}
+
+// NotificationChannelResponse represents the response structure for channel operations
+type NotificationChannelResponse struct {
+	Success  bool                 `json:"success"`
+	Message  string               `json:"message"`
+	Channel  *NotificationChannel `json:"channel,omitempty"`
+	Delivery *AlertDelivery       `json:"delivery,omitempty"` // result of a test notification
+	Errors   []FieldError         `json:"errors,omitempty"`
+}
+
+// NotificationChannelsResponse represents the response structure for listing channels
+type NotificationChannelsResponse struct {
+	Success  bool                  `json:"success"`
+	Channels []NotificationChannel `json:"channels"`
+}
+
+// AlertWebhookPayload is the JSON body a webhook channel receives
+type AlertWebhookPayload struct {
+	Type    string       `json:"type"`               // "alert.triggered", or "test" for a test notification
+	EventID int64        `json:"event_id,omitempty"` // ID of the alert event
+	Trigger AlertTrigger `json:"trigger"`
+	Alert   *Alert       `json:"alert,omitempty"` // definition at the time it fired
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Outbox item statuses
+const (
+	OutboxPending    = "pending"
+	OutboxProcessing = "processing"
+	OutboxDead       = "dead" // failed permanently or ran out of attempts
+)
+
+// OutboxItem is a queued outbound broker call
+type OutboxItem struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Operation     string    `json:"operation"`
+	AlertID       int       `json:"alert_id,omitempty"`
+	Payload       string    `json:"payload,omitempty"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// OutboxResponse represents the response structure for outbox listings
+type OutboxResponse struct {
+	Success bool         `json:"success"`
+	Items   []OutboxItem `json:"items"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// Position represents the net holding in one instrument with its marks and P&L
+type Position struct {
+	Symbol           string  `json:"symbol"`
+	UnderlyingSymbol string  `json:"underlying_symbol,omitempty"`
+	OptionType       string  `json:"option_type,omitempty"`
+	StrikePrice      float64 `json:"strike_price,omitempty"`
+	Expiry           string  `json:"expiry,omitempty"`
+	NetQuantity      float64 `json:"net_quantity"` // positive for long, negative for short
+	AvgCost          float64 `json:"avg_cost"`
+	MarkPrice        float64 `json:"mark_price"`
+	RealizedPnL      float64 `json:"realized_pnl"`
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`
+}
+
+// PortfolioResponse represents the portfolio of a user as of a given date
+type PortfolioResponse struct {
+	Success       bool       `json:"success"`
+	AsOf          string     `json:"as_of"`
+	Source        string     `json:"source"` // "snapshot" or "computed"
+	Positions     []Position `json:"positions"`
+	RealizedPnL   float64    `json:"realized_pnl"`
+	UnrealizedPnL float64    `json:"unrealized_pnl"`
+	TotalPnL      float64    `json:"total_pnl"`
+}
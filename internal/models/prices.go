@@ -0,0 +1,49 @@
+package models
+
+// DailyPrice is a closing price for one trading day
+type DailyPrice struct {
+	Date  string  `json:"date"` // YYYY-MM-DD
+	Close float64 `json:"close"`
+}
+
+// PriceSeries summarizes the daily prices loaded for a symbol
+type PriceSeries struct {
+	Symbol string `json:"symbol"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Count  int    `json:"count"`
+}
+
+// BenchmarkRequest represents a benchmark price series upload
+type BenchmarkRequest struct {
+	Symbol string       `json:"symbol"` // e.g. "NIFTY 50", "NIFTY BANK"
+	Prices []DailyPrice `json:"prices"`
+}
+
+// BenchmarkPoint is one day of the equity curve next to the benchmark
+type BenchmarkPoint struct {
+	Date            string  `json:"date"`
+	Equity          float64 `json:"equity"`
+	PortfolioReturn float64 `json:"portfolio_return"` // cumulative, as a fraction
+	BenchmarkClose  float64 `json:"benchmark_close"`
+	BenchmarkReturn float64 `json:"benchmark_return"` // cumulative, as a fraction
+}
+
+// BenchmarkStats are computed on daily returns over the comparison period
+type BenchmarkStats struct {
+	Alpha           float64 `json:"alpha"` // daily
+	AnnualizedAlpha float64 `json:"annualized_alpha"`
+	Beta            float64 `json:"beta"`
+	Correlation     float64 `json:"correlation"`
+	Days            int     `json:"days"`
+	SkippedDays     int     `json:"skipped_days,omitempty"` // days left out because the day before had no positive equity or close
+}
+
+// BenchmarkResponse represents the portfolio vs benchmark comparison
+type BenchmarkResponse struct {
+	Success   bool             `json:"success"`
+	Benchmark string           `json:"benchmark"`
+	Capital   float64          `json:"capital"`
+	Points    []BenchmarkPoint `json:"points"`
+	Stats     BenchmarkStats   `json:"stats"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Quote represents a market price update for one instrument
+type Quote struct {
+	Symbol    string    `json:"symbol"`
+	Exchange  string    `json:"exchange,omitempty"`
+	LTP       float64   `json:"ltp"`
+	Bid       float64   `json:"bid,omitempty"`
+	Ask       float64   `json:"ask,omitempty"`
+	Volume    int64     `json:"volume,omitempty"`
+	OI        int64     `json:"oi,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// QuotesResponse represents the response structure for quote lookups
+type QuotesResponse struct {
+	Success bool             `json:"success"`
+	Quotes  map[string]Quote `json:"quotes"`
+	Missing []string         `json:"missing,omitempty"`
+}
@@ -14,4 +14,8 @@ type Stock struct {
 	Price            float64   `json:"price"`
 	Side             string    `json:"side"`
 	Timestamp        time.Time `json:"timestamp"`
+	UserID           int       `json:"user_id,omitempty"`
+	Exchange         string    `json:"exchange,omitempty"`
+	InstrumentToken  int64     `json:"instrument_token,omitempty"`
+	LotSize          int       `json:"lot_size,omitempty"`
 }
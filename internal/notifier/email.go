@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/email"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// emailService is created once, like the SMTP settings it reads
+var emailService = email.NewEmailService()
+
+// emailSender mails the channel's address, or the account email when it has none
+type emailSender struct{}
+
+func (emailSender) Send(ctx context.Context, ch models.NotificationChannel, m Message) error {
+	if !emailService.IsEmailConfigured() {
+		return ErrNotConfigured
+	}
+	to := ch.Address
+	if to == "" {
+		var err error
+		if to, err = db.GetUserEmail(ch.UserID); err != nil {
+			return err
+		}
+	}
+	// The SMTP dialer has no context; it is bounded by the server's own timeouts
+	return emailService.SendEmail(to, m.Subject, m.HTML)
+}
@@ -0,0 +1,112 @@
+// Package notifier delivers alert notifications over the channels a user has set up: email,
+// signed HTTP webhooks, Telegram bots and Slack incoming webhooks.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// Timeout bounds one delivery over an HTTP channel
+const Timeout = 10 * time.Second
+
+// ErrNotConfigured means the channel cannot deliver at all, e.g. email without SMTP settings.
+// Deliveries failing with it are recorded as skipped rather than failed.
+var ErrNotConfigured = errors.New("channel is not configured")
+
+// Message is one notification, rendered for each kind of channel
+type Message struct {
+	Subject string                     // email subject
+	Text    string                     // plain text for chat channels
+	HTML    string                     // email body
+	Payload models.AlertWebhookPayload // webhook body
+}
+
+// Sender delivers messages over one type of channel
+type Sender interface {
+	Send(ctx context.Context, ch models.NotificationChannel, m Message) error
+}
+
+// senders by channel type
+var senders = map[string]Sender{
+	models.ChannelEmail:    emailSender{},
+	models.ChannelWebhook:  webhookSender{},
+	models.ChannelTelegram: telegramSender{},
+	models.ChannelSlack:    slackSender{},
+}
+
+// httpClient is shared by the Telegram and Slack channels, whose hosts the operator configures
+var httpClient = &http.Client{Timeout: Timeout, CheckRedirect: noRedirects}
+
+// IsChannelType reports whether t is a supported channel type
+func IsChannelType(t string) bool {
+	_, ok := senders[t]
+	return ok
+}
+
+// Send delivers a message over a channel
+func Send(ctx context.Context, ch models.NotificationChannel, m Message) error {
+	sender, ok := senders[ch.Type]
+	if !ok {
+		return fmt.Errorf("unsupported channel type %q", ch.Type)
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	return sender.Send(ctx, ch, m)
+}
+
+// ChannelsFor returns the active channels an alert notifies: the selected ones, or all of the
+// user's when none are selected. A user without channels is emailed at their account address.
+func ChannelsFor(userID int, selected []int) ([]models.NotificationChannel, error) {
+	all, err := db.GetNotificationChannels(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return []models.NotificationChannel{{UserID: userID, Type: models.ChannelEmail, Name: "email", IsActive: true}}, nil
+	}
+
+	wanted := make(map[int]bool, len(selected))
+	for _, id := range selected {
+		wanted[id] = true
+	}
+	var channels []models.NotificationChannel
+	for _, ch := range all {
+		if ch.IsActive && (len(selected) == 0 || wanted[ch.ID]) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels, nil
+}
+
+// DefaultTelegramBaseURL is the Telegram Bot API root
+const DefaultTelegramBaseURL = "https://api.telegram.org"
+
+// DefaultSlackBaseURL is where Slack incoming webhooks live
+const DefaultSlackBaseURL = "https://hooks.slack.com"
+
+// TelegramBaseURL is the Bot API root, DefaultTelegramBaseURL unless TELEGRAM_BASE_URL is set
+func TelegramBaseURL() string {
+	return baseURL("TELEGRAM_BASE_URL", DefaultTelegramBaseURL)
+}
+
+// SlackBaseURL is the root Slack webhook URLs must be under, DefaultSlackBaseURL unless
+// SLACK_BASE_URL is set
+func SlackBaseURL() string {
+	return baseURL("SLACK_BASE_URL", DefaultSlackBaseURL)
+}
+
+func baseURL(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	return defaultValue
+}
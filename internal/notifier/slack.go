@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// slackSender posts the text to the channel's incoming webhook URL
+type slackSender struct{}
+
+func (slackSender) Send(ctx context.Context, ch models.NotificationChannel, m Message) error {
+	body, err := json.Marshal(map[string]string{"text": m.Text})
+	if err != nil {
+		return err
+	}
+	// Webhook URLs are secrets too
+	if _, err := postJSON(ctx, httpClient, ch.URL, body, nil); err != nil {
+		return fmt.Errorf("slack: %s", redact(err.Error(), ch.URL))
+	}
+	return nil
+}
+
+// redact hides a secret in an error message
+func redact(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, secret, "[redacted]")
+}
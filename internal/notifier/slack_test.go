@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+func TestSlackSend(t *testing.T) {
+	srv, seen := standIn(t, http.StatusOK, "ok")
+	ch := models.NotificationChannel{Type: models.ChannelSlack, URL: srv.URL + "/services/T000/B000/XXXX"}
+	if err := Send(context.Background(), ch, Message{Text: "INFY above 1500"}); err != nil {
+		t.Fatal(err)
+	}
+	if seen.path != "/services/T000/B000/XXXX" {
+		t.Errorf("path = %s", seen.path)
+	}
+	if string(seen.body) != `{"text":"INFY above 1500"}` {
+		t.Errorf("body = %s", seen.body)
+	}
+}
+
+func TestSlackRedactsURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	ch := models.NotificationChannel{Type: models.ChannelSlack, URL: srv.URL + "/services/T000/B000/XXXX"}
+	err := Send(context.Background(), ch, Message{Text: "hi"})
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), ch.URL) || !strings.Contains(err.Error(), "[redacted]") {
+		t.Errorf("webhook URL not redacted: %v", err)
+	}
+}
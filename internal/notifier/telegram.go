@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// telegramSender messages the channel's chat through the Bot API sendMessage method
+type telegramSender struct{}
+
+func (telegramSender) Send(ctx context.Context, ch models.NotificationChannel, m Message) error {
+	body, err := json.Marshal(map[string]string{"chat_id": ch.ChatID, "text": m.Text})
+	if err != nil {
+		return err
+	}
+	// The token is part of the path, so keep it out of errors
+	respBody, err := postJSON(ctx, httpClient, TelegramBaseURL()+"/bot"+ch.BotToken+"/sendMessage", body, nil)
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if jsonErr := json.Unmarshal(respBody, &result); jsonErr == nil && !result.OK && result.Description != "" {
+		return fmt.Errorf("telegram: %s", result.Description)
+	}
+	if err != nil {
+		return fmt.Errorf("telegram: %s", redact(err.Error(), ch.BotToken))
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram: unexpected response")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+func TestTelegramSend(t *testing.T) {
+	srv, seen := standIn(t, http.StatusOK, `{"ok": true, "result": {}}`)
+	t.Setenv("TELEGRAM_BASE_URL", srv.URL+"/")
+	ch := models.NotificationChannel{Type: models.ChannelTelegram, BotToken: "123456:ABC-token", ChatID: "-1001"}
+	if err := Send(context.Background(), ch, Message{Text: "INFY above 1500"}); err != nil {
+		t.Fatal(err)
+	}
+	if seen.path != "/bot123456:ABC-token/sendMessage" {
+		t.Errorf("path = %s, want /bot123456:ABC-token/sendMessage", seen.path)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(seen.body, &body); err != nil || body["chat_id"] != "-1001" || body["text"] != "INFY above 1500" {
+		t.Errorf("unexpected body %s (%v)", seen.body, err)
+	}
+}
+
+func TestTelegramErrorDescription(t *testing.T) {
+	srv, _ := standIn(t, http.StatusBadRequest, `{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`)
+	t.Setenv("TELEGRAM_BASE_URL", srv.URL)
+	ch := models.NotificationChannel{Type: models.ChannelTelegram, BotToken: "123456:ABC-token", ChatID: "-1001"}
+	err := Send(context.Background(), ch, Message{Text: "hi"})
+	if err == nil || err.Error() != "telegram: Bad Request: chat not found" {
+		t.Errorf("err = %v, want the Telegram description", err)
+	}
+}
+
+func TestTelegramRedactsToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	t.Setenv("TELEGRAM_BASE_URL", srv.URL)
+	ch := models.NotificationChannel{Type: models.ChannelTelegram, BotToken: "123456:ABC-token", ChatID: "-1001"}
+	err := Send(context.Background(), ch, Message{Text: "hi"})
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), ch.BotToken) || !strings.Contains(err.Error(), "[redacted]") {
+		t.Errorf("token not redacted: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// Webhook signature headers. The signature is "sha256=" and the hex HMAC-SHA256, keyed with the
+// channel secret, of the timestamp, a dot and the body.
+const (
+	TimestampHeader = "X-Stock-Panel-Timestamp"
+	SignatureHeader = "X-Stock-Panel-Signature"
+)
+
+// Sign returns the signature of a webhook body sent at a Unix timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSender POSTs the JSON payload, signed with the channel secret
+type webhookSender struct{}
+
+func (webhookSender) Send(ctx context.Context, ch models.NotificationChannel, m Message) error {
+	body, err := json.Marshal(m.Payload)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{TimestampHeader: timestamp}
+	if ch.Secret != "" {
+		headers[SignatureHeader] = Sign(ch.Secret, timestamp, body)
+	}
+	_, err = postJSON(ctx, webhookClient, ch.URL, body, headers)
+	return err
+}
+
+// ErrPrivateAddress is returned for webhook hosts that are not on the public internet
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// nonPublicNets are the ranges not covered by the net.IP checks in isPublic
+var nonPublicNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // "this network"
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isPublic reports whether ip is a public unicast address: not loopback, link-local
+// (which includes cloud metadata services), private, unspecified or multicast
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL rejects webhook URLs that are not http(s) or whose host resolves to a non-public address.
+// Deliveries check again when dialing, since DNS answers can change after the channel is saved.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be an http or https URL")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("host %s does not resolve", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return errors.New("must not point to a loopback, link-local or private address")
+		}
+	}
+	return nil
+}
+
+// publicOnly is a dialer Control that refuses connections to non-public addresses.
+// It sees the resolved address, so a hostname cannot be re-pointed after CheckURL passed.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// webhookClient delivers to user-supplied URLs: only public addresses, no proxy and no redirects
+var webhookClient = newWebhookClient()
+
+func newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: Timeout, Control: publicOnly}).DialContext
+	return &http.Client{Timeout: Timeout, Transport: transport, CheckRedirect: noRedirects}
+}
+
+// noRedirects makes the client return a redirect response instead of following it
+func noRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// postJSON POSTs a JSON body and returns the response body; any status but 2xx is an error.
+// The error carries only the status, since the body may come from a service the user cannot see.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "stock-panel")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const testSecret = "0123456789abcdef-secret"
+
+// received is what a stand-in server saw
+type received struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// standIn answers every request with status and response, recording the last request
+func standIn(t *testing.T, status int, response string) (*httptest.Server, *received) {
+	t.Helper()
+	seen := &received{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*seen = received{path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, seen
+}
+
+// allowLoopback lets webhooks reach the stand-in servers, keeping the rest of the client as is
+func allowLoopback(t *testing.T) {
+	t.Helper()
+	saved := webhookClient
+	client := newWebhookClient()
+	client.Transport.(*http.Transport).DialContext = (&net.Dialer{Timeout: Timeout}).DialContext
+	webhookClient = client
+	t.Cleanup(func() { webhookClient = saved })
+}
+
+func TestSign(t *testing.T) {
+	got := Sign(testSecret, "1700000000", []byte(`{"type":"test"}`))
+	want := "sha256=9edf5ce73f2c78d9115ad31b83000cbc6d658ad557b85e88433cbff9b8fb0e65"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	allowLoopback(t)
+	srv, seen := standIn(t, http.StatusOK, "")
+	m := Message{Payload: models.AlertWebhookPayload{Type: "test", Trigger: models.AlertTrigger{Message: "hello"}}}
+	ch := models.NotificationChannel{Type: models.ChannelWebhook, URL: srv.URL + "/hook", Secret: testSecret}
+	if err := Send(context.Background(), ch, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen.path != "/hook" {
+		t.Errorf("path = %s, want /hook", seen.path)
+	}
+	if got := seen.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	timestamp := seen.header.Get(TimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time", TimestampHeader, timestamp)
+	}
+	if got, want := seen.header.Get(SignatureHeader), Sign(testSecret, timestamp, seen.body); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	var payload models.AlertWebhookPayload
+	if err := json.Unmarshal(seen.body, &payload); err != nil || payload.Type != "test" || payload.Trigger.Message != "hello" {
+		t.Errorf("unexpected body %s (%v)", seen.body, err)
+	}
+
+	ch.Secret = ""
+	if err := Send(context.Background(), ch, m); err != nil {
+		t.Fatal(err)
+	}
+	if seen.header.Get(SignatureHeader) != "" {
+		t.Error("a channel without a secret should not sign")
+	}
+}
+
+func TestWebhookRejectsPrivateAddresses(t *testing.T) {
+	srv, seen := standIn(t, http.StatusOK, "")
+	ch := models.NotificationChannel{Type: models.ChannelWebhook, URL: srv.URL, Secret: testSecret}
+	err := Send(context.Background(), ch, Message{})
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("err = %v, want %v", err, ErrPrivateAddress)
+	}
+	if seen.path != "" {
+		t.Error("the request reached a loopback address")
+	}
+}
+
+func TestWebhookDoesNotFollowRedirects(t *testing.T) {
+	allowLoopback(t)
+	target, seen := standIn(t, http.StatusOK, "")
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL+"/internal", http.StatusFound))
+	defer redirect.Close()
+
+	ch := models.NotificationChannel{Type: models.ChannelWebhook, URL: redirect.URL, Secret: testSecret}
+	err := Send(context.Background(), ch, Message{})
+	if err == nil || err.Error() != "status 302" {
+		t.Errorf("err = %v, want status 302", err)
+	}
+	if seen.path != "" {
+		t.Error("the redirect was followed")
+	}
+}
+
+func TestWebhookErrorOmitsResponseBody(t *testing.T) {
+	allowLoopback(t)
+	srv, _ := standIn(t, http.StatusInternalServerError, "internal service details")
+	ch := models.NotificationChannel{Type: models.ChannelWebhook, URL: srv.URL, Secret: testSecret}
+	err := Send(context.Background(), ch, Message{})
+	if err == nil || err.Error() != "status 500" {
+		t.Errorf("err = %v, want status 500", err)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	rejected := []string{
+		"ftp://example.com/",
+		"http://",
+		"http://127.0.0.1/",
+		"http://localhost:8080/",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[fe80::1]/",
+		"http://10.1.2.3/",
+		"http://172.16.0.1/",
+		"https://192.168.1.1/",
+		"http://[fd00::1]/",
+		"http://100.64.0.1/",
+		"http://0.0.0.0/",
+		"http://224.0.0.1/",
+	}
+	for _, raw := range rejected {
+		if err := CheckURL(raw); err == nil {
+			t.Errorf("CheckURL(%q) passed", raw)
+		}
+	}
+	for _, raw := range []string{"https://93.184.216.34/hook", "http://[2606:4700::1111]:8080/"} {
+		if err := CheckURL(raw); err != nil {
+			t.Errorf("CheckURL(%q) = %v", raw, err)
+		}
+	}
+}
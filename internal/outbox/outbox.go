@@ -0,0 +1,326 @@
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+const (
+	defaultWorkers     = 4
+	defaultMaxAttempts = 8
+	baseBackoff        = 2 * time.Second
+	maxBackoff         = 10 * time.Minute
+	pollInterval       = time.Second
+	// maxDeferAge bounds how long a deferred item (e.g. waiting for a Kite login) is kept
+	maxDeferAge = 24 * time.Hour
+)
+
+// Handler delivers one outbox item; returning nil removes it from the outbox
+type Handler func(item models.OutboxItem) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]Handler)
+
+	// claimMu serializes claiming so two workers never take the same item
+	claimMu sync.Mutex
+	wake    = make(chan struct{}, 1)
+)
+
+// permanentError marks a failure that retrying will not fix
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the item goes straight to the dead-letter list
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// deferredError marks a failure that should be retried later without using up an attempt
+type deferredError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *deferredError) Error() string { return e.err.Error() }
+func (e *deferredError) Unwrap() error { return e.err }
+
+// Defer wraps err so the item is retried after the given delay without counting an attempt,
+// e.g. while the user has no Kite session. Items deferred for over a day are dead-lettered.
+func Defer(err error, after time.Duration) error {
+	return &deferredError{err, after}
+}
+
+// Register sets the handler for an operation
+func Register(operation string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[operation] = h
+}
+
+// Enqueue stores an operation for delivery. A pending item for the same operation and alert
+// is brought forward instead of adding a duplicate, since handlers read the alert's current state.
+func Enqueue(userID int, operation string, alertID int, payload interface{}) error {
+	var payloadJSON []byte
+	if payload != nil {
+		var err error
+		if payloadJSON, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	now := time.Now().UnixMilli()
+
+	var alertArg interface{}
+	if alertID != 0 {
+		alertArg = alertID
+		result, err := db.DB.Exec(
+			"UPDATE outbox SET next_attempt_at = ?, attempts = 0, payload = ?, updated_at = ? WHERE operation = ? AND alert_id = ? AND status = ?",
+			now, string(payloadJSON), now, operation, alertID, models.OutboxPending,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			notify()
+			return nil
+		}
+	}
+
+	_, err := db.DB.Exec(
+		"INSERT INTO outbox (user_id, operation, alert_id, payload, status, attempts, next_attempt_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?)",
+		userID, operation, alertArg, string(payloadJSON), models.OutboxPending, now, now, now,
+	)
+	if err != nil {
+		return err
+	}
+	notify()
+	return nil
+}
+
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+const itemColumns = "id, user_id, operation, COALESCE(alert_id, 0), COALESCE(payload, ''), status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, updated_at"
+
+func scanItem(row interface{ Scan(...interface{}) error }) (models.OutboxItem, error) {
+	var item models.OutboxItem
+	var next, created, updated int64
+	err := row.Scan(&item.ID, &item.UserID, &item.Operation, &item.AlertID, &item.Payload, &item.Status,
+		&item.Attempts, &next, &item.LastError, &created, &updated)
+	item.NextAttemptAt = time.UnixMilli(next)
+	item.CreatedAt = time.UnixMilli(created)
+	item.UpdatedAt = time.UnixMilli(updated)
+	return item, err
+}
+
+// claim marks the next due item as processing. Items for the same alert are delivered in order,
+// so an item waits while an earlier one for its alert is still queued.
+func claim() (models.OutboxItem, bool, error) {
+	claimMu.Lock()
+	defer claimMu.Unlock()
+
+	item, err := scanItem(db.DB.QueryRow(
+		`SELECT `+itemColumns+` FROM outbox o WHERE status = ? AND next_attempt_at <= ?
+		AND (alert_id IS NULL OR NOT EXISTS (
+			SELECT 1 FROM outbox p WHERE p.alert_id = o.alert_id AND p.id < o.id AND p.status IN (?, ?)))
+		ORDER BY next_attempt_at, id LIMIT 1`,
+		models.OutboxPending, time.Now().UnixMilli(), models.OutboxPending, models.OutboxProcessing,
+	))
+	if err == sql.ErrNoRows {
+		return item, false, nil
+	}
+	if err != nil {
+		return item, false, err
+	}
+	_, err = db.DB.Exec("UPDATE outbox SET status = ?, updated_at = ? WHERE id = ?", models.OutboxProcessing, time.Now().UnixMilli(), item.ID)
+	if err != nil {
+		return item, false, err
+	}
+	return item, true, nil
+}
+
+// deliver runs the item's handler and records the outcome
+func deliver(item models.OutboxItem) {
+	handlersMu.RLock()
+	h := handlers[item.Operation]
+	handlersMu.RUnlock()
+
+	var err error
+	if h == nil {
+		err = Permanent(fmt.Errorf("unknown outbox operation %q", item.Operation))
+	} else {
+		err = h(item)
+	}
+	if err == nil {
+		if _, err := db.DB.Exec("DELETE FROM outbox WHERE id = ?", item.ID); err != nil {
+			log.Printf("Failed to remove delivered outbox item %d: %v", item.ID, err)
+		}
+		return
+	}
+
+	var permanent *permanentError
+	var deferred *deferredError
+	switch {
+	case errors.As(err, &permanent):
+		kill(item, err)
+	case errors.As(err, &deferred):
+		if time.Since(item.CreatedAt) > maxDeferAge {
+			kill(item, err)
+			return
+		}
+		reschedule(item, item.Attempts, deferred.after, err)
+	default:
+		attempts := item.Attempts + 1
+		if attempts >= MaxAttempts() {
+			kill(item, fmt.Errorf("giving up after %d attempts: %w", attempts, err))
+			return
+		}
+		reschedule(item, attempts, backoff(attempts), err)
+	}
+}
+
+func reschedule(item models.OutboxItem, attempts int, delay time.Duration, cause error) {
+	log.Printf("🔁 Outbox item %d (%s) failed, retrying in %v: %v", item.ID, item.Operation, delay.Round(time.Second), cause)
+	now := time.Now()
+	_, err := db.DB.Exec("UPDATE outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		models.OutboxPending, attempts, now.Add(delay).UnixMilli(), cause.Error(), now.UnixMilli(), item.ID)
+	if err != nil {
+		log.Printf("Failed to reschedule outbox item %d: %v", item.ID, err)
+	}
+}
+
+// kill moves an item to the dead-letter list
+func kill(item models.OutboxItem, cause error) {
+	log.Printf("💀 Outbox item %d (%s) moved to dead letters: %v", item.ID, item.Operation, cause)
+	_, err := db.DB.Exec("UPDATE outbox SET status = ?, attempts = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		models.OutboxDead, item.Attempts+1, cause.Error(), time.Now().UnixMilli(), item.ID)
+	if err != nil {
+		log.Printf("Failed to dead-letter outbox item %d: %v", item.ID, err)
+	}
+}
+
+// backoff returns the exponential delay before the given attempt, with jitter over its upper half
+func backoff(attempts int) time.Duration {
+	d := maxBackoff
+	if attempts <= 20 {
+		if exp := baseBackoff << (attempts - 1); exp < maxBackoff {
+			d = exp
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// MaxAttempts reads OUTBOX_MAX_ATTEMPTS (default 8)
+func MaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxAttempts
+}
+
+// Workers reads OUTBOX_WORKERS (default 4)
+func Workers() int {
+	if n, err := strconv.Atoi(os.Getenv("OUTBOX_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultWorkers
+}
+
+// Items returns a user's queued items with the given status, oldest first
+func Items(userID int, status string) ([]models.OutboxItem, error) {
+	rows, err := db.DB.Query("SELECT "+itemColumns+" FROM outbox WHERE user_id = ? AND status = ? ORDER BY id", userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OutboxItem
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Retry puts a user's dead-lettered item back in the queue with fresh attempts;
+// it returns sql.ErrNoRows if the user has no such dead item
+func Retry(userID, itemID int) error {
+	now := time.Now().UnixMilli()
+	result, err := db.DB.Exec("UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ?, created_at = ?, updated_at = ? WHERE id = ? AND user_id = ? AND status = ?",
+		models.OutboxPending, now, now, now, itemID, userID, models.OutboxDead)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	notify()
+	return nil
+}
+
+// RetryAll puts every dead-lettered item of a user back in the queue and returns how many
+func RetryAll(userID int) (int, error) {
+	now := time.Now().UnixMilli()
+	result, err := db.DB.Exec("UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ?, created_at = ?, updated_at = ? WHERE user_id = ? AND status = ?",
+		models.OutboxPending, now, now, now, userID, models.OutboxDead)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		notify()
+	}
+	return int(n), nil
+}
+
+// Start requeues items interrupted by a restart and starts the worker pool
+func Start() {
+	if _, err := db.DB.Exec("UPDATE outbox SET status = ? WHERE status = ?", models.OutboxPending, models.OutboxProcessing); err != nil {
+		log.Printf("❌ Failed to requeue interrupted outbox items: %v", err)
+	}
+
+	workers := Workers()
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+	log.Printf("📤 Outbox started with %d workers", workers)
+}
+
+func worker() {
+	for {
+		item, ok, err := claim()
+		if err != nil {
+			log.Printf("❌ Failed to claim outbox item: %v", err)
+		}
+		if !ok {
+			select {
+			case <-wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		deliver(item)
+		// Let another idle worker pick up whatever is queued behind this item
+		notify()
+	}
+}
@@ -0,0 +1,173 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/prices"
+)
+
+// tradingDaysPerYear is used to annualize daily alpha
+const tradingDaysPerYear = 252
+
+// TotalPnLOn returns the user's realized plus unrealized P&L at the end of the given date
+func TotalPnLOn(userID int, date time.Time) (float64, error) {
+	positions, _, err := AsOf(userID, date)
+	if err != nil {
+		return 0, err
+	}
+	realized, unrealized := Totals(positions)
+	return realized + unrealized, nil
+}
+
+// pnlSeries returns the user's total P&L at the end of each date, ascending, like TotalPnLOn but
+// reading the trades and snapshots once: past days use their snapshot, the others replay trades.
+func pnlSeries(userID int, dates []time.Time) ([]float64, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	first := market.LastTradingDay(market.NSE, dates[0]).Format(DateFormat)
+	snapshots, err := snapshotTotals(userID, first, dates[len(dates)-1].Format(DateFormat))
+	if err != nil {
+		return nil, err
+	}
+	fills, err := loadFills(userID, EndOfDay(dates[len(dates)-1]))
+	if err != nil {
+		return nil, err
+	}
+
+	b := newBook(fills)
+	now := time.Now()
+	pnls := make([]float64, len(dates))
+	for i, date := range dates {
+		asOf := EndOfDay(date)
+		if asOf.Before(now) {
+			if total, ok := snapshots[market.LastTradingDay(market.NSE, date).Format(DateFormat)]; ok {
+				pnls[i] = total
+				continue
+			}
+		} else {
+			asOf = now
+		}
+		b.advance(asOf)
+		positions, err := b.marked(asOf)
+		if err != nil {
+			return nil, err
+		}
+		realized, unrealized := Totals(positions)
+		pnls[i] = realized + unrealized
+	}
+	return pnls, nil
+}
+
+// CompareToBenchmark builds the user's equity curve on the benchmark's trading days between
+// from and to (YYYY-MM-DD) and computes alpha, beta and correlation on daily returns.
+// Equity is capital plus total P&L, so capital sets the base that returns are measured against.
+func CompareToBenchmark(userID int, benchmark, from, to string, capital float64) (*models.BenchmarkResponse, error) {
+	if capital <= 0 {
+		return nil, fmt.Errorf("capital must be positive")
+	}
+	closes, err := prices.DailyCloses(benchmark, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(closes) == 0 {
+		return nil, fmt.Errorf("no prices loaded for benchmark %s between %s and %s", benchmark, from, to)
+	}
+
+	response := &models.BenchmarkResponse{
+		Success:   true,
+		Benchmark: benchmark,
+		Capital:   capital,
+		Points:    make([]models.BenchmarkPoint, 0, len(closes)),
+	}
+
+	dates := make([]time.Time, len(closes))
+	for i, c := range closes {
+		date, err := time.ParseInLocation(DateFormat, c.Date, market.IST)
+		if err != nil {
+			return nil, fmt.Errorf("invalid benchmark date %q: %v", c.Date, err)
+		}
+		dates[i] = date
+	}
+	pnls, err := pnlSeries(userID, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Returns need a positive base: the first day is the base of cumulative returns, and a day
+	// after one without positive equity or close has no daily return
+	if equity := capital + pnls[0]; equity <= 0 {
+		return nil, fmt.Errorf("equity on %s is %.2f; use a larger capital", closes[0].Date, equity)
+	}
+	if closes[0].Close <= 0 {
+		return nil, fmt.Errorf("benchmark close on %s is not positive", closes[0].Date)
+	}
+
+	var portfolioReturns, benchmarkReturns []float64
+	for i, c := range closes {
+		point := models.BenchmarkPoint{
+			Date:           c.Date,
+			Equity:         capital + pnls[i],
+			BenchmarkClose: c.Close,
+		}
+		if i > 0 {
+			first, prev := response.Points[0], response.Points[i-1]
+			point.PortfolioReturn = point.Equity/first.Equity - 1
+			point.BenchmarkReturn = point.BenchmarkClose/first.BenchmarkClose - 1
+			if prev.Equity > 0 && prev.BenchmarkClose > 0 {
+				portfolioReturns = append(portfolioReturns, point.Equity/prev.Equity-1)
+				benchmarkReturns = append(benchmarkReturns, point.BenchmarkClose/prev.BenchmarkClose-1)
+			} else {
+				response.Stats.SkippedDays++
+			}
+		}
+		response.Points = append(response.Points, point)
+	}
+
+	skipped := response.Stats.SkippedDays
+	response.Stats = ReturnStats(portfolioReturns, benchmarkReturns)
+	response.Stats.SkippedDays = skipped
+	return response, nil
+}
+
+// ReturnStats computes alpha, beta and correlation of portfolio daily returns against benchmark
+// daily returns. Both slices must be aligned day by day.
+func ReturnStats(portfolioReturns, benchmarkReturns []float64) models.BenchmarkStats {
+	n := len(portfolioReturns)
+	stats := models.BenchmarkStats{Days: n}
+	if n < 2 || len(benchmarkReturns) != n {
+		return stats
+	}
+
+	meanP, meanB := mean(portfolioReturns), mean(benchmarkReturns)
+	var cov, varP, varB float64
+	for i := 0; i < n; i++ {
+		devP := portfolioReturns[i] - meanP
+		devB := benchmarkReturns[i] - meanB
+		cov += devP * devB
+		varP += devP * devP
+		varB += devB * devB
+	}
+
+	if varB > 0 {
+		stats.Beta = cov / varB
+	}
+	if varP > 0 && varB > 0 {
+		stats.Correlation = cov / math.Sqrt(varP*varB)
+	}
+	stats.Alpha = meanP - stats.Beta*meanB
+	stats.AnnualizedAlpha = stats.Alpha * tradingDaysPerYear
+	return stats
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
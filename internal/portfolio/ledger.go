@@ -0,0 +1,80 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// ValidLedgerType reports whether the entry type is one the ledger accepts
+func ValidLedgerType(entryType string) bool {
+	switch entryType {
+	case models.LedgerDeposit, models.LedgerWithdrawal, models.LedgerDividend, models.LedgerCharge:
+		return true
+	}
+	return false
+}
+
+// signedAmount returns the entry's effect on the cash balance
+func signedAmount(e models.LedgerEntry) float64 {
+	switch e.EntryType {
+	case models.LedgerWithdrawal, models.LedgerCharge:
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+// isExternalFlow reports whether the entry moves capital in or out of the account
+func isExternalFlow(e models.LedgerEntry) bool {
+	return e.EntryType == models.LedgerDeposit || e.EntryType == models.LedgerWithdrawal
+}
+
+// AddLedgerEntry stores a ledger entry for the user and returns its ID
+func AddLedgerEntry(userID int, req models.LedgerEntryRequest) (int, error) {
+	result, err := db.DB.Exec(
+		"INSERT INTO cash_ledger (user_id, entry_type, amount, entry_date, note, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, req.EntryType, req.Amount, req.Date, req.Note, time.Now(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert ledger entry: %v", err)
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// DeleteLedgerEntry removes a ledger entry; the boolean is false when nothing matched
+func DeleteLedgerEntry(userID, entryID int) (bool, error) {
+	result, err := db.DB.Exec("DELETE FROM cash_ledger WHERE id = ? AND user_id = ?", entryID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete ledger entry: %v", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// LedgerEntries returns the user's entries up to and including the given date (YYYY-MM-DD),
+// oldest first, each with the running cash balance after it
+func LedgerEntries(userID int, to string) ([]models.LedgerEntry, error) {
+	rows, err := db.DB.Query("SELECT id, entry_type, amount, entry_date, note, created_at FROM cash_ledger WHERE user_id = ? AND entry_date <= ? ORDER BY entry_date, id", userID, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LedgerEntry{}
+	var balance float64
+	for rows.Next() {
+		e := models.LedgerEntry{UserID: userID}
+		var note *string
+		if err := rows.Scan(&e.ID, &e.EntryType, &e.Amount, &e.Date, &note, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %v", err)
+		}
+		e.Note = deref(note)
+		balance += signedAmount(e)
+		e.Balance = balance
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,190 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+	"github.com/vinaykotian/stock-panel/internal/quotes"
+)
+
+// DateFormat is the layout used for snapshot dates and as-of queries
+const DateFormat = "2006-01-02"
+
+// fill is a single recorded trade from the stocks table
+type fill struct {
+	stock    models.Stock
+	quantity float64
+}
+
+// loadFills returns the user's trades recorded at or before asOf, oldest first
+func loadFills(userID int, asOf time.Time) ([]fill, error) {
+	rows, err := db.DB.Query("SELECT symbol, underlying_symbol, option_type, strike_price, expiry, price, side, timestamp FROM stocks WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stocks: %v", err)
+	}
+	defer rows.Close()
+
+	var fills []fill
+	for rows.Next() {
+		var s models.Stock
+		var underlying, optionType, expiry, side *string
+		var strike *float64
+		if err := rows.Scan(&s.Symbol, &underlying, &optionType, &strike, &expiry, &s.Price, &side, &s.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %v", err)
+		}
+		if s.Timestamp.After(asOf) {
+			continue
+		}
+		s.UnderlyingSymbol = deref(underlying)
+		s.OptionType = deref(optionType)
+		s.Expiry = deref(expiry)
+		s.Side = deref(side)
+		if strike != nil {
+			s.StrikePrice = *strike
+		}
+		s.UserID = userID
+		fills = append(fills, fill{stock: s, quantity: 1})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].stock.Timestamp.Before(fills[j].stock.Timestamp)
+	})
+	return fills, nil
+}
+
+// Positions computes the user's positions as of the given time using average-cost accounting.
+// Positions are marked at the last quote at or before asOf, or the last fill when that is newer.
+// Instruments that were fully closed are kept so their realized P&L is reported.
+func Positions(userID int, asOf time.Time) ([]models.Position, error) {
+	fills, err := loadFills(userID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	b := newBook(fills)
+	b.advance(asOf)
+	return b.marked(asOf)
+}
+
+// book replays fills in time order, so positions at a series of times cost one read of the trades
+type book struct {
+	fills    []fill
+	next     int // first fill not yet applied
+	bySymbol map[string]*models.Position
+	lastFill map[string]time.Time
+	order    []string
+}
+
+func newBook(fills []fill) *book {
+	return &book{fills: fills, bySymbol: make(map[string]*models.Position), lastFill: make(map[string]time.Time)}
+}
+
+// advance applies the fills recorded at or before asOf; asOf must not go back in time
+func (b *book) advance(asOf time.Time) {
+	for ; b.next < len(b.fills) && !b.fills[b.next].stock.Timestamp.After(asOf); b.next++ {
+		f := b.fills[b.next]
+		p, ok := b.bySymbol[f.stock.Symbol]
+		if !ok {
+			p = &models.Position{
+				Symbol:           f.stock.Symbol,
+				UnderlyingSymbol: f.stock.UnderlyingSymbol,
+				OptionType:       f.stock.OptionType,
+				StrikePrice:      f.stock.StrikePrice,
+				Expiry:           f.stock.Expiry,
+			}
+			b.bySymbol[f.stock.Symbol] = p
+			b.order = append(b.order, f.stock.Symbol)
+		}
+
+		qty := f.quantity
+		switch f.stock.Side {
+		case "BUY":
+		case "SELL":
+			qty = -qty
+		default:
+			continue
+		}
+		applyFill(p, qty, f.stock.Price)
+		p.MarkPrice = f.stock.Price
+		b.lastFill[f.stock.Symbol] = f.stock.Timestamp
+	}
+}
+
+// marked returns copies of the applied positions marked as of asOf
+func (b *book) marked(asOf time.Time) ([]models.Position, error) {
+	positions := make([]models.Position, 0, len(b.order))
+	for _, symbol := range b.order {
+		p := *b.bySymbol[symbol]
+		q, ok, err := quotes.LatestAt(symbol, asOf)
+		if err != nil {
+			return nil, err
+		}
+		if ok && !q.Timestamp.Before(b.lastFill[symbol]) {
+			p.MarkPrice = q.LTP
+		}
+		p.UnrealizedPnL = (p.MarkPrice - p.AvgCost) * p.NetQuantity
+		positions = append(positions, p)
+	}
+	return positions, nil
+}
+
+// applyFill updates a position with a signed fill quantity at the given price
+func applyFill(p *models.Position, qty, price float64) {
+	if p.NetQuantity == 0 || (p.NetQuantity > 0) == (qty > 0) {
+		total := abs(p.NetQuantity) + abs(qty)
+		p.AvgCost = (p.AvgCost*abs(p.NetQuantity) + price*abs(qty)) / total
+		p.NetQuantity += qty
+		return
+	}
+
+	// Fill reduces (and possibly reverses) the existing position
+	closing := min(abs(qty), abs(p.NetQuantity))
+	if p.NetQuantity > 0 {
+		p.RealizedPnL += (price - p.AvgCost) * closing
+	} else {
+		p.RealizedPnL += (p.AvgCost - price) * closing
+	}
+	p.NetQuantity += qty
+	switch {
+	case p.NetQuantity == 0:
+		p.AvgCost = 0
+	case abs(qty) > closing:
+		// Reversed through zero; the remainder opens at the fill price
+		p.AvgCost = price
+	}
+}
+
+// Totals sums realized and unrealized P&L across positions
+func Totals(positions []models.Position) (realized, unrealized float64) {
+	for _, p := range positions {
+		realized += p.RealizedPnL
+		unrealized += p.UnrealizedPnL
+	}
+	return realized, unrealized
+}
+
+// EndOfDay returns the last instant of the given trading date in IST
+func EndOfDay(date time.Time) time.Time {
+	y, m, d := date.In(market.IST).Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, market.IST).Add(-time.Nanosecond)
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,211 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// cashFlow is a dated amount from the investor's point of view (negative = money put in)
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// Returns computes money-weighted (XIRR) and time-weighted returns for the user between
+// from and to (YYYY-MM-DD, inclusive). Account value is the cash ledger balance plus total P&L;
+// deposits and withdrawals are treated as flows at the start of their day.
+func Returns(userID int, from, to string) (*models.ReturnsResponse, error) {
+	fromDate, err := time.ParseInLocation(DateFormat, from, market.IST)
+	if err != nil {
+		return nil, fmt.Errorf("from must be a date in YYYY-MM-DD format")
+	}
+	toDate, err := time.ParseInLocation(DateFormat, to, market.IST)
+	if err != nil {
+		return nil, fmt.Errorf("to must be a date in YYYY-MM-DD format")
+	}
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+
+	entries, err := LedgerEntries(userID, to)
+	if err != nil {
+		return nil, err
+	}
+	accountValue := func(date time.Time) (float64, error) {
+		pnl, err := TotalPnLOn(userID, date)
+		if err != nil {
+			return 0, err
+		}
+		return balanceOn(entries, date.Format(DateFormat)) + pnl, nil
+	}
+
+	startDay := market.PreviousTradingDay(market.NSE, fromDate)
+	startValue, err := accountValue(startDay)
+	if err != nil {
+		return nil, err
+	}
+	endValue, err := accountValue(toDate)
+	if err != nil {
+		return nil, err
+	}
+	startPnL, err := TotalPnLOn(userID, startDay)
+	if err != nil {
+		return nil, err
+	}
+	endPnL, err := TotalPnLOn(userID, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ReturnsResponse{
+		Success:     true,
+		From:        from,
+		To:          to,
+		StartValue:  startValue,
+		EndValue:    endValue,
+		PnL:         endPnL - startPnL,
+		CashBalance: balanceOn(entries, to),
+	}
+
+	// Net external flow per day within the period
+	flowsByDate := make(map[string]float64)
+	for _, e := range entries {
+		if e.Date < from {
+			continue
+		}
+		switch e.EntryType {
+		case models.LedgerDividend:
+			response.Dividends += e.Amount
+		case models.LedgerCharge:
+			response.Charges += e.Amount
+		}
+		if isExternalFlow(e) {
+			flowsByDate[e.Date] += signedAmount(e)
+			response.NetFlows += signedAmount(e)
+		}
+	}
+	flowDates := make([]string, 0, len(flowsByDate))
+	for date := range flowsByDate {
+		flowDates = append(flowDates, date)
+	}
+	sort.Strings(flowDates)
+
+	// Time-weighted: chain sub-period returns split at each flow
+	growth := 1.0
+	base := startValue
+	flows := []cashFlow{}
+	if startValue != 0 {
+		flows = append(flows, cashFlow{date: fromDate, amount: -startValue})
+	}
+	for _, date := range flowDates {
+		d, _ := time.ParseInLocation(DateFormat, date, market.IST)
+		before, err := accountValue(market.PreviousTradingDay(market.NSE, d))
+		if err != nil {
+			return nil, err
+		}
+		if base > 0 {
+			growth *= before / base
+		}
+		base = before + flowsByDate[date]
+		flows = append(flows, cashFlow{date: d, amount: -flowsByDate[date]})
+	}
+	if base > 0 {
+		growth *= endValue / base
+	}
+	response.TWR = growth - 1
+
+	flows = append(flows, cashFlow{date: toDate, amount: endValue})
+	if rate, ok := xirr(flows); ok {
+		response.XIRR = &rate
+	}
+	return response, nil
+}
+
+// balanceOn returns the running cash balance at the end of the given date
+func balanceOn(entries []models.LedgerEntry, date string) float64 {
+	var balance float64
+	for _, e := range entries {
+		if e.Date > date {
+			break
+		}
+		balance = e.Balance
+	}
+	return balance
+}
+
+// xirr solves for the annualized rate at which the flows' net present value is zero.
+// It returns false when the flows do not change sign or no root is found.
+func xirr(flows []cashFlow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+	var hasPositive, hasNegative bool
+	for _, f := range flows {
+		hasPositive = hasPositive || f.amount > 0
+		hasNegative = hasNegative || f.amount < 0
+	}
+	if !hasPositive || !hasNegative {
+		return 0, false
+	}
+
+	start := flows[0].date
+	for _, f := range flows {
+		if f.date.Before(start) {
+			start = f.date
+		}
+	}
+	npv := func(rate float64) (value, derivative float64) {
+		for _, f := range flows {
+			years := f.date.Sub(start).Hours() / 24 / 365
+			factor := math.Pow(1+rate, years)
+			value += f.amount / factor
+			derivative -= years * f.amount / (factor * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	// Newton's method first, bisection as a fallback
+	rate := 0.1
+	for i := 0; i < 100; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < 1e-7 {
+			return rate, true
+		}
+		if derivative == 0 {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-10 {
+			return next, true
+		}
+		rate = next
+	}
+
+	low, high := -0.9999, 100.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	if lowValue*highValue > 0 {
+		return 0, false
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		midValue, _ := npv(mid)
+		if math.Abs(midValue) < 1e-7 {
+			return mid, true
+		}
+		if lowValue*midValue < 0 {
+			high = mid
+		} else {
+			low, lowValue = mid, midValue
+		}
+	}
+	return (low + high) / 2, true
+}
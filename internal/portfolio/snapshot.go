@@ -0,0 +1,160 @@
+package portfolio
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/market"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// snapshotDelay is how long after market close snapshots are taken, so late fills are included
+const snapshotDelay = 5 * time.Minute
+
+// TakeSnapshot stores every user's positions, marks and P&L for the given trading date
+func TakeSnapshot(date time.Time) error {
+	userIDs, err := db.GetUserIDs()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %v", err)
+	}
+	for _, userID := range userIDs {
+		if err := SnapshotUser(userID, date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotUser stores one user's portfolio for the given trading date, replacing any earlier snapshot
+func SnapshotUser(userID int, date time.Time) error {
+	positions, err := Positions(userID, EndOfDay(date))
+	if err != nil {
+		return err
+	}
+	snapshotDate := date.In(market.IST).Format(DateFormat)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin snapshot transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM portfolio_snapshots WHERE user_id = ? AND snapshot_date = ?", userID, snapshotDate); err != nil {
+		return fmt.Errorf("failed to clear snapshot: %v", err)
+	}
+	for _, p := range positions {
+		_, err := tx.Exec(
+			"INSERT INTO portfolio_snapshots (user_id, snapshot_date, symbol, underlying_symbol, option_type, strike_price, expiry, net_quantity, avg_cost, mark_price, realized_pnl, unrealized_pnl, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			userID, snapshotDate, p.Symbol, p.UnderlyingSymbol, p.OptionType, p.StrikePrice, p.Expiry, p.NetQuantity, p.AvgCost, p.MarkPrice, p.RealizedPnL, p.UnrealizedPnL, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert snapshot row: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadSnapshot returns the stored positions for a user and date.
+// The boolean is false when no snapshot was taken for that date.
+func LoadSnapshot(userID int, date time.Time) ([]models.Position, bool, error) {
+	snapshotDate := date.In(market.IST).Format(DateFormat)
+
+	var count int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM portfolio_snapshots WHERE user_id = ? AND snapshot_date = ?", userID, snapshotDate).Scan(&count); err != nil {
+		return nil, false, fmt.Errorf("failed to query snapshots: %v", err)
+	}
+	if count == 0 {
+		return nil, false, nil
+	}
+
+	rows, err := db.DB.Query("SELECT symbol, underlying_symbol, option_type, strike_price, expiry, net_quantity, avg_cost, mark_price, realized_pnl, unrealized_pnl FROM portfolio_snapshots WHERE user_id = ? AND snapshot_date = ? ORDER BY id", userID, snapshotDate)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	positions := []models.Position{}
+	for rows.Next() {
+		var p models.Position
+		if err := rows.Scan(&p.Symbol, &p.UnderlyingSymbol, &p.OptionType, &p.StrikePrice, &p.Expiry, &p.NetQuantity, &p.AvgCost, &p.MarkPrice, &p.RealizedPnL, &p.UnrealizedPnL); err != nil {
+			return nil, false, fmt.Errorf("failed to scan snapshot: %v", err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, true, rows.Err()
+}
+
+// snapshotTotals returns the realized plus unrealized P&L of each of the user's snapshots between
+// from and to (YYYY-MM-DD, inclusive), by date
+func snapshotTotals(userID int, from, to string) (map[string]float64, error) {
+	rows, err := db.DB.Query("SELECT snapshot_date, SUM(realized_pnl + unrealized_pnl) FROM portfolio_snapshots WHERE user_id = ? AND snapshot_date BETWEEN ? AND ? GROUP BY snapshot_date", userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var date string
+		var total float64
+		if err := rows.Scan(&date, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %v", err)
+		}
+		totals[date] = total
+	}
+	return totals, rows.Err()
+}
+
+// AsOf returns the user's positions at the end of the given date and where they came from.
+// Past days use the end-of-day snapshot of their last trading day ("snapshot") when one exists;
+// otherwise, and for today, positions are recomputed from trades and quotes ("computed").
+func AsOf(userID int, date time.Time) ([]models.Position, string, error) {
+	now := time.Now()
+	asOf := EndOfDay(date)
+	if asOf.Before(now) {
+		// Weekends and holidays carry the previous trading day's close
+		positions, found, err := LoadSnapshot(userID, market.LastTradingDay(market.NSE, date))
+		if err != nil {
+			return nil, "", err
+		}
+		if found {
+			return positions, "snapshot", nil
+		}
+	} else {
+		asOf = now
+	}
+
+	positions, err := Positions(userID, asOf)
+	if err != nil {
+		return nil, "", err
+	}
+	return positions, "computed", nil
+}
+
+// nextSnapshotTime returns the next snapshot run after now: shortly after the NSE close on a trading day
+func nextSnapshotTime(now time.Time) time.Time {
+	next := market.Close(market.NSE, now).Add(snapshotDelay)
+	for !next.After(now) || !market.IsTradingDay(market.NSE, next) {
+		next = market.Close(market.NSE, next.AddDate(0, 0, 1)).Add(snapshotDelay)
+	}
+	return next
+}
+
+// StartSnapshotScheduler starts a goroutine that snapshots all portfolios after each market close
+func StartSnapshotScheduler() {
+	go func() {
+		for {
+			next := nextSnapshotTime(time.Now())
+			log.Printf("📸 Next portfolio snapshot scheduled for %s", next.Format(time.RFC3339))
+			time.Sleep(time.Until(next))
+
+			if err := TakeSnapshot(next); err != nil {
+				log.Printf("❌ Failed to take portfolio snapshot: %v", err)
+				continue
+			}
+			log.Printf("✅ Portfolio snapshot taken for %s", next.Format(DateFormat))
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package prices
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// DateFormat is the layout used for daily price dates
+const DateFormat = "2006-01-02"
+
+// SaveDailyCloses upserts a series of daily closing prices for a symbol
+func SaveDailyCloses(symbol string, closes []models.DailyPrice) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO daily_prices (symbol, price_date, close) VALUES (?, ?, ?) ON CONFLICT (symbol, price_date) DO UPDATE SET close = excluded.close")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range closes {
+		if _, err := time.Parse(DateFormat, c.Date); err != nil {
+			return fmt.Errorf("invalid date %q: expected YYYY-MM-DD", c.Date)
+		}
+		if _, err := stmt.Exec(symbol, c.Date, c.Close); err != nil {
+			return fmt.Errorf("failed to insert price for %s: %v", c.Date, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DailyCloses returns the closing prices for a symbol between from and to (inclusive), oldest first
+func DailyCloses(symbol, from, to string) ([]models.DailyPrice, error) {
+	rows, err := db.DB.Query("SELECT price_date, close FROM daily_prices WHERE symbol = ? AND price_date >= ? AND price_date <= ? ORDER BY price_date", symbol, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily prices: %v", err)
+	}
+	defer rows.Close()
+
+	var closes []models.DailyPrice
+	for rows.Next() {
+		var c models.DailyPrice
+		if err := rows.Scan(&c.Date, &c.Close); err != nil {
+			return nil, fmt.Errorf("failed to scan daily price: %v", err)
+		}
+		closes = append(closes, c)
+	}
+	return closes, rows.Err()
+}
+
+// Symbols returns the symbols that have daily prices loaded with their date range
+func Symbols() ([]models.PriceSeries, error) {
+	rows, err := db.DB.Query("SELECT symbol, MIN(price_date), MAX(price_date), COUNT(*) FROM daily_prices GROUP BY symbol ORDER BY symbol")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily prices: %v", err)
+	}
+	defer rows.Close()
+
+	series := []models.PriceSeries{}
+	for rows.Next() {
+		var s models.PriceSeries
+		if err := rows.Scan(&s.Symbol, &s.From, &s.To, &s.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan price series: %v", err)
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
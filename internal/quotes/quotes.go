@@ -0,0 +1,201 @@
+package quotes
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinaykotian/stock-panel/internal/db"
+	"github.com/vinaykotian/stock-panel/internal/models"
+)
+
+// defaultRetentionDays is how long raw quotes are kept when QUOTE_RETENTION_DAYS is not set
+const defaultRetentionDays = 30
+
+var (
+	// Latest quote per symbol, kept in memory so hot paths avoid a DB query
+	latestMu sync.RWMutex
+	latest   = make(map[string]models.Quote)
+
+	// Functions called with every batch after it is stored
+	listenersMu sync.RWMutex
+	listeners   []func([]models.Quote)
+)
+
+// OnSave registers a function that is called with each batch of quotes after it is stored.
+// Listeners run synchronously on the ingesting goroutine, in registration order.
+func OnSave(fn func([]models.Quote)) {
+	listenersMu.Lock()
+	listeners = append(listeners, fn)
+	listenersMu.Unlock()
+}
+
+// Save validates and stores quotes, updating the latest-quote cache.
+// Quotes without a timestamp are stamped with the current time.
+func Save(batch []models.Quote) error {
+	now := time.Now()
+	for i := range batch {
+		if batch[i].Symbol == "" {
+			return fmt.Errorf("quote %d: symbol is required", i)
+		}
+		if batch[i].LTP <= 0 {
+			return fmt.Errorf("quote %d (%s): ltp must be positive", i, batch[i].Symbol)
+		}
+		if batch[i].Timestamp.IsZero() {
+			batch[i].Timestamp = now
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO quotes (symbol, exchange, ltp, bid, ask, volume, oi, ts) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, q := range batch {
+		if _, err := stmt.Exec(q.Symbol, q.Exchange, q.LTP, q.Bid, q.Ask, q.Volume, q.OI, q.Timestamp.UnixMilli()); err != nil {
+			return fmt.Errorf("failed to insert quote for %s: %v", q.Symbol, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit quotes: %v", err)
+	}
+
+	latestMu.Lock()
+	for _, q := range batch {
+		if cur, ok := latest[q.Symbol]; !ok || !q.Timestamp.Before(cur.Timestamp) {
+			latest[q.Symbol] = q
+		}
+	}
+	latestMu.Unlock()
+
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(batch)
+	}
+	return nil
+}
+
+// Latest returns the most recent quote for a symbol
+func Latest(symbol string) (models.Quote, bool, error) {
+	latestMu.RLock()
+	q, ok := latest[symbol]
+	latestMu.RUnlock()
+	if ok {
+		return q, true, nil
+	}
+
+	q, ok, err := LatestAt(symbol, time.Now())
+	if err != nil || !ok {
+		return q, ok, err
+	}
+	latestMu.Lock()
+	if cur, exists := latest[symbol]; !exists || q.Timestamp.After(cur.Timestamp) {
+		latest[symbol] = q
+	}
+	latestMu.Unlock()
+	return q, true, nil
+}
+
+// LatestAt returns the last quote for a symbol at or before the given time
+func LatestAt(symbol string, at time.Time) (models.Quote, bool, error) {
+	q := models.Quote{Symbol: symbol}
+	var exchange *string
+	var ts int64
+	err := db.DB.QueryRow(
+		"SELECT exchange, ltp, bid, ask, volume, oi, ts FROM quotes WHERE symbol = ? AND ts <= ? ORDER BY ts DESC LIMIT 1",
+		symbol, at.UnixMilli(),
+	).Scan(&exchange, &q.LTP, &q.Bid, &q.Ask, &q.Volume, &q.OI, &ts)
+	if err == sql.ErrNoRows {
+		return q, false, nil
+	}
+	if err != nil {
+		return q, false, fmt.Errorf("failed to query latest quote: %v", err)
+	}
+	if exchange != nil {
+		q.Exchange = *exchange
+	}
+	q.Timestamp = time.UnixMilli(ts)
+	return q, true, nil
+}
+
+// Range returns the quotes for a symbol in [from, to), oldest first
+func Range(symbol string, from, to time.Time) ([]models.Quote, error) {
+	rows, err := db.DB.Query(
+		"SELECT exchange, ltp, bid, ask, volume, oi, ts FROM quotes WHERE symbol = ? AND ts >= ? AND ts < ? ORDER BY ts",
+		symbol, from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotes: %v", err)
+	}
+	defer rows.Close()
+
+	var result []models.Quote
+	for rows.Next() {
+		q := models.Quote{Symbol: symbol}
+		var exchange *string
+		var ts int64
+		if err := rows.Scan(&exchange, &q.LTP, &q.Bid, &q.Ask, &q.Volume, &q.OI, &ts); err != nil {
+			return nil, fmt.Errorf("failed to scan quote: %v", err)
+		}
+		if exchange != nil {
+			q.Exchange = *exchange
+		}
+		q.Timestamp = time.UnixMilli(ts)
+		result = append(result, q)
+	}
+	return result, rows.Err()
+}
+
+// RetentionDays returns how many days of raw quotes are kept (QUOTE_RETENTION_DAYS, 0 keeps everything)
+func RetentionDays() int {
+	if value := os.Getenv("QUOTE_RETENTION_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days >= 0 {
+			return days
+		}
+		log.Printf("⚠️  Invalid QUOTE_RETENTION_DAYS %q, using %d", value, defaultRetentionDays)
+	}
+	return defaultRetentionDays
+}
+
+// Prune deletes quotes older than the retention window and returns how many were removed
+func Prune(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	result, err := db.DB.Exec("DELETE FROM quotes WHERE ts < ?", cutoff.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune quotes: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// StartRetention starts a goroutine that periodically prunes quotes past the retention window
+func StartRetention() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour) // Prune every hour
+		defer ticker.Stop()
+
+		for {
+			removed, err := Prune(RetentionDays())
+			if err != nil {
+				log.Printf("❌ Quote retention failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("🧹 Pruned %d quotes older than %d days", removed, RetentionDays())
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -2,14 +2,75 @@
 class AlertsManager {
   constructor() {
     this.alerts = [];
+    this.channels = [];
     this.currentEditId = null;
+    this.prices = {};
+    this.eventSource = null;
     this.init();
   }
   
   async init() {
     console.log('Initializing alerts manager...');
     await this.loadAlerts();
+    this.loadChannels();
     this.setupEventListeners();
+    this.checkKiteSession();
+    this.connectLiveUpdates();
+  }
+  
+  // Stream prices and alert triggers from the server; EventSource reconnects on its own
+  connectLiveUpdates() {
+    const token = localStorage.getItem('authToken');
+    if (!token || !window.EventSource) return;
+    
+    this.eventSource = new EventSource(`/events?token=${encodeURIComponent(token)}`);
+    this.eventSource.addEventListener('quote', (e) => {
+      const quote = JSON.parse(e.data);
+      this.prices[quote.symbol] = quote.ltp;
+      document.querySelectorAll('.alert-ltp').forEach(el => {
+        if (el.dataset.symbol === quote.symbol) {
+          el.textContent = `₹${quote.ltp.toFixed(2)}`;
+        }
+      });
+    });
+    this.eventSource.addEventListener('alert', async (e) => {
+      const trigger = JSON.parse(e.data);
+      this.showSuccess(`🔔 ${trigger.symbol} at ₹${trigger.price}: ${trigger.message || 'alert triggered'}`);
+      await this.loadAlerts();
+    });
+  }
+  
+  async checkKiteSession() {
+    const params = new URLSearchParams(window.location.search);
+    if (params.get('kite') === 'connected') {
+      this.showSuccess('Kite account connected');
+    } else if (params.get('kite')) {
+      this.showError('Kite login failed. Please try again.');
+    }
+    
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {};
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch('/kite/session', { headers });
+      if (!response.ok) return;
+      const data = await response.json();
+      
+      const banner = document.getElementById('kiteSessionBanner');
+      if (!banner || !data.configured) return;
+      if (!data.connected) {
+        document.getElementById('kiteSessionMessage').textContent =
+          data.message || 'Connect your Kite account to sync alerts.';
+        banner.style.display = 'flex';
+      } else {
+        banner.style.display = 'none';
+      }
+    } catch (error) {
+      console.error('Error checking Kite session:', error);
+    }
   }
   
   setupEventListeners() {
@@ -19,6 +80,21 @@ class AlertsManager {
       alertForm.addEventListener('submit', (e) => this.handleFormSubmit(e));
     }
     
+    const channelForm = document.getElementById('channelForm');
+    if (channelForm) {
+      channelForm.addEventListener('submit', (e) => this.createChannel(e));
+    }
+    
+    // Symbol autocomplete from the instruments master
+    const symbolInput = document.getElementById('symbol');
+    if (symbolInput) {
+      let searchTimer = null;
+      symbolInput.addEventListener('input', () => {
+        clearTimeout(searchTimer);
+        searchTimer = setTimeout(() => this.suggestSymbols(symbolInput.value), 200);
+      });
+    }
+    
     // Modal close events
     window.addEventListener('click', (e) => {
       if (e.target.classList.contains('modal')) {
@@ -36,6 +112,28 @@ class AlertsManager {
     });
   }
   
+  async suggestSymbols(query) {
+    const list = document.getElementById('symbolSuggestions');
+    if (!list || query.trim().length < 2) return;
+    
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {};
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch(`/instruments/search?q=${encodeURIComponent(query.trim())}&limit=10`, { headers });
+      if (!response.ok) return;
+      const data = await response.json();
+      list.innerHTML = (data.instruments || []).map(inst =>
+        `<option value="${this.escapeHtml(inst.tradingsymbol)}">${this.escapeHtml(inst.exchange)} · ${this.escapeHtml(inst.name || inst.instrument_type)}</option>`
+      ).join('');
+    } catch (error) {
+      console.error('Error searching instruments:', error);
+    }
+  }
+  
   async loadAlerts() {
     try {
       this.showLoading(true);
@@ -87,9 +185,14 @@ class AlertsManager {
   }
   
   createAlertCard(alert) {
-    const statusClass = alert.is_active ? 'active' : 'inactive';
-    const statusText = alert.is_active ? 'Active' : 'Inactive';
-    const statusIcon = alert.is_active ? 'fa-bell' : 'fa-bell-slash';
+    const states = {
+      'armed': ['active', 'Armed', 'fa-bell'],
+      'cooling_down': ['waiting', 'Cooling Down', 'fa-hourglass-half'],
+      'triggered': [alert.is_active ? 'waiting' : 'inactive', 'Triggered', 'fa-check'],
+      'expired': ['inactive', 'Expired', 'fa-clock'],
+      'disabled': ['inactive', 'Inactive', 'fa-bell-slash']
+    };
+    const [statusClass, statusText, statusIcon] = states[alert.state] || (alert.is_active ? states.armed : states.disabled);
     
     const toggleBtnText = alert.is_active ? 'Deactivate' : 'Activate';
     const toggleBtnClass = alert.is_active ? 'deactivate' : 'toggle';
@@ -105,7 +208,7 @@ class AlertsManager {
       <div class="alert-card ${alert.is_active ? '' : 'inactive'}" data-alert-id="${alert.id}">
         <div class="alert-header">
           <h3 class="alert-symbol">
-            ${this.escapeHtml(alert.symbol)}
+            ${this.escapeHtml(alert.symbol || (alert.alert_type === 'POSITION_LOSS' ? 'Any position' : 'Portfolio'))}
             ${isOption ? `<span style="font-size: 0.8em; color: #7f8c8d; margin-left: 0.5em;">(${alert.option_type})</span>` : ''}
           </h3>
           <span class="alert-status ${statusClass}">
@@ -135,8 +238,32 @@ class AlertsManager {
           </div>
           <div class="alert-detail">
             <span class="alert-detail-label">Condition:</span>
-            <span class="alert-detail-value">${this.formatCondition(alert.condition)} ${alert.target_value}</span>
+            <span class="alert-detail-value">${alert.alert_type === 'EXPRESSION' ? this.escapeHtml(alert.expression) : alert.alert_type === 'TRAILING_STOP' ? this.formatTrail(alert) : PORTFOLIO_TYPES.includes(alert.alert_type) ? this.formatPortfolio(alert) : alert.interval ? this.formatIndicator(alert) : `${this.formatCondition(alert.condition)} ${alert.target_value}`}</span>
           </div>
+          ${alert.alert_type === 'TRAILING_STOP' ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Trail Level:</span>
+              <span class="alert-detail-value">${alert.trail_level ? `₹${alert.trail_level.toFixed(2)} (${alert.trail_side === 'SHORT' ? 'low' : 'high'} ₹${alert.trail_extreme.toFixed(2)})` : 'waiting for activation'}</span>
+            </div>
+          ` : ''}
+          ${alert.alert_type === 'DRAWDOWN' ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Peak P&amp;L:</span>
+              <span class="alert-detail-value">${alert.peak_date ? `₹${alert.peak_pnl.toFixed(2)} (${alert.peak_date})` : 'not tracked yet'}</span>
+            </div>
+          ` : ''}
+          ${alert.alert_type === 'PERCENTAGE_CHANGE' ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Baseline:</span>
+              <span class="alert-detail-value">${alert.baseline_value ? `₹${alert.baseline_value}` : 'next quote'} (${this.formatBaseline(alert)})</span>
+            </div>
+          ` : ''}
+          ${alert.symbol ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">LTP:</span>
+              <span class="alert-detail-value alert-ltp" data-symbol="${this.escapeHtml(alert.symbol)}">${this.prices[alert.symbol] !== undefined ? `₹${this.prices[alert.symbol].toFixed(2)}` : '—'}</span>
+            </div>
+          ` : ''}
           <div class="alert-detail">
             <span class="alert-detail-label">Created:</span>
             <span class="alert-detail-value">${createdAt}</span>
@@ -145,6 +272,32 @@ class AlertsManager {
             <span class="alert-detail-label">Updated:</span>
             <span class="alert-detail-value">${updatedAt}</span>
           </div>
+          <div class="alert-detail">
+            <span class="alert-detail-label">Fires:</span>
+            <span class="alert-detail-value">${this.formatPolicy(alert)}</span>
+          </div>
+          ${alert.rearms_at ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Re-arms:</span>
+              <span class="alert-detail-value">${new Date(alert.rearms_at).toLocaleString()}</span>
+            </div>
+          ` : ''}
+          ${alert.valid_until ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Valid Until:</span>
+              <span class="alert-detail-value">${new Date(alert.valid_until).toLocaleString()}</span>
+            </div>
+          ` : ''}
+          ${alert.triggered_at ? `
+            <div class="alert-detail">
+              <span class="alert-detail-label">Triggered:</span>
+              <span class="alert-detail-value">₹${alert.triggered_price} on ${new Date(alert.triggered_at).toLocaleString()}</span>
+            </div>
+          ` : ''}
+          <div class="alert-detail">
+            <span class="alert-detail-label">Kite:</span>
+            <span class="alert-detail-value sync-${alert.sync_status || 'pending'}" title="${this.escapeHtml(alert.sync_error || '')}">${this.formatSyncStatus(alert.sync_status)}</span>
+          </div>
         </div>
         
         ${alert.message ? `<div class="alert-message">"${this.escapeHtml(alert.message)}"</div>` : ''}
@@ -167,22 +320,261 @@ class AlertsManager {
     `;
   }
   
+  formatSyncStatus(status) {
+    const statuses = {
+      'synced': 'Synced',
+      'failed': 'Sync failed',
+      'local': 'Local only',
+      'pending': 'Not synced yet'
+    };
+    return statuses[status] || statuses.pending;
+  }
+  
   formatAlertType(type) {
     const types = {
       'PRICE_ABOVE': 'Price Above',
       'PRICE_BELOW': 'Price Below',
-      'PERCENTAGE_CHANGE': 'Percentage Change'
+      'PERCENTAGE_CHANGE': 'Percentage Change',
+      'EXPRESSION': 'Expression',
+      'TRAILING_STOP': 'Trailing Stop',
+      'DAY_PNL': 'Day P&L Limit',
+      'POSITION_LOSS': 'Position Loss',
+      'DRAWDOWN': 'Drawdown from Peak P&L',
+      'SMA_CROSS': 'SMA Crossover',
+      'EMA_CROSS': 'EMA Crossover',
+      'RSI': 'RSI',
+      'BOLLINGER': 'Bollinger Band Break',
+      'SUPERTREND': 'Supertrend Flip',
+      'VWAP_CROSS': 'VWAP Cross'
     };
     return types[type] || type;
   }
   
+  // Indicator, parameters and direction of an indicator alert, evaluated on candle close
+  formatIndicator(alert) {
+    const up = alert.condition === '>' || alert.condition === '>=';
+    const side = up ? 'above' : 'below';
+    const descriptions = {
+      'SMA_CROSS': `SMA ${alert.period} crosses ${side} SMA ${alert.slow_period}`,
+      'EMA_CROSS': `EMA ${alert.period} crosses ${side} EMA ${alert.slow_period}`,
+      'RSI': `RSI ${alert.period} ${this.formatCondition(alert.condition)} ${alert.target_value}`,
+      'BOLLINGER': `Close ${side} the ${up ? 'upper' : 'lower'} band (${alert.period}, ${alert.multiplier})`,
+      'SUPERTREND': `Supertrend (${alert.period}, ${alert.multiplier}) turns ${up ? 'up' : 'down'}`,
+      'VWAP_CROSS': `Close crosses ${side} VWAP`
+    };
+    return `${descriptions[alert.alert_type] || alert.alert_type} on ${alert.interval} close`;
+  }
+  
+  // Side, trail and activation of a trailing stop
+  formatTrail(alert) {
+    const trail = alert.trail_type === 'AMOUNT' ? `₹${alert.trail_value}` : `${alert.trail_value}%`;
+    const side = alert.trail_side === 'SHORT' ? 'above the low' : 'below the high';
+    return `${trail} ${side}${alert.activation_price ? ` from ₹${alert.activation_price}` : ''}`;
+  }
+  
+  // Limit of a portfolio alert on the day's P&L or a position's loss
+  formatPortfolio(alert) {
+    switch (alert.alert_type) {
+      case 'DAY_PNL':
+        return `Day P&L ${this.formatCondition(alert.condition)} ₹${alert.target_value}`;
+      case 'DRAWDOWN':
+        return `Day P&L ₹${alert.target_value} below its peak`;
+    }
+    return `Loss of ${alert.target_value}% of cost`;
+  }
+  
+  async loadChannels() {
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {};
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch('/notification-channels', { headers });
+      if (!response.ok) throw new Error('Failed to load notification channels');
+      const data = await response.json();
+      this.channels = data.channels || [];
+      this.renderChannels();
+      this.renderChannelOptions([]);
+    } catch (error) {
+      console.error('Error loading notification channels:', error);
+    }
+  }
+  
+  renderChannels() {
+    const list = document.getElementById('channelsList');
+    if (this.channels.length === 0) {
+      list.innerHTML = '<p>No channels yet. Triggered alerts are emailed to your account address.</p>';
+      return;
+    }
+    list.innerHTML = this.channels.map(ch => `
+      <div class="channel-row">
+        <span><strong>${this.escapeHtml(ch.name)}</strong> · ${ch.type}${ch.is_active ? '' : ' (inactive)'}
+          <small>${this.escapeHtml(ch.address || ch.url || ch.chat_id || '')}</small></span>
+        <span>
+          <button type="button" class="btn btn-secondary" onclick="alertsManager.testChannel(${ch.id})">
+            <i class="fas fa-vial"></i> Test
+          </button>
+          <button type="button" class="btn btn-secondary" onclick="alertsManager.deleteChannel(${ch.id})">
+            <i class="fas fa-trash"></i> Delete
+          </button>
+        </span>
+      </div>
+    `).join('');
+  }
+  
+  // Checkbox per channel in the alert form, checking the alert's selected ones
+  renderChannelOptions(selected) {
+    const options = document.getElementById('channelOptions');
+    if (this.channels.length === 0) {
+      options.innerHTML = '<label>Your account email</label>';
+      return;
+    }
+    options.innerHTML = this.channels.map(ch => `
+      <label>
+        <input type="checkbox" name="channels" value="${ch.id}" ${selected.includes(ch.id) ? 'checked' : ''}>
+        ${this.escapeHtml(ch.name)} (${ch.type.toLowerCase()})
+      </label>
+    `).join('');
+  }
+  
+  async createChannel(e) {
+    e.preventDefault();
+    const channelData = {
+      type: document.getElementById('channelType').value,
+      name: document.getElementById('channelName').value
+    };
+    document.querySelectorAll('.channel-field').forEach(input => {
+      if (input.style.display !== 'none' && input.value) {
+        const field = { channelAddress: 'address', channelUrl: 'url', channelSecret: 'secret', channelBotToken: 'bot_token', channelChatId: 'chat_id' }[input.id];
+        channelData[field] = input.value;
+      }
+    });
+    
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {
+        'Content-Type': 'application/json'
+      };
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch('/notification-channels', {
+        method: 'POST',
+        headers,
+        body: JSON.stringify(channelData)
+      });
+      const data = await response.json().catch(() => ({}));
+      if (!response.ok) {
+        throw new Error(this.formatErrors(data, 'Failed to create channel'));
+      }
+      this.showSuccess(data.message || 'Notification channel created successfully');
+      document.getElementById('channelForm').reset();
+      toggleChannelFields();
+      await this.loadChannels();
+    } catch (error) {
+      console.error('Error creating notification channel:', error);
+      this.showError(error.message);
+    }
+  }
+  
+  async testChannel(channelId) {
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {};
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch(`/notification-channels/test?id=${channelId}`, {
+        method: 'POST',
+        headers
+      });
+      const data = await response.json().catch(() => ({}));
+      if (!response.ok || !data.success) {
+        const detail = data.delivery && data.delivery.detail ? `: ${data.delivery.detail}` : '';
+        throw new Error((data.message || 'Test notification failed') + detail);
+      }
+      this.showSuccess(data.message);
+    } catch (error) {
+      console.error('Error testing notification channel:', error);
+      this.showError(error.message);
+    }
+  }
+  
+  async deleteChannel(channelId) {
+    const channel = this.channels.find(ch => ch.id === channelId);
+    if (!channel) return;
+    
+    this.showConfirmModal(
+      `Are you sure you want to delete the channel ${channel.name}?`,
+      async () => {
+        try {
+          const token = localStorage.getItem('authToken');
+          const headers = {};
+          if (token) {
+            headers['Authorization'] = `Bearer ${token}`;
+          }
+          
+          const response = await fetch(`/notification-channels?id=${channelId}`, {
+            method: 'DELETE',
+            headers
+          });
+          const data = await response.json().catch(() => ({}));
+          if (!response.ok) {
+            throw new Error(data.message || 'Failed to delete channel');
+          }
+          this.showSuccess(data.message || 'Notification channel deleted successfully');
+          await this.loadChannels();
+        } catch (error) {
+          console.error('Error deleting notification channel:', error);
+          this.showError('Failed to delete channel. Please try again.');
+        }
+      }
+    );
+  }
+  
+  // Message of a rejected request, listing each invalid field
+  formatErrors(errorData, fallback) {
+    const message = errorData.message || fallback;
+    if (!errorData.errors || errorData.errors.length === 0) return message;
+    return message + ': ' + errorData.errors
+      .map(e => e.field ? `${e.field} ${e.message}` : e.message)
+      .join('; ');
+  }
+  
+  formatPolicy(alert) {
+    let label = 'Once';
+    if (alert.trigger_policy === 'RECURRING') {
+      label = `Every time, ${Math.round(alert.cooldown_seconds / 60)} min cooldown`;
+    } else if (alert.trigger_policy === 'DAILY') {
+      label = 'Once per trading day';
+    }
+    if (alert.trigger_count > 0) label += ` (fired ${alert.trigger_count}×)`;
+    return label;
+  }
+  
+  formatBaseline(alert) {
+    const baselines = {
+      'PREVIOUS_CLOSE': 'previous close',
+      'CREATION_PRICE': 'price at creation',
+      'DAY_OPEN': 'day open',
+      'FIXED': 'fixed'
+    };
+    let label = baselines[alert.baseline_type] || 'price at creation';
+    if (alert.baseline_date) label += ` for ${alert.baseline_date}`;
+    if (alert.direction === 'EITHER') label += ', either direction';
+    return label;
+  }
+  
   formatCondition(condition) {
     const conditions = {
       '>': 'Greater than',
       '<': 'Less than',
       '>=': 'Greater than or equal to',
-      '<=': 'Less than or equal to',
-      '==': 'Equal to'
+      '<=': 'Less than or equal to'
     };
     return conditions[condition] || condition;
   }
@@ -207,6 +599,67 @@ class AlertsManager {
       message: formData.get('message') || document.getElementById('message').value
     };
     
+    // Add the baseline of percentage change alerts
+    if (alertData.alert_type === 'PERCENTAGE_CHANGE') {
+      alertData.baseline_type = document.getElementById('baselineType').value;
+      alertData.direction = document.getElementById('direction').value;
+      if (alertData.baseline_type === 'FIXED') {
+        alertData.baseline_value = parseFloat(document.getElementById('baselineValue').value) || 0;
+      }
+    }
+    
+    // Expressions replace the condition and target value
+    if (alertData.alert_type === 'EXPRESSION') {
+      alertData.expression = document.getElementById('expression').value;
+      delete alertData.condition;
+      delete alertData.target_value;
+    }
+    
+    // Trailing stops follow the price instead of a condition and target value
+    if (alertData.alert_type === 'TRAILING_STOP') {
+      alertData.trail_side = document.getElementById('trailSide').value;
+      alertData.trail_type = document.getElementById('trailType').value;
+      alertData.trail_value = parseFloat(document.getElementById('trailValue').value) || 0;
+      alertData.activation_price = parseFloat(document.getElementById('activationPrice').value) || 0;
+      delete alertData.condition;
+      delete alertData.target_value;
+    }
+    
+    // Portfolio alerts watch the user's positions; only DAY_PNL takes a condition
+    if (PORTFOLIO_TYPES.includes(alertData.alert_type)) {
+      if (alertData.alert_type !== 'POSITION_LOSS') {
+        alertData.symbol = '';
+      }
+      if (alertData.alert_type !== 'DAY_PNL') {
+        delete alertData.condition;
+      }
+    }
+    
+    // Indicator alerts take a candle interval and parameters; left out ones get defaults
+    if (INDICATOR_TYPES.includes(alertData.alert_type)) {
+      alertData.interval = document.getElementById('interval').value;
+      alertData.period = parseInt(document.getElementById('period').value) || 0;
+      alertData.slow_period = parseInt(document.getElementById('slowPeriod').value) || 0;
+      alertData.multiplier = parseFloat(document.getElementById('multiplier').value) || 0;
+      if (alertData.alert_type !== 'RSI') {
+        delete alertData.target_value;
+      }
+    }
+    
+    // Trigger policy and expiry
+    alertData.trigger_policy = document.getElementById('triggerPolicy').value;
+    if (alertData.trigger_policy === 'RECURRING') {
+      alertData.cooldown_seconds = Math.round((parseFloat(document.getElementById('cooldownMinutes').value) || 0) * 60);
+    }
+    const validUntil = document.getElementById('validUntil').value;
+    if (validUntil) {
+      alertData.valid_until = new Date(validUntil).toISOString();
+    }
+    
+    // Selected notification channels; none selected notifies all
+    alertData.channels = Array.from(document.querySelectorAll('#channelOptions input[name="channels"]:checked'))
+      .map(input => parseInt(input.value));
+    
     // Add options data if instrument type is option
     if (instrumentType === 'OPTION') {
       alertData.underlying_symbol = document.getElementById('underlyingSymbol').value;
@@ -216,7 +669,11 @@ class AlertsManager {
     }
     
     // Validate required fields
-    if (!alertData.symbol || !alertData.alert_type || !alertData.condition) {
+    const isExpression = alertData.alert_type === 'EXPRESSION';
+    const isTrailing = alertData.alert_type === 'TRAILING_STOP';
+    const isPortfolio = PORTFOLIO_TYPES.includes(alertData.alert_type);
+    const needsCondition = !isPortfolio || alertData.alert_type === 'DAY_PNL';
+    if ((!alertData.symbol && !isExpression && !isPortfolio) || !alertData.alert_type || (isExpression ? !alertData.expression : isTrailing ? !alertData.trail_value : needsCondition && !alertData.condition)) {
       this.showError('Please fill in all required fields.');
       return;
     }
@@ -263,7 +720,7 @@ class AlertsManager {
     
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({}));
-      throw new Error(errorData.message || 'Failed to create alert');
+      throw new Error(this.formatErrors(errorData, 'Failed to create alert'));
     }
     
     const data = await response.json();
@@ -287,7 +744,7 @@ class AlertsManager {
     
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({}));
-      throw new Error(errorData.message || 'Failed to update alert');
+      throw new Error(this.formatErrors(errorData, 'Failed to update alert'));
     }
     
     const data = await response.json();
@@ -370,6 +827,28 @@ class AlertsManager {
     document.getElementById('targetValue').value = alert.target_value;
     document.getElementById('condition').value = alert.condition;
     document.getElementById('message').value = alert.message || '';
+    document.getElementById('baselineType').value = alert.baseline_type || 'CREATION_PRICE';
+    document.getElementById('baselineValue').value = alert.baseline_type === 'FIXED' ? alert.baseline_value : '';
+    document.getElementById('direction').value = alert.direction || 'SIGNED';
+    document.getElementById('expression').value = alert.expression || '';
+    document.getElementById('interval').value = alert.interval || '5m';
+    document.getElementById('period').value = alert.period || '';
+    document.getElementById('slowPeriod').value = alert.slow_period || '';
+    document.getElementById('multiplier').value = alert.multiplier || '';
+    document.getElementById('trailSide').value = alert.trail_side || 'LONG';
+    document.getElementById('trailType').value = alert.trail_type || 'PERCENT';
+    document.getElementById('trailValue').value = alert.trail_value || '';
+    document.getElementById('activationPrice').value = alert.activation_price || '';
+    togglePercentageFields();
+    toggleExpressionFields();
+    toggleIndicatorFields();
+    toggleTrailingFields();
+    togglePortfolioFields();
+    document.getElementById('triggerPolicy').value = alert.trigger_policy || 'ONCE';
+    document.getElementById('cooldownMinutes').value = alert.cooldown_seconds ? alert.cooldown_seconds / 60 : '';
+    document.getElementById('validUntil').value = alert.valid_until ? toLocalInputValue(new Date(alert.valid_until)) : '';
+    toggleCooldownField();
+    this.renderChannelOptions(alert.channels || []);
     
     // Handle options fields
     const isOption = alert.option_type && (alert.option_type === 'CALL' || alert.option_type === 'PUT');
@@ -400,6 +879,13 @@ class AlertsManager {
     
     // Show options fields by default (since options is default)
     document.getElementById('optionsFields').style.display = 'block';
+    togglePercentageFields();
+    toggleExpressionFields();
+    toggleIndicatorFields();
+    toggleTrailingFields();
+    togglePortfolioFields();
+    toggleCooldownField();
+    this.renderChannelOptions([]);
     
     // Update modal title and button
     document.getElementById('modalTitle').textContent = 'Add New Alert';
@@ -564,7 +1050,6 @@ function addBulkAlertRow() {
         <option value="<"><</option>
         <option value=">=">>=</option>
         <option value="<="><=</option>
-        <option value="==">==</option>
       </select>
     </td>
     <td>
@@ -693,6 +1178,7 @@ async function submitBulkAlerts() {
     // Create alerts one by one
     let successCount = 0;
     let errorCount = 0;
+    const failures = [];
     
     for (const alert of alerts) {
       try {
@@ -700,17 +1186,19 @@ async function submitBulkAlerts() {
         successCount++;
       } catch (error) {
         errorCount++;
+        failures.push(`${alert.symbol}: ${error.message}`);
         console.error('Failed to create alert:', error);
       }
     }
     
     if (successCount > 0) {
       alertsManager.showSuccess(`Successfully created ${successCount} alerts${errorCount > 0 ? `, ${errorCount} failed` : ''}`);
+      if (failures.length > 0) alertsManager.showError(failures.join('\n'));
       clearBulkAlertRows();
       addBulkAlertRow(); // Add a new empty row
       await alertsManager.loadAlerts();
     } else {
-      alertsManager.showError('Failed to create any alerts');
+      alertsManager.showError('Failed to create any alerts:\n' + failures.join('\n'));
     }
     
   } catch (error) {
@@ -740,11 +1228,121 @@ function toggleOptionsFields() {
   }
 }
 
+function togglePercentageFields() {
+  const isPercentage = document.getElementById('alertType').value === 'PERCENTAGE_CHANGE';
+  const isFixed = document.getElementById('baselineType').value === 'FIXED';
+  
+  document.getElementById('percentageFields').style.display = isPercentage ? 'block' : 'none';
+  document.getElementById('baselineValueGroup').style.display = isPercentage && isFixed ? 'block' : 'none';
+  document.getElementById('baselineValue').required = isPercentage && isFixed;
+}
+
+function toggleExpressionFields() {
+  const isExpression = document.getElementById('alertType').value === 'EXPRESSION';
+  
+  // An expression names its symbols, so the alert's own symbol is optional
+  document.getElementById('expressionGroup').style.display = isExpression ? 'block' : 'none';
+  document.getElementById('expression').required = isExpression;
+  document.getElementById('symbol').required = !isExpression;
+  document.getElementById('targetValueGroup').style.display = isExpression ? 'none' : 'block';
+  document.getElementById('targetValue').required = !isExpression;
+  document.getElementById('conditionGroup').style.display = isExpression ? 'none' : 'block';
+  document.getElementById('condition').required = !isExpression;
+}
+
+// Alert types evaluated on candle close
+const INDICATOR_TYPES = ['SMA_CROSS', 'EMA_CROSS', 'RSI', 'BOLLINGER', 'SUPERTREND', 'VWAP_CROSS'];
+
+function toggleIndicatorFields() {
+  const type = document.getElementById('alertType').value;
+  const isIndicator = INDICATOR_TYPES.includes(type);
+  const isCrossover = type === 'SMA_CROSS' || type === 'EMA_CROSS';
+  const isBanded = type === 'BOLLINGER' || type === 'SUPERTREND';
+  const defaultPeriods = { 'SMA_CROSS': 9, 'EMA_CROSS': 9, 'RSI': 14, 'BOLLINGER': 20, 'SUPERTREND': 10 };
+  
+  document.getElementById('indicatorFields').style.display = isIndicator ? 'block' : 'none';
+  document.getElementById('periodGroup').style.display = isIndicator && type !== 'VWAP_CROSS' ? 'block' : 'none';
+  document.getElementById('periodLabel').textContent = isCrossover ? 'Fast Period' : 'Period';
+  document.getElementById('period').placeholder = `Default ${defaultPeriods[type] || ''}`;
+  document.getElementById('slowPeriodGroup').style.display = isCrossover ? 'block' : 'none';
+  document.getElementById('multiplierGroup').style.display = isBanded ? 'block' : 'none';
+  document.getElementById('multiplier').placeholder = `Default ${type === 'BOLLINGER' ? 2 : 3}`;
+  
+  // Only RSI compares with a target value; the other indicators fire on a cross, break or flip
+  if (isIndicator) {
+    const usesTarget = type === 'RSI';
+    document.getElementById('targetValueGroup').style.display = usesTarget ? 'block' : 'none';
+    document.getElementById('targetValue').required = usesTarget;
+  }
+}
+
+function toggleTrailingFields() {
+  const isTrailing = document.getElementById('alertType').value === 'TRAILING_STOP';
+  
+  document.getElementById('trailingFields').style.display = isTrailing ? 'block' : 'none';
+  document.getElementById('trailValue').required = isTrailing;
+  
+  // The side and trail decide when the stop fires
+  if (isTrailing) {
+    document.getElementById('targetValueGroup').style.display = 'none';
+    document.getElementById('targetValue').required = false;
+    document.getElementById('conditionGroup').style.display = 'none';
+    document.getElementById('condition').required = false;
+  }
+}
+
+// Alert types on the user's own positions and P&L
+const PORTFOLIO_TYPES = ['DAY_PNL', 'POSITION_LOSS', 'DRAWDOWN'];
+
+function togglePortfolioFields() {
+  const type = document.getElementById('alertType').value;
+  const isPortfolio = PORTFOLIO_TYPES.includes(type);
+  const targetLabels = {
+    'DAY_PNL': 'Day P&L Limit (₹) *',
+    'POSITION_LOSS': 'Loss (% of cost) *',
+    'DRAWDOWN': 'Drawdown from Peak (₹) *'
+  };
+  
+  document.getElementById('symbolGroup').style.display = isPortfolio && type !== 'POSITION_LOSS' ? 'none' : 'block';
+  document.getElementById('instrumentTypeGroup').style.display = isPortfolio ? 'none' : 'block';
+  document.getElementById('targetValueLabel').textContent = targetLabels[type] || 'Target Value *';
+  
+  // Positions are named by symbol alone, and a position loss alert without one watches them all
+  if (isPortfolio) {
+    document.getElementById('symbol').required = false;
+    document.getElementById('instrumentType').value = 'STOCK';
+    toggleOptionsFields();
+    document.getElementById('conditionGroup').style.display = type === 'DAY_PNL' ? 'block' : 'none';
+    document.getElementById('condition').required = type === 'DAY_PNL';
+  }
+}
+
+function toggleCooldownField() {
+  const isRecurring = document.getElementById('triggerPolicy').value === 'RECURRING';
+  document.getElementById('cooldownGroup').style.display = isRecurring ? 'block' : 'none';
+  document.getElementById('cooldownMinutes').required = isRecurring;
+}
+
+// Show the inputs of the selected notification channel type
+function toggleChannelFields() {
+  const type = document.getElementById('channelType').value;
+  document.querySelectorAll('.channel-field').forEach(input => {
+    input.style.display = input.dataset.types.split(' ').includes(type) ? '' : 'none';
+  });
+}
+
+// Format a date for a datetime-local input, which takes local time without a zone
+function toLocalInputValue(date) {
+  const pad = n => String(n).padStart(2, '0');
+  return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}T${pad(date.getHours())}:${pad(date.getMinutes())}`;
+}
+
 // Initialize alerts manager when DOM is loaded
 let alertsManager;
 document.addEventListener('DOMContentLoaded', () => {
   alertsManager = new AlertsManager();
   initializeBulkAlertTable(); // Initialize the bulk alert table
+  toggleChannelFields();
 });
 
 // Add CSS animations for notifications
@@ -773,3 +1371,24 @@ style.textContent = `
   }
 `;
 document.head.appendChild(style);
+
+// Start the Kite login flow; Kite redirects back to /kite/callback
+async function connectKite() {
+  try {
+    const token = localStorage.getItem('authToken');
+    const headers = {};
+    if (token) {
+      headers['Authorization'] = `Bearer ${token}`;
+    }
+    
+    const response = await fetch('/kite/login-url', { headers });
+    const data = await response.json();
+    if (!response.ok || !data.login_url) {
+      throw new Error(data.message || 'Failed to start Kite login');
+    }
+    window.location.href = data.login_url;
+  } catch (error) {
+    console.error('Error starting Kite login:', error);
+    alertsManager.showError(error.message);
+  }
+}
@@ -5,14 +5,116 @@ class Dashboard {
     this.allPnlData = [];
     this.allTradesData = [];
     this.currentFilter = null;
+    this.positions = [];
+    this.eventSource = null;
+    this.reloadTimer = null;
     this.init();
   }
   
   async init() {
     console.log('Initializing dashboard...');
     await this.loadDashboardData();
+    await this.loadPositions();
     this.setupCharts();
     this.setupDateFilter();
+    this.connectLiveUpdates();
+  }
+  
+  async loadPositions() {
+    try {
+      const token = localStorage.getItem('authToken');
+      const headers = {};
+      if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+      }
+      
+      const response = await fetch('/portfolio', { headers });
+      if (!response.ok) throw new Error('Failed to load positions');
+      const data = await response.json();
+      this.positions = (data.positions || []).filter(p => p.net_quantity !== 0);
+      this.renderPositions();
+      
+    } catch (error) {
+      console.error('Error loading positions:', error);
+      document.getElementById('openPositions').innerHTML = '<div class="error">Failed to load positions.</div>';
+    }
+  }
+  
+  // Stream prices, trades and alert triggers from the server; EventSource reconnects on its own
+  connectLiveUpdates() {
+    const token = localStorage.getItem('authToken');
+    if (!token || !window.EventSource) return;
+    
+    const status = document.getElementById('liveStatus');
+    this.eventSource = new EventSource(`/events?token=${encodeURIComponent(token)}`);
+    this.eventSource.onopen = () => { status.textContent = '● live'; status.style.color = '#28a745'; };
+    this.eventSource.onerror = () => { status.textContent = '○ reconnecting'; status.style.color = '#7f8c8d'; };
+    
+    this.eventSource.addEventListener('quote', (e) => this.applyQuote(JSON.parse(e.data)));
+    this.eventSource.addEventListener('trade', (e) => {
+      const trade = JSON.parse(e.data);
+      this.showFilterStatus(`New trade: ${trade.side} ${trade.symbol} @ ₹${trade.price}`, 'info');
+      this.scheduleReload();
+    });
+    this.eventSource.addEventListener('alert', (e) => {
+      const alert = JSON.parse(e.data);
+      this.showFilterStatus(`🔔 ${alert.symbol} at ₹${alert.price}: ${alert.message || 'alert triggered'}`, 'success');
+    });
+  }
+  
+  // Coalesce bursts of trades into one reload
+  scheduleReload() {
+    clearTimeout(this.reloadTimer);
+    this.reloadTimer = setTimeout(async () => {
+      await this.loadDashboardData();
+      await this.loadPositions();
+      if (this.currentFilter) this.applyDateFilter();
+    }, 500);
+  }
+  
+  applyQuote(quote) {
+    let changed = false;
+    this.positions.forEach(p => {
+      if (p.symbol === quote.symbol) {
+        p.unrealized_pnl = (quote.ltp - p.avg_cost) * p.net_quantity;
+        p.mark_price = quote.ltp;
+        changed = true;
+      }
+    });
+    if (changed) this.renderPositions();
+  }
+  
+  renderPositions() {
+    const container = document.getElementById('openPositions');
+    if (this.positions.length === 0) {
+      container.innerHTML = '<div class="loading">No open positions.</div>';
+      return;
+    }
+    
+    container.innerHTML = `
+      <table style="width: 100%; border-collapse: collapse;">
+        <thead>
+          <tr style="background: #f8f9fa;">
+            <th style="padding: 0.8em; text-align: left; border-bottom: 2px solid #dee2e6;">Symbol</th>
+            <th style="padding: 0.8em; text-align: left; border-bottom: 2px solid #dee2e6;">Qty</th>
+            <th style="padding: 0.8em; text-align: left; border-bottom: 2px solid #dee2e6;">Avg Cost</th>
+            <th style="padding: 0.8em; text-align: left; border-bottom: 2px solid #dee2e6;">LTP</th>
+            <th style="padding: 0.8em; text-align: left; border-bottom: 2px solid #dee2e6;">Unrealized</th>
+          </tr>
+        </thead>
+        <tbody>
+          ${this.positions.map(p => `
+            <tr style="border-bottom: 1px solid #f1f3f4;">
+              <td style="padding: 0.8em;">${p.symbol}</td>
+              <td style="padding: 0.8em;">${p.net_quantity}</td>
+              <td style="padding: 0.8em;">₹${p.avg_cost.toFixed(2)}</td>
+              <td style="padding: 0.8em;">₹${p.mark_price.toFixed(2)}</td>
+              <td style="padding: 0.8em;" class="${p.unrealized_pnl >= 0 ? 'pnl-positive' : 'pnl-negative'}">₹${p.unrealized_pnl.toFixed(2)}</td>
+            </tr>
+          `).join('')}
+        </tbody>
+      </table>
+    `;
   }
   
   async loadDashboardData() {